@@ -1,9 +1,19 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/beakeyz/gones-emu/pkg/audio"
+	"github.com/beakeyz/gones-emu/pkg/audio/sdlaudio"
 	"github.com/beakeyz/gones-emu/pkg/debug"
 	"github.com/beakeyz/gones-emu/pkg/hardware"
+	"github.com/beakeyz/gones-emu/pkg/hardware/memory/cartridge"
+	"github.com/beakeyz/gones-emu/pkg/hardware/ppu"
+	"github.com/beakeyz/gones-emu/pkg/hardware/region"
 	"github.com/beakeyz/gones-emu/pkg/video"
+	"github.com/beakeyz/gones-emu/pkg/video/sdlvideo"
 )
 
 func main() {
@@ -11,22 +21,134 @@ func main() {
 	// Video backend for drawing what the PPU wants
 	var vidBackend video.VideoBackend
 	var nes *hardware.NESSystem
+	var romPath string
+	var headless bool
+	var frames int
+	var palette string
+	var scale string
+	var regionName string
+	var romDb string
+	var audioOut string
+	var sampleRate int
+	var record string
+	var recordChannels bool
+	var debugLog bool
+
+	flag.StringVar(&romPath, "rom", "res/SuperMarioBros.nes", "path to the ROM to run")
+	flag.BoolVar(&headless, "headless", false, "run without a window, into an in-memory framebuffer")
+	flag.IntVar(&frames, "frames", 60, "amount of frames to run for when headless")
+	flag.StringVar(&palette, "palette", ppu.PALETTE_DEFAULT,
+		"built-in palette ("+strings.Join(ppu.PaletteNames(), ", ")+") or path to a .pal file")
+	flag.StringVar(&scale, "scale", "integer", "how to scale the screen up (integer, aspect)")
+	flag.StringVar(&regionName, "region", "auto", "console region (auto, ntsc, pal, dendy)")
+	flag.StringVar(&romDb, "romdb", "", "ROM database to pick the region from (lines of 'crc32 region name')")
+	flag.StringVar(&audioOut, "audio", "", "where the sound goes (sdl, null, or a .wav path). sdl, or null when headless")
+	flag.IntVar(&sampleRate, "samplerate", audio.SAMPLE_RATE_48000, "audio sample rate (44100, 48000)")
+	flag.StringVar(&record, "record", "", "record the audio into this .wav (F9 toggles recording too)")
+	flag.BoolVar(&recordChannels, "record-channels", false, "also record every APU channel into its own .wav")
+	flag.BoolVar(&debugLog, "debug", false, "log what the hardware is doing (slow, way too slow for real time)")
+	flag.Parse()
 
-	// Enable debugging
-	debug.Enable()
+	// Debug logging costs more than a frame's worth of time, so only on request
+	if debugLog {
+		debug.Enable()
+	}
 
 	// Initialize the video backend
-	err = video.InitVideo(&vidBackend)
+	if headless {
+		vidBackend = video.NewFramebuffer()
+	} else {
+		var mode video.ScaleMode
+
+		mode, err = video.ParseScaleMode(scale)
+
+		if err != nil {
+			debug.Error("%s\n", err.Error())
+			return
+		}
+
+		vidBackend, err = sdlvideo.New(mode)
+
+		if err != nil {
+			debug.Error("Failed to initialize video")
+			return
+		}
+	}
+
+	if romDb != "" {
+		err = cartridge.LoadRomDatabase(romDb)
+
+		if err != nil {
+			debug.Error("Failed to load ROM database: %s\n", err.Error())
+			return
+		}
+	}
+
+	nes, err = hardware.InitNesSystem(vidBackend, romPath)
 
 	if err != nil {
-		debug.Error("Failed to initialize video")
+		debug.Error("Failed to init nes system!")
 		return
 	}
 
-	nes, err = hardware.InitNesSystem(&vidBackend, "res/SuperMarioBros.nes")
+	// The region from the command line wins over whatever the cartridge says
+	if regionName != "auto" {
+		r, err := region.Parse(regionName)
+
+		if err != nil {
+			debug.Error("%s\n", err.Error())
+			return
+		}
+
+		nes.SetRegion(r)
+	}
+
+	if audioOut == "" {
+		audioOut = "sdl"
+
+		if headless {
+			audioOut = "null"
+		}
+	}
+
+	audBackend, err := openAudio(audioOut, sampleRate)
 
 	if err != nil {
-		debug.Error("Failed to init nes system!")
+		debug.Error("Failed to initialize audio: %s\n", err.Error())
+		return
+	}
+
+	nes.SetAudioBackend(audBackend)
+	defer nes.CloseAudio()
+
+	if record != "" {
+		err = nes.StartRecording(record, sampleRate, recordChannels)
+
+		if err != nil {
+			debug.Error("Failed to start recording: %s\n", err.Error())
+			return
+		}
+	}
+
+	defer nes.StopRecording()
+
+	err = nes.SetPalette(palette)
+
+	if err != nil {
+		debug.Error("Failed to load palette: %s\n", err.Error())
+		return
+	}
+
+	if headless {
+		for range frames {
+			err = nes.StepFrame()
+
+			if err != nil {
+				debug.Error("Stopped with the error: %s\n", err.Error())
+				return
+			}
+		}
+
 		return
 	}
 
@@ -34,3 +156,22 @@ func main() {
 
 	// debug.Log("\nExited with the error: %s\n", err.Error())
 }
+
+/*
+ * Picks the audio backend. Anything that isn't a known backend is a file
+ * to record into
+ */
+func openAudio(name string, rate int) (audio.AudioBackend, error) {
+	if rate != audio.SAMPLE_RATE_44100 && rate != audio.SAMPLE_RATE_48000 {
+		return nil, fmt.Errorf("unsupported sample rate %d", rate)
+	}
+
+	switch name {
+	case "null":
+		return audio.NewNull(rate), nil
+	case "sdl":
+		return sdlaudio.New(rate)
+	}
+
+	return audio.NewWav(name, rate)
+}
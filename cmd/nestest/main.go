@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/beakeyz/gones-emu/pkg/hardware/bus"
+	"github.com/beakeyz/gones-emu/pkg/hardware/cpu/cpu6502"
+	"github.com/beakeyz/gones-emu/pkg/hardware/memory/cartridge"
+	"github.com/beakeyz/gones-emu/pkg/hardware/memory/ram"
+	"github.com/beakeyz/gones-emu/pkg/hardware/ppu"
+)
+
+/* nestest.log covers this many instructions */
+const NESTEST_LOG_LINES = 8991
+
+/* nestest runs all of its tests without a screen when started here */
+const NESTEST_AUTOMATION_PC = 0xc000
+
+/*
+ * The PPU does 3 dots per CPU cycle. nestest never turns rendering on, so
+ * there's no odd frame dot skip to worry about and we can just work out
+ * where the PPU is from the cycle count
+ */
+func ppuPosition(cycles uint64) (int, int) {
+	dots := cycles * 3
+
+	return int((dots / 341) % 262), int(dots % 341)
+}
+
+func readLog(path string) ([]string, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var lines []string
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+
+	return lines, scanner.Err()
+}
+
+/*
+ * A 2A03 with RAM and the nestest cartridge, sitting at the start of the
+ * automated run
+ */
+func newNestestCpu(rom_path string) (*cpu6502.CPU6502, *bus.SystemBus, error) {
+	sbus, _ := bus.NewSystembus()
+	ppu_bus, _ := bus.NewSystembus()
+
+	sbus.AddComponent(ram.New(0, 0x1fff, 0x0800))
+
+	_, err := cartridge.LoadCardridge(sbus, ppu_bus, ppu.NewVram(ppu.MIRROR_HORIZONTAL), rom_path)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := cpu6502.New(sbus, cpu6502.C6502_VARIANT_2A03)
+	c.Initialize()
+	c.SetPC(NESTEST_AUTOMATION_PC)
+
+	return c, sbus, nil
+}
+
+func main() {
+	os.Exit(run())
+}
+
+/*
+ * Headless nestest runner. Runs nestest.nes in automation mode, prints the
+ * trace and, when given a reference log, stops at the first line where we
+ * don't agree with it
+ */
+func run() int {
+	var rom_path string
+	var log_path string
+	var trace_path string
+	var max_instr int
+	var golden []string
+	var trace io.Writer = io.Discard
+	var cycles int
+
+	flag.StringVar(&rom_path, "rom", "res/nestest.nes", "path to nestest.nes")
+	flag.StringVar(&log_path, "log", "", "reference nestest.log to compare against")
+	flag.StringVar(&trace_path, "trace", "", "write the trace here ('-' for stdout)")
+	flag.IntVar(&max_instr, "n", NESTEST_LOG_LINES, "amount of instructions to run")
+	flag.Parse()
+
+	if log_path != "" {
+		var err error
+
+		golden, err = readLog(log_path)
+
+		if err != nil {
+			fmt.Printf("nestest: failed to read the reference log: %s\n", err.Error())
+			return 2
+		}
+
+		max_instr = len(golden)
+	} else if trace_path == "" {
+		// Nothing to compare against, so at least show what we did
+		trace_path = "-"
+	}
+
+	switch trace_path {
+	case "":
+	case "-":
+		trace = os.Stdout
+	default:
+		f, err := os.Create(trace_path)
+
+		if err != nil {
+			fmt.Printf("nestest: failed to create the trace: %s\n", err.Error())
+			return 2
+		}
+
+		defer f.Close()
+
+		w := bufio.NewWriter(f)
+		defer w.Flush()
+
+		trace = w
+	}
+
+	c, sbus, err := newNestestCpu(rom_path)
+
+	if err != nil {
+		fmt.Printf("nestest: failed to load '%s': %s\n", rom_path, err.Error())
+		return 2
+	}
+
+	for i := range max_instr {
+		line := c.Trace(ppuPosition(c.GetCycles()))
+
+		fmt.Fprintln(trace, line)
+
+		if golden != nil && line != golden[i] {
+			fmt.Printf("nestest: first divergence at line %d\n", i+1)
+
+			if i > 0 {
+				fmt.Printf("  prev: %s\n", golden[i-1])
+			}
+
+			fmt.Printf("  want: %s\n", golden[i])
+			fmt.Printf("  got:  %s\n", line)
+			return 1
+		}
+
+		err = c.ExecuteFrame(&cycles)
+
+		if err != nil {
+			fmt.Printf("nestest: CPU error after %d instructions: %s\n", i+1, err.Error())
+			return 1
+		}
+	}
+
+	// nestest leaves its error codes in $02 (official) and $03 (unofficial)
+	var official, unofficial uint8
+
+	sbus.Read(0x0002, &official)
+	sbus.Read(0x0003, &unofficial)
+
+	fmt.Printf("nestest: %d instructions, results $%02X $%02X\n", max_instr, official, unofficial)
+
+	if golden != nil {
+		fmt.Println("nestest: trace matches the reference log")
+	}
+
+	if official != 0 || unofficial != 0 {
+		return 1
+	}
+
+	return 0
+}
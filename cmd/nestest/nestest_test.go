@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+/*
+ * nestest.nes and its log aren't ours to ship, so drop them in res/ to
+ * have this check the CPU
+ */
+const (
+	testRomPath = "../../res/nestest.nes"
+	testLogPath = "../../res/nestest.log"
+)
+
+/*
+ * Trace nestest from $C000 and compare every line against the reference
+ * log, stopping at the first one that differs
+ */
+func TestNestest(t *testing.T) {
+	var cycles int
+
+	for _, path := range []string{testRomPath, testLogPath} {
+		if _, err := os.Stat(path); err != nil {
+			t.Skipf("%s is missing", path)
+		}
+	}
+
+	golden, err := readLog(testLogPath)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, _, err := newNestestCpu(testRomPath)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range golden {
+		got := c.Trace(ppuPosition(c.GetCycles()))
+
+		if got != want {
+			if i > 0 {
+				t.Logf("prev: %s", golden[i-1])
+			}
+
+			t.Fatalf("first divergence at line %d\nwant: %s\ngot:  %s", i+1, want, got)
+		}
+
+		err = c.ExecuteFrame(&cycles)
+
+		if err != nil {
+			t.Fatalf("CPU error after %d instructions: %s", i+1, err.Error())
+		}
+	}
+}
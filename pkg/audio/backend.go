@@ -0,0 +1,56 @@
+package audio
+
+/*
+ * Whatever the NES sound ends up on. This can be the sound card, a file
+ * or nothing at all
+ *
+ * Samples are mono floats between -1.0 and 1.0, at the rate the backend
+ * asks for
+ */
+type AudioBackend interface {
+	/* The rate we should be producing samples at */
+	SampleRate() int
+	/* Take some more samples to play */
+	QueueSamples(samples []float32) error
+	/*
+	 * How many samples are waiting to be played. Backends that don't play
+	 * in real time (files, nothing) return -1, since there's no buffer to
+	 * keep filled
+	 */
+	QueuedSamples() int
+	Close() error
+}
+
+const (
+	SAMPLE_RATE_44100 = 44100
+	SAMPLE_RATE_48000 = 48000
+)
+
+/*
+ * Backend that throws everything away, for when we don't want sound
+ */
+type Null struct {
+	rate int
+}
+
+func NewNull(rate int) *Null {
+	return &Null{
+		rate: rate,
+	}
+}
+
+func (n *Null) SampleRate() int {
+	return n.rate
+}
+
+func (n *Null) QueueSamples(samples []float32) error {
+	return nil
+}
+
+func (n *Null) QueuedSamples() int {
+	return -1
+}
+
+func (n *Null) Close() error {
+	return nil
+}
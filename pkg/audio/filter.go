@@ -0,0 +1,29 @@
+package audio
+
+import "math"
+
+/*
+ * One pole highpass. The NES (roughly) highpasses its output at 37 Hz,
+ * which also gets rid of the DC the APU output sits on
+ */
+type highpass struct {
+	factor float32
+	in     float32
+	out    float32
+}
+
+const HIGHPASS_HZ = 37.0
+
+func newHighpass(rate int) *highpass {
+	return &highpass{
+		factor: float32(math.Exp(-2 * math.Pi * HIGHPASS_HZ / float64(rate))),
+	}
+}
+
+func (hp *highpass) process(samples []float32) {
+	for i, in := range samples {
+		hp.out = hp.factor*hp.out + in - hp.in
+		hp.in = in
+		samples[i] = hp.out
+	}
+}
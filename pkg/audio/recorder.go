@@ -0,0 +1,109 @@
+package audio
+
+/*
+ * Records into one or more .wav files, each with its own signal. Used to
+ * dump the mixed APU output, and the channels on their own next to it
+ *
+ * This never does rate control, so the same run always gives the exact
+ * same files. That's what makes them useful to diff against
+ */
+type Recorder struct {
+	tracks    []*recorderTrack
+	clockRate float64
+	rate      int
+
+	samples []float32
+}
+
+type recorderTrack struct {
+	writer    *WavWriter
+	resampler *Resampler
+	filter    *highpass
+}
+
+/*
+ * Start recording into a file for each of @paths. Feed them with Clock,
+ * using the index of the path
+ */
+func NewRecorder(paths []string, rate int, clockRate float64) (*Recorder, error) {
+	rec := &Recorder{
+		clockRate: clockRate,
+		rate:      rate,
+		samples:   make([]float32, RESAMPLER_BUFFER_SIZE),
+	}
+
+	for _, path := range paths {
+		writer, err := NewWavWriter(path, rate, 1)
+
+		if err != nil {
+			rec.Close()
+			return nil, err
+		}
+
+		rec.tracks = append(rec.tracks, &recorderTrack{
+			writer:    writer,
+			resampler: NewResampler(clockRate, rate),
+			filter:    newHighpass(rate),
+		})
+	}
+
+	return rec, nil
+}
+
+func (rec *Recorder) SetClockRate(clockRate float64) {
+	rec.clockRate = clockRate
+
+	for _, track := range rec.tracks {
+		track.resampler.SetRates(clockRate, rec.rate, 1.0)
+	}
+}
+
+func (rec *Recorder) Tracks() int {
+	return len(rec.tracks)
+}
+
+/*
+ * Feed @track the output for a single CPU cycle
+ */
+func (rec *Recorder) Clock(track int, level float32) {
+	rec.tracks[track].resampler.Clock(level)
+}
+
+/*
+ * Write out whatever got finished
+ */
+func (rec *Recorder) EndFrame() error {
+	for _, track := range rec.tracks {
+		n := track.resampler.ReadSamples(rec.samples)
+		samples := rec.samples[:n]
+
+		track.filter.process(samples)
+
+		err := track.writer.WriteSamples(samples)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Finish all the files up. Returns the first thing that went wrong
+ */
+func (rec *Recorder) Close() error {
+	var first error
+
+	for _, track := range rec.tracks {
+		err := track.writer.Close()
+
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+
+	rec.tracks = nil
+
+	return first
+}
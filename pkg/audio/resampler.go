@@ -0,0 +1,160 @@
+package audio
+
+import "math"
+
+/*
+ * Band-limited resampler
+ *
+ * The APU output is a staircase that changes at most once per CPU cycle
+ * (~1.79 MHz). Just picking every 40th value or so aliases like crazy, so
+ * instead every change in level gets turned into a band-limited step
+ * (an integrated windowed sinc) at its exact (fractional) position in the
+ * output. Output samples are the running sum of all of those.
+ *
+ * This only does work when the level actually changes, which is what keeps
+ * it cheap enough to feed at the CPU clock
+ *
+ * See: http://www.slack.net/~ant/bl-synth/
+ */
+
+const (
+	/* How many output samples a single step is spread over */
+	RESAMPLER_TAPS = 16
+	/* Fractional positions the step kernel is worked out for */
+	RESAMPLER_PHASES = 64
+	/* Where the kernel cuts off, as a fraction of the output nyquist */
+	RESAMPLER_CUTOFF = 0.90
+	/* Output samples we can hold before somebody has to read them */
+	RESAMPLER_BUFFER_SIZE = 8192
+)
+
+/*
+ * The impulse a step contributes to every output sample around it, for
+ * each fractional position. Summed up, these make the band-limited step
+ */
+var stepKernel [RESAMPLER_PHASES + 1][RESAMPLER_TAPS]float32
+
+func init() {
+	half := float64(RESAMPLER_TAPS / 2)
+
+	for p := range stepKernel {
+		frac := float64(p) / RESAMPLER_PHASES
+
+		var impulse [RESAMPLER_TAPS]float64
+		var sum float64
+
+		for i := range RESAMPLER_TAPS {
+			x := float64(i) - half + 1 - frac
+
+			// Lowpassed sinc, in a Blackman window to keep it short
+			v := RESAMPLER_CUTOFF
+			if x != 0 {
+				v = math.Sin(math.Pi*RESAMPLER_CUTOFF*x) / (math.Pi * x)
+			}
+
+			w := 0.0
+			if math.Abs(x) < half {
+				w = 0.42 + 0.5*math.Cos(math.Pi*x/half) + 0.08*math.Cos(2*math.Pi*x/half)
+			}
+
+			impulse[i] = v * w
+			sum += impulse[i]
+		}
+
+		// Every step has to add up to exactly its height, or we'd drift
+		for i := range RESAMPLER_TAPS {
+			stepKernel[p][i] = float32(impulse[i] / sum)
+		}
+	}
+}
+
+type Resampler struct {
+	/* Deltas, waiting to be summed into output samples */
+	buf [RESAMPLER_BUFFER_SIZE + RESAMPLER_TAPS]float32
+	/* Where we are in buf, in output samples */
+	time float64
+	/* Output samples per input clock */
+	step float64
+
+	/* The level the last clock left us at */
+	level float32
+	/* The running sum of everything we already handed out */
+	integrator float32
+}
+
+func NewResampler(clockRate float64, sampleRate int) *Resampler {
+	r := &Resampler{}
+
+	r.SetRates(clockRate, sampleRate, 1.0)
+
+	return r
+}
+
+/*
+ * Go from @clockRate input samples a second to @sampleRate output ones.
+ * @adjust scales the amount of output, for rate control
+ */
+func (r *Resampler) SetRates(clockRate float64, sampleRate int, adjust float64) {
+	r.step = float64(sampleRate) * adjust / clockRate
+}
+
+/*
+ * Feed a single input sample
+ */
+func (r *Resampler) Clock(level float32) {
+	if level != r.level {
+		r.addDelta(level - r.level)
+		r.level = level
+	}
+
+	r.time += r.step
+}
+
+func (r *Resampler) addDelta(delta float32) {
+	pos := int(r.time)
+
+	// Nobody is reading. Keep the level right, even if the timing isn't
+	if pos >= RESAMPLER_BUFFER_SIZE {
+		r.buf[RESAMPLER_BUFFER_SIZE-1] += delta
+		return
+	}
+
+	phase := int((r.time-float64(pos))*RESAMPLER_PHASES + 0.5)
+	kernel := &stepKernel[phase]
+	out := r.buf[pos : pos+RESAMPLER_TAPS]
+
+	for i := range out {
+		out[i] += kernel[i] * delta
+	}
+}
+
+/*
+ * How many output samples are done and ready to be read
+ */
+func (r *Resampler) Available() int {
+	return min(int(r.time), RESAMPLER_BUFFER_SIZE)
+}
+
+/*
+ * Read up to len(@out) finished samples. Returns how many we got
+ */
+func (r *Resampler) ReadSamples(out []float32) int {
+	n := min(len(out), r.Available())
+
+	for i := range n {
+		r.integrator += r.buf[i]
+		out[i] = r.integrator
+	}
+
+	// Move whatever is still being worked on to the front
+	copy(r.buf[:], r.buf[n:])
+	clear(r.buf[len(r.buf)-n:])
+
+	r.time -= float64(n)
+
+	if r.time > RESAMPLER_BUFFER_SIZE {
+		r.time = RESAMPLER_BUFFER_SIZE
+	}
+
+	return n
+}
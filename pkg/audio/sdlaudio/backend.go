@@ -0,0 +1,76 @@
+package sdlaudio
+
+import (
+	"unsafe"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+/*
+ * Audio backend that plays through SDL
+ *
+ * We push samples into SDL's queue instead of having it call back into
+ * us, since the emulator runs on its own schedule anyway
+ */
+type Backend struct {
+	device sdl.AudioDeviceID
+	rate   int
+}
+
+/* Size of the chunks SDL pulls from its queue, in samples */
+const SDL_AUDIO_CHUNK = 1024
+
+func New(rate int) (*Backend, error) {
+	var obtained sdl.AudioSpec
+
+	err := sdl.InitSubSystem(sdl.INIT_AUDIO)
+
+	if err != nil {
+		return nil, err
+	}
+
+	desired := sdl.AudioSpec{
+		Freq:     int32(rate),
+		Format:   sdl.AUDIO_F32SYS,
+		Channels: 1,
+		Samples:  SDL_AUDIO_CHUNK,
+	}
+
+	device, err := sdl.OpenAudioDevice("", false, &desired, &obtained, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Devices start out paused
+	sdl.PauseAudioDevice(device, false)
+
+	return &Backend{
+		device: device,
+		rate:   int(obtained.Freq),
+	}, nil
+}
+
+func (back *Backend) SampleRate() int {
+	return back.rate
+}
+
+func (back *Backend) QueueSamples(samples []float32) error {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	data := unsafe.Slice((*byte)(unsafe.Pointer(&samples[0])), len(samples)*4)
+
+	return sdl.QueueAudio(back.device, data)
+}
+
+func (back *Backend) QueuedSamples() int {
+	return int(sdl.GetQueuedAudioSize(back.device) / 4)
+}
+
+func (back *Backend) Close() error {
+	sdl.CloseAudioDevice(back.device)
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package audio
+
+/*
+ * Takes the APU output at the CPU clock and gets it to a backend
+ *
+ * Video is paced to the frame rate, so the sound card and the emulator
+ * don't quite agree on how fast time goes. To keep the backend buffer from
+ * running dry or piling up, we nudge the resampling rate up or down a tiny
+ * bit depending on how full it is. At 0.5% max that's not something anyone
+ * can hear
+ *
+ * See: https://docs.libretro.com/development/cores/dynamic-rate-control/
+ */
+
+const (
+	/* How much audio we try to keep queued in the backend */
+	STREAM_LATENCY_MS = 64
+	/* How far we're allowed to stray from the real rate */
+	STREAM_MAX_RATE_DELTA = 0.005
+)
+
+type Stream struct {
+	backend   AudioBackend
+	resampler *Resampler
+
+	clockRate float64
+	/* Samples we want queued in the backend */
+	target int
+
+	filter *highpass
+
+	samples []float32
+}
+
+func NewStream(backend AudioBackend, clockRate float64) *Stream {
+	rate := backend.SampleRate()
+
+	return &Stream{
+		backend:   backend,
+		resampler: NewResampler(clockRate, rate),
+		clockRate: clockRate,
+		target:    rate * STREAM_LATENCY_MS / 1000,
+		filter:    newHighpass(rate),
+		samples:   make([]float32, RESAMPLER_BUFFER_SIZE),
+	}
+}
+
+/*
+ * The CPU clock changed, because we switched regions
+ */
+func (s *Stream) SetClockRate(clockRate float64) {
+	s.clockRate = clockRate
+	s.resampler.SetRates(clockRate, s.backend.SampleRate(), 1.0)
+}
+
+/*
+ * Feed the output for a single CPU cycle
+ */
+func (s *Stream) Clock(level float32) {
+	s.resampler.Clock(level)
+}
+
+/*
+ * Hand everything we made this frame to the backend, and see whether we
+ * should speed up or slow down a bit
+ */
+func (s *Stream) EndFrame() error {
+	n := s.resampler.ReadSamples(s.samples)
+	samples := s.samples[:n]
+
+	s.filter.process(samples)
+
+	queued := s.backend.QueuedSamples()
+
+	// Not real time, so there's nothing to keep up with
+	if queued < 0 {
+		return s.backend.QueueSamples(samples)
+	}
+
+	// Way too much queued up (fast-forward probably). Let it drain
+	if queued > s.target*4 {
+		return nil
+	}
+
+	fill := float64(s.target-queued) / float64(s.target)
+	fill = min(max(fill, -1.0), 1.0)
+
+	s.resampler.SetRates(s.clockRate, s.backend.SampleRate(), 1.0+fill*STREAM_MAX_RATE_DELTA)
+
+	return s.backend.QueueSamples(samples)
+}
+
+func (s *Stream) Close() error {
+	return s.backend.Close()
+}
@@ -0,0 +1,180 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"os"
+)
+
+/*
+ * Writes 16-bit PCM .wav files
+ *
+ * The header needs the size of the data, which we only know at the end,
+ * so it gets written with zeroes first and patched up in Close
+ *
+ * See: http://soundfile.sapp.org/doc/WaveFormat/
+ */
+type WavWriter struct {
+	file *os.File
+	out  *bufio.Writer
+
+	rate     int
+	channels int
+	/* Bytes of sample data we wrote so far */
+	data_size uint32
+}
+
+const (
+	WAV_HEADER_SIZE     = 44
+	WAV_BITS_PER_SAMPLE = 16
+	WAV_FORMAT_PCM      = 1
+)
+
+func NewWavWriter(path string, rate int, channels int) (*WavWriter, error) {
+	if channels <= 0 {
+		return nil, errors.New("audio: a wav needs at least one channel")
+	}
+
+	file, err := os.Create(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	w := &WavWriter{
+		file:     file,
+		out:      bufio.NewWriter(file),
+		rate:     rate,
+		channels: channels,
+	}
+
+	err = w.writeHeader()
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *WavWriter) writeHeader() error {
+	var header [WAV_HEADER_SIZE]byte
+
+	block_align := w.channels * WAV_BITS_PER_SAMPLE / 8
+
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], WAV_HEADER_SIZE-8+w.data_size)
+	copy(header[8:], "WAVE")
+
+	copy(header[12:], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	binary.LittleEndian.PutUint16(header[20:], WAV_FORMAT_PCM)
+	binary.LittleEndian.PutUint16(header[22:], uint16(w.channels))
+	binary.LittleEndian.PutUint32(header[24:], uint32(w.rate))
+	binary.LittleEndian.PutUint32(header[28:], uint32(w.rate*block_align))
+	binary.LittleEndian.PutUint16(header[32:], uint16(block_align))
+	binary.LittleEndian.PutUint16(header[34:], WAV_BITS_PER_SAMPLE)
+
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], w.data_size)
+
+	_, err := w.out.Write(header[:])
+
+	return err
+}
+
+/*
+ * Write samples between -1.0 and 1.0. With more than one channel they're
+ * interleaved, so @samples should hold whole frames
+ */
+func (w *WavWriter) WriteSamples(samples []float32) error {
+	var buf [2]byte
+
+	for _, s := range samples {
+		s = min(max(s, -1.0), 1.0)
+
+		binary.LittleEndian.PutUint16(buf[:], uint16(int16(s*32767)))
+
+		_, err := w.out.Write(buf[:])
+
+		if err != nil {
+			return err
+		}
+	}
+
+	w.data_size += uint32(len(samples) * 2)
+
+	return nil
+}
+
+func (w *WavWriter) Channels() int {
+	return w.channels
+}
+
+/*
+ * Fill in the sizes and close the file
+ */
+func (w *WavWriter) Close() error {
+	err := w.out.Flush()
+
+	if err != nil {
+		w.file.Close()
+		return err
+	}
+
+	_, err = w.file.Seek(0, 0)
+
+	if err == nil {
+		w.out.Reset(w.file)
+		err = w.writeHeader()
+	}
+
+	if err == nil {
+		err = w.out.Flush()
+	}
+
+	cerr := w.file.Close()
+
+	if err != nil {
+		return err
+	}
+
+	return cerr
+}
+
+/*
+ * Backend that writes everything into a .wav instead of playing it
+ */
+type Wav struct {
+	writer *WavWriter
+}
+
+func NewWav(path string, rate int) (*Wav, error) {
+	writer, err := NewWavWriter(path, rate, 1)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Wav{
+		writer: writer,
+	}, nil
+}
+
+func (w *Wav) SampleRate() int {
+	return w.writer.rate
+}
+
+func (w *Wav) QueueSamples(samples []float32) error {
+	return w.writer.WriteSamples(samples)
+}
+
+func (w *Wav) QueuedSamples() int {
+	return -1
+}
+
+func (w *Wav) Close() error {
+	return w.writer.Close()
+}
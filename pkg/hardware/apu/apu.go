@@ -0,0 +1,305 @@
+package apu
+
+import (
+	"errors"
+
+	"github.com/beakeyz/gones-emu/pkg/debug"
+	"github.com/beakeyz/gones-emu/pkg/hardware/cpu"
+	"github.com/beakeyz/gones-emu/pkg/hardware/region"
+)
+
+/*
+ * The audio half of the 2A03
+ *
+ * Five channels (two pulses, a triangle, noise and the DMC), mixed into a
+ * single output. It runs off the CPU clock, so it gets clocked once for
+ * every CPU cycle
+ *
+ * See: https://www.nesdev.org/wiki/APU
+ */
+
+const (
+	APU_START_ADDR = 0x4000
+	APU_END_ADDR   = 0x4017
+
+	APU_PULSE1   = 0x4000
+	APU_PULSE2   = 0x4004
+	APU_TRIANGLE = 0x4008
+	APU_NOISE    = 0x400c
+	APU_DMC      = 0x4010
+	APU_STATUS   = 0x4015
+	APU_FRAME    = 0x4017
+
+	/* $4015 bits */
+	APU_STATUS_PULSE1    = 0x01
+	APU_STATUS_PULSE2    = 0x02
+	APU_STATUS_TRIANGLE  = 0x04
+	APU_STATUS_NOISE     = 0x08
+	APU_STATUS_DMC       = 0x10
+	APU_STATUS_FRAME_IRQ = 0x40
+	APU_STATUS_DMC_IRQ   = 0x80
+)
+
+/*
+ * Indices for the channels, for anyone that wants to look at them
+ * separately
+ */
+type Channel int
+
+const (
+	CHANNEL_PULSE1 Channel = iota
+	CHANNEL_PULSE2
+	CHANNEL_TRIANGLE
+	CHANNEL_NOISE
+	CHANNEL_DMC
+	CHANNEL_COUNT
+)
+
+func (ch Channel) String() string {
+	switch ch {
+	case CHANNEL_PULSE1:
+		return "pulse1"
+	case CHANNEL_PULSE2:
+		return "pulse2"
+	case CHANNEL_TRIANGLE:
+		return "triangle"
+	case CHANNEL_NOISE:
+		return "noise"
+	case CHANNEL_DMC:
+		return "dmc"
+	}
+
+	return "unknown"
+}
+
+type APU struct {
+	cpu cpu.CPU
+
+	pulse1   *pulse
+	pulse2   *pulse
+	triangle *triangle
+	noise    *noise
+	dmc      *dmc
+
+	frame *frameCounter
+
+	/* CPU cycles since power on. The pulses only run on every other one */
+	cycles uint64
+}
+
+func New(c cpu.CPU) *APU {
+	return &APU{
+		cpu:      c,
+		pulse1:   newPulse(true),
+		pulse2:   newPulse(false),
+		triangle: newTriangle(),
+		noise:    newNoise(),
+		dmc:      newDmc(c),
+		frame:    newFrameCounter(c),
+	}
+}
+
+/*
+ * PAL has its own noise and DMC rate tables, and a slower frame counter
+ */
+func (apu *APU) SetRegion(r region.Region) {
+	apu.noise.setRegion(r)
+	apu.dmc.setRegion(r)
+	apu.frame.setRegion(r)
+}
+
+/*
+ * What the reset button does. Everything goes quiet, like a write of 0 to
+ * $4015, and the frame IRQ gets acknowledged
+ */
+func (apu *APU) Reset() {
+	apu.writeStatus(0)
+	apu.dmc.reset()
+	apu.frame.clearIrq()
+}
+
+/*
+ * Do a single CPU cycle worth of APU
+ */
+func (apu *APU) Clock() {
+	apu.frame.clock(apu)
+
+	apu.triangle.clockTimer()
+	apu.noise.clockTimer()
+	apu.dmc.clockTimer()
+
+	if (apu.cycles & 1) != 0 {
+		apu.pulse1.clockTimer()
+		apu.pulse2.clockTimer()
+	}
+
+	apu.cycles++
+}
+
+/*
+ * Envelopes and the triangle's linear counter
+ */
+func (apu *APU) clockQuarterFrame() {
+	apu.pulse1.env.clock()
+	apu.pulse2.env.clock()
+	apu.triangle.clockLinear()
+	apu.noise.env.clock()
+}
+
+/*
+ * Length counters and sweeps
+ */
+func (apu *APU) clockHalfFrame() {
+	apu.pulse1.length.clock()
+	apu.pulse2.length.clock()
+	apu.triangle.length.clock()
+	apu.noise.length.clock()
+
+	apu.pulse1.clockSweep()
+	apu.pulse2.clockSweep()
+}
+
+/*
+ * The raw output of a single channel. 0-15 for everything but the DMC,
+ * which goes up to 127
+ */
+func (apu *APU) ChannelOutput(ch Channel) uint8 {
+	switch ch {
+	case CHANNEL_PULSE1:
+		return apu.pulse1.output()
+	case CHANNEL_PULSE2:
+		return apu.pulse2.output()
+	case CHANNEL_TRIANGLE:
+		return apu.triangle.output()
+	case CHANNEL_NOISE:
+		return apu.noise.output()
+	case CHANNEL_DMC:
+		return apu.dmc.output()
+	}
+
+	return 0
+}
+
+/*
+ * A single channel going through the mixer on its own, so it sits at the
+ * level it has in the mixed output
+ */
+func (apu *APU) ChannelMix(ch Channel) float32 {
+	out := apu.ChannelOutput(ch)
+
+	switch ch {
+	case CHANNEL_PULSE1, CHANNEL_PULSE2:
+		return mix(out, 0, 0, 0, 0)
+	case CHANNEL_TRIANGLE:
+		return mix(0, 0, out, 0, 0)
+	case CHANNEL_NOISE:
+		return mix(0, 0, 0, out, 0)
+	case CHANNEL_DMC:
+		return mix(0, 0, 0, 0, out)
+	}
+
+	return 0
+}
+
+/*
+ * The mixed output right now, between 0.0 and 1.0
+ */
+func (apu *APU) Output() float32 {
+	return mix(
+		apu.pulse1.output(),
+		apu.pulse2.output(),
+		apu.triangle.output(),
+		apu.noise.output(),
+		apu.dmc.output())
+}
+
+func (apu *APU) readStatus() uint8 {
+	var status uint8
+
+	if apu.pulse1.length.active() {
+		status |= APU_STATUS_PULSE1
+	}
+
+	if apu.pulse2.length.active() {
+		status |= APU_STATUS_PULSE2
+	}
+
+	if apu.triangle.length.active() {
+		status |= APU_STATUS_TRIANGLE
+	}
+
+	if apu.noise.length.active() {
+		status |= APU_STATUS_NOISE
+	}
+
+	if apu.dmc.active() {
+		status |= APU_STATUS_DMC
+	}
+
+	if apu.frame.irq_flag {
+		status |= APU_STATUS_FRAME_IRQ
+	}
+
+	if apu.dmc.irq_flag {
+		status |= APU_STATUS_DMC_IRQ
+	}
+
+	// Reading the status acknowledges the frame IRQ (but not the DMC one)
+	apu.frame.clearIrq()
+
+	return status
+}
+
+func (apu *APU) writeStatus(value uint8) {
+	apu.pulse1.length.setEnabled((value & APU_STATUS_PULSE1) != 0)
+	apu.pulse2.length.setEnabled((value & APU_STATUS_PULSE2) != 0)
+	apu.triangle.length.setEnabled((value & APU_STATUS_TRIANGLE) != 0)
+	apu.noise.length.setEnabled((value & APU_STATUS_NOISE) != 0)
+	apu.dmc.setEnabled((value & APU_STATUS_DMC) != 0)
+}
+
+/*
+ * Everything but $4015 is write only, and reads of those give open bus
+ */
+func (apu *APU) Read(addr uint16, value *uint8) error {
+	if addr != APU_STATUS {
+		return errors.New("apu: register is write only")
+	}
+
+	*value = apu.readStatus()
+
+	return nil
+}
+
+func (apu *APU) Write(addr uint16, value uint8) error {
+	debug.Log("(APU) Writing 0x%x at %x\n", value, addr)
+
+	switch {
+	case addr >= APU_PULSE1 && addr < APU_PULSE2:
+		apu.pulse1.write(addr-APU_PULSE1, value)
+	case addr >= APU_PULSE2 && addr < APU_TRIANGLE:
+		apu.pulse2.write(addr-APU_PULSE2, value)
+	case addr >= APU_TRIANGLE && addr < APU_NOISE:
+		apu.triangle.write(addr-APU_TRIANGLE, value)
+	case addr >= APU_NOISE && addr < APU_DMC:
+		apu.noise.write(addr-APU_NOISE, value)
+	case addr >= APU_DMC && addr < APU_DMC+4:
+		apu.dmc.write(addr-APU_DMC, value)
+	case addr == APU_STATUS:
+		apu.writeStatus(value)
+	case addr == APU_FRAME:
+		apu.frame.write(value, (apu.cycles&1) != 0)
+	default:
+		return errors.New("apu: write to an unmapped register")
+	}
+
+	return nil
+}
+
+func (apu *APU) StartAddr() uint16 {
+	return APU_START_ADDR
+}
+
+func (apu *APU) EndAddr() uint16 {
+	return APU_END_ADDR
+}
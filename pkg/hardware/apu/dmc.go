@@ -0,0 +1,216 @@
+package apu
+
+import (
+	"github.com/beakeyz/gones-emu/pkg/hardware/cpu"
+	"github.com/beakeyz/gones-emu/pkg/hardware/region"
+)
+
+/*
+ * Delta modulation channel ($4010-$4013)
+ *
+ * Plays 1 bit delta encoded samples straight out of CPU memory. Every bit
+ * moves the 7 bit output level up or down by 2. Samples get fetched a
+ * byte at a time with DMA, which stalls the CPU for a couple of cycles.
+ * It can also raise an IRQ when a sample is done
+ *
+ * See: https://www.nesdev.org/wiki/APU_DMC
+ */
+
+/* Output rates, in CPU cycles per bit */
+var dmcRates = map[region.Region][16]uint16{
+	region.REGION_NTSC: {428, 380, 340, 320, 286, 254, 226, 214, 190, 160, 142, 128, 106, 84, 72, 54},
+	region.REGION_PAL:  {398, 354, 316, 298, 276, 236, 210, 198, 176, 148, 132, 118, 98, 78, 66, 50},
+}
+
+type dmc struct {
+	cpu   cpu.CPU
+	rates *[16]uint16
+
+	irq_enabled bool
+	irq_flag    bool
+	loop        bool
+	period      uint16
+	timer       uint16
+
+	/* Output unit */
+	level          uint8
+	shift          uint8
+	bits_remaining uint8
+	silence        bool
+
+	/* Memory reader */
+	sample_addr     uint16
+	sample_length   uint16
+	current_addr    uint16
+	bytes_remaining uint16
+	buffer          uint8
+	buffer_full     bool
+	fetching        bool
+}
+
+func newDmc(c cpu.CPU) *dmc {
+	d := &dmc{
+		cpu:            c,
+		bits_remaining: 8,
+		silence:        true,
+	}
+
+	d.setRegion(region.REGION_NTSC)
+	d.period = d.rates[0]
+
+	return d
+}
+
+func (d *dmc) setRegion(r region.Region) {
+	table, ok := dmcRates[r]
+
+	if !ok {
+		table = dmcRates[region.REGION_NTSC]
+	}
+
+	d.rates = &table
+}
+
+func (d *dmc) write(reg uint16, value uint8) {
+	switch reg {
+	case 0:
+		d.irq_enabled = (value & 0x80) != 0
+		d.loop = (value & 0x40) != 0
+		d.period = d.rates[value&0x0f]
+
+		if !d.irq_enabled {
+			d.clearIrq()
+		}
+	case 1:
+		d.level = value & 0x7f
+	case 2:
+		d.sample_addr = 0xc000 + uint16(value)*64
+	case 3:
+		d.sample_length = uint16(value)*16 + 1
+	}
+}
+
+func (d *dmc) clearIrq() {
+	d.irq_flag = false
+
+	if d.cpu != nil {
+		d.cpu.ReleaseIrq(cpu.IRQ_SOURCE_DMC)
+	}
+}
+
+/*
+ * Stop playing. The CPU drops any fetch we were waiting on when it
+ * resets, so don't wait for it either
+ */
+func (d *dmc) reset() {
+	d.setEnabled(false)
+	d.fetching = false
+}
+
+func (d *dmc) restart() {
+	d.current_addr = d.sample_addr
+	d.bytes_remaining = d.sample_length
+}
+
+/*
+ * $4015 bit 4
+ */
+func (d *dmc) setEnabled(enabled bool) {
+	d.clearIrq()
+
+	if !enabled {
+		d.bytes_remaining = 0
+		return
+	}
+
+	if d.bytes_remaining == 0 {
+		d.restart()
+		d.fetch()
+	}
+}
+
+func (d *dmc) active() bool {
+	return d.bytes_remaining > 0
+}
+
+/*
+ * Get the next sample byte if the buffer's empty. The CPU does the actual
+ * read once it's stalled, and hands the byte back to us
+ */
+func (d *dmc) fetch() {
+	if d.buffer_full || d.fetching || d.bytes_remaining == 0 || d.cpu == nil {
+		return
+	}
+
+	d.fetching = true
+	d.cpu.StartDmcDma(d.current_addr, d.fetched)
+}
+
+func (d *dmc) fetched(value uint8) {
+	d.fetching = false
+	d.buffer = value
+	d.buffer_full = true
+
+	// Wraps around to $8000, not $0000
+	if d.current_addr == 0xffff {
+		d.current_addr = 0x8000
+	} else {
+		d.current_addr++
+	}
+
+	d.bytes_remaining--
+
+	if d.bytes_remaining > 0 {
+		return
+	}
+
+	if d.loop {
+		d.restart()
+	} else if d.irq_enabled {
+		d.irq_flag = true
+		d.cpu.RaiseIrq(cpu.IRQ_SOURCE_DMC)
+	}
+}
+
+/*
+ * Clocked every CPU cycle
+ */
+func (d *dmc) clockTimer() {
+	if d.timer > 0 {
+		d.timer--
+		return
+	}
+
+	d.timer = d.period - 1
+
+	if !d.silence {
+		if (d.shift & 0x01) != 0 {
+			if d.level <= 125 {
+				d.level += 2
+			}
+		} else if d.level >= 2 {
+			d.level -= 2
+		}
+	}
+
+	d.shift >>= 1
+	d.bits_remaining--
+
+	if d.bits_remaining > 0 {
+		return
+	}
+
+	// Start a new output cycle with whatever's in the buffer
+	d.bits_remaining = 8
+	d.silence = !d.buffer_full
+
+	if d.buffer_full {
+		d.shift = d.buffer
+		d.buffer_full = false
+		d.fetch()
+	}
+}
+
+func (d *dmc) output() uint8 {
+	return d.level
+}
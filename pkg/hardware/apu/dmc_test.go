@@ -0,0 +1,147 @@
+package apu
+
+import (
+	"testing"
+
+	"github.com/beakeyz/gones-emu/pkg/hardware/cpu"
+)
+
+/*
+ * Just enough of a CPU for the DMC: it writes down the sample fetches
+ * and the IRQ line. Anything else panics
+ */
+type dmcCpu struct {
+	cpu.CPU
+
+	fetches []uint16
+	done    func(value uint8)
+	irq     cpu.IrqSource
+}
+
+func (c *dmcCpu) StartDmcDma(addr uint16, done func(value uint8)) {
+	c.fetches = append(c.fetches, addr)
+	c.done = done
+}
+
+func (c *dmcCpu) RaiseIrq(source cpu.IrqSource) {
+	c.irq |= source
+}
+
+func (c *dmcCpu) ReleaseIrq(source cpu.IrqSource) {
+	c.irq &= ^source
+}
+
+/*
+ * Clock the APU and answer every fetch right away, until @n bytes went
+ * through
+ */
+func (c *dmcCpu) run(apu *APU, n int) {
+	for len(c.fetches) < n {
+		if c.done != nil {
+			done := c.done
+			c.done = nil
+			done(0x55)
+		}
+
+		apu.Clock()
+	}
+
+	if c.done != nil {
+		done := c.done
+		c.done = nil
+		done(0x55)
+	}
+}
+
+/*
+ * A sample at the very top of memory keeps going at $8000
+ */
+func TestDmcAddressWrap(t *testing.T) {
+	c := &dmcCpu{}
+	apu := New(c)
+
+	// Keep the frame IRQ out of it
+	apu.Write(APU_FRAME, FRAME_COUNTER_INHIBIT)
+
+	// IRQ on, fastest rate, sample at $FFC0, 65 bytes long
+	apu.Write(APU_DMC, 0x8f)
+	apu.Write(APU_DMC+2, 0xff)
+	apu.Write(APU_DMC+3, 0x04)
+	apu.Write(APU_STATUS, APU_STATUS_DMC)
+
+	c.run(apu, 65)
+
+	for i, addr := range c.fetches {
+		want := uint16(0xffc0 + i)
+
+		if i == 64 {
+			want = 0x8000
+		}
+
+		if addr != want {
+			t.Errorf("fetch %d: $%04x, want $%04x", i, addr, want)
+		}
+	}
+
+	var status uint8
+
+	apu.Read(APU_STATUS, &status)
+
+	if c.irq != cpu.IRQ_SOURCE_DMC || (status&APU_STATUS_DMC_IRQ) == 0 || (status&APU_STATUS_DMC) != 0 {
+		t.Errorf("after the last byte: irq=%x status=%02x", c.irq, status)
+	}
+
+	// Acknowledged by $4015 writes, not reads
+	apu.Write(APU_STATUS, 0)
+
+	if c.irq != 0 || apu.dmc.irq_flag {
+		t.Errorf("$4015 write didn't acknowledge the IRQ")
+	}
+}
+
+func TestDmcLoop(t *testing.T) {
+	c := &dmcCpu{}
+	apu := New(c)
+
+	// Looping, sample at $C040, 17 bytes long
+	apu.Write(APU_DMC, 0x4f)
+	apu.Write(APU_DMC+2, 0x01)
+	apu.Write(APU_DMC+3, 0x01)
+	apu.Write(APU_STATUS, APU_STATUS_DMC)
+
+	c.run(apu, 20)
+
+	if c.fetches[16] != 0xc050 || c.fetches[17] != 0xc040 || c.fetches[19] != 0xc042 {
+		t.Errorf("loop went %04x, %04x ... %04x", c.fetches[16], c.fetches[17], c.fetches[19])
+	}
+
+	if c.irq != 0 {
+		t.Errorf("looping samples don't raise an IRQ")
+	}
+}
+
+/*
+ * The output level moves by 2 for every bit, and stays within 0-127
+ */
+func TestDmcOutput(t *testing.T) {
+	c := &dmcCpu{}
+	apu := New(c)
+
+	apu.Write(APU_DMC, 0x0f)
+	apu.Write(APU_DMC+1, 0x7e)
+	apu.Write(APU_DMC+3, 0x01)
+	apu.Write(APU_STATUS, APU_STATUS_DMC)
+
+	// $55 goes up, down, up, down... so it sits near the top without going over
+	c.run(apu, 4)
+
+	if level := apu.ChannelOutput(CHANNEL_DMC); level < 0x7c || level > 0x7f {
+		t.Errorf("level %02x", level)
+	}
+
+	apu.Write(APU_DMC+1, 0x83)
+
+	if level := apu.ChannelOutput(CHANNEL_DMC); level != 0x03 {
+		t.Errorf("$4011 loaded %02x, want 03", level)
+	}
+}
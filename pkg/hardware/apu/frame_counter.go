@@ -0,0 +1,185 @@
+package apu
+
+import (
+	"github.com/beakeyz/gones-emu/pkg/hardware/cpu"
+	"github.com/beakeyz/gones-emu/pkg/hardware/region"
+)
+
+/*
+ * Frame counter ($4017)
+ *
+ * Clocks the envelopes, length counters and sweeps at roughly 240 Hz. It
+ * has two sequences:
+ *
+ *   4-step: Q, Q+H, Q, Q+H+IRQ (unless inhibited)
+ *   5-step: Q, Q+H, Q, -, Q+H (never an IRQ)
+ *
+ * The steps land in between CPU cycles, so the positions here are the CPU
+ * cycle (counted from the reset) the step happens on.
+ *
+ * See: https://www.nesdev.org/wiki/APU_Frame_Counter
+ */
+
+const (
+	FRAME_COUNTER_FIVE_STEP = 0x80
+	FRAME_COUNTER_INHIBIT   = 0x40
+)
+
+type frameSequence struct {
+	/* CPU cycles of the 4 steps that clock something */
+	steps [4]int
+	/* Amount of CPU cycles before the sequence starts over */
+	length int
+}
+
+type frameTiming struct {
+	four frameSequence
+	five frameSequence
+}
+
+var frameTimings = map[region.Region]frameTiming{
+	region.REGION_NTSC: {
+		four: frameSequence{steps: [4]int{7457, 14913, 22371, 29829}, length: 29830},
+		five: frameSequence{steps: [4]int{7457, 14913, 22371, 37281}, length: 37282},
+	},
+	region.REGION_PAL: {
+		four: frameSequence{steps: [4]int{8313, 16627, 24939, 33253}, length: 33254},
+		five: frameSequence{steps: [4]int{8313, 16627, 24939, 41565}, length: 41566},
+	},
+}
+
+type frameCounter struct {
+	cpu    cpu.CPU
+	timing frameTiming
+
+	five_step bool
+	inhibit   bool
+	irq_flag  bool
+
+	/* Where we are in the sequence */
+	cycle int
+	/* CPU cycles until a $4017 write resets the sequence */
+	reset_delay int
+}
+
+func newFrameCounter(c cpu.CPU) *frameCounter {
+	fc := &frameCounter{
+		cpu: c,
+	}
+
+	fc.setRegion(region.REGION_NTSC)
+
+	return fc
+}
+
+/*
+ * Dendy runs the NTSC sequence, on its slower clock
+ */
+func (fc *frameCounter) setRegion(r region.Region) {
+	timing, ok := frameTimings[r]
+
+	if !ok {
+		timing = frameTimings[region.REGION_NTSC]
+	}
+
+	fc.timing = timing
+}
+
+func (fc *frameCounter) sequence() *frameSequence {
+	if fc.five_step {
+		return &fc.timing.five
+	}
+
+	return &fc.timing.four
+}
+
+func (fc *frameCounter) setIrq() {
+	if fc.inhibit {
+		return
+	}
+
+	fc.irq_flag = true
+
+	if fc.cpu != nil {
+		fc.cpu.RaiseIrq(cpu.IRQ_SOURCE_FRAME_COUNTER)
+	}
+}
+
+func (fc *frameCounter) clearIrq() {
+	fc.irq_flag = false
+
+	if fc.cpu != nil {
+		fc.cpu.ReleaseIrq(cpu.IRQ_SOURCE_FRAME_COUNTER)
+	}
+}
+
+/*
+ * $4017 writes. The new mode applies right away, but the sequence only
+ * restarts 3 or 4 CPU cycles later, depending on whether we're in the
+ * middle of an APU cycle or not. @odd tells which one it is
+ */
+func (fc *frameCounter) write(value uint8, odd bool) {
+	fc.five_step = (value & FRAME_COUNTER_FIVE_STEP) != 0
+	fc.inhibit = (value & FRAME_COUNTER_INHIBIT) != 0
+
+	if fc.inhibit {
+		fc.clearIrq()
+	}
+
+	fc.reset_delay = 3
+
+	if odd {
+		fc.reset_delay = 4
+	}
+}
+
+/*
+ * Do a CPU cycle worth of frame counter
+ */
+func (fc *frameCounter) clock(apu *APU) {
+	if fc.reset_delay > 0 {
+		fc.reset_delay--
+
+		if fc.reset_delay == 0 {
+			fc.cycle = 0
+
+			// Going into 5-step mode clocks everything right away
+			if fc.five_step {
+				apu.clockQuarterFrame()
+				apu.clockHalfFrame()
+			}
+
+			return
+		}
+	}
+
+	seq := fc.sequence()
+
+	fc.cycle++
+
+	switch fc.cycle {
+	case seq.steps[0], seq.steps[2]:
+		apu.clockQuarterFrame()
+	case seq.steps[1]:
+		apu.clockQuarterFrame()
+		apu.clockHalfFrame()
+	case seq.steps[3]:
+		apu.clockQuarterFrame()
+		apu.clockHalfFrame()
+
+		if !fc.five_step {
+			fc.setIrq()
+		}
+	case seq.steps[3] - 1, 0:
+		// The IRQ flag gets set on three cycles in a row: the one before
+		// the last step, the step itself and the first cycle after the
+		// wrap. Acknowledging it in between doesn't stick
+		if !fc.five_step {
+			fc.setIrq()
+		}
+	}
+
+	if fc.cycle >= seq.length-1 {
+		fc.cycle = -1
+	}
+}
@@ -0,0 +1,211 @@
+package apu
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/beakeyz/gones-emu/pkg/hardware/region"
+)
+
+/*
+ * Everything the frame counter did, by the CPU cycle (counted from
+ * when we started watching) it happened on
+ */
+type frameEvents struct {
+	quarter []int
+	half    []int
+	irq     []int
+}
+
+/*
+ * Clock @apu for @cycles CPU cycles and write down what the frame
+ * counter does. Pulse 1's envelope and length counter show the quarter
+ * and half frames. The IRQ flag gets acknowledged right after every
+ * cycle, so we see every cycle that sets it
+ */
+func watchFrameCounter(apu *APU, cycles int) frameEvents {
+	var events frameEvents
+
+	env := &apu.pulse1.env
+	length := &apu.pulse1.length
+
+	for cycle := 1; cycle <= cycles; cycle++ {
+		*env = envelope{decay: 15}
+		*length = lengthCounter{enabled: true, counter: 100}
+
+		apu.Clock()
+
+		if env.decay != 15 {
+			events.quarter = append(events.quarter, cycle)
+		}
+
+		if length.counter != 100 {
+			events.half = append(events.half, cycle)
+		}
+
+		if apu.frame.irq_flag {
+			events.irq = append(events.irq, cycle)
+			apu.frame.clearIrq()
+		}
+	}
+
+	return events
+}
+
+/*
+ * @steps over and over, @length apart
+ */
+func repeatSteps(steps []int, length int, times int) []int {
+	var ret []int
+
+	for i := range times {
+		for _, step := range steps {
+			ret = append(ret, i*length+step)
+		}
+	}
+
+	return ret
+}
+
+func TestFrameCounterSteps(t *testing.T) {
+	tests := []struct {
+		name      string
+		region    region.Region
+		five_step bool
+		quarter   []int
+		half      []int
+		length    int
+		irq       []int
+	}{
+		{
+			"ntsc 4-step", region.REGION_NTSC, false,
+			[]int{7457, 14913, 22371, 29829}, []int{14913, 29829}, 29830,
+			[]int{29828, 29829, 29830},
+		},
+		{
+			"ntsc 5-step", region.REGION_NTSC, true,
+			[]int{7457, 14913, 22371, 37281}, []int{14913, 37281}, 37282,
+			nil,
+		},
+		{
+			"pal 4-step", region.REGION_PAL, false,
+			[]int{8313, 16627, 24939, 33253}, []int{16627, 33253}, 33254,
+			[]int{33252, 33253, 33254},
+		},
+		{
+			"pal 5-step", region.REGION_PAL, true,
+			[]int{8313, 16627, 24939, 41565}, []int{16627, 41565}, 41566,
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			apu := New(nil)
+			apu.SetRegion(test.region)
+			apu.frame.five_step = test.five_step
+
+			// Two whole sequences, and a bit of the third
+			events := watchFrameCounter(apu, 2*test.length+100)
+
+			if want := repeatSteps(test.quarter, test.length, 2); !slices.Equal(events.quarter, want) {
+				t.Errorf("quarter frames at %v, want %v", events.quarter, want)
+			}
+
+			if want := repeatSteps(test.half, test.length, 2); !slices.Equal(events.half, want) {
+				t.Errorf("half frames at %v, want %v", events.half, want)
+			}
+
+			// The one right after the second wrap doesn't come back around
+			if want := repeatSteps(test.irq, test.length, 2); !slices.Equal(events.irq, want) {
+				t.Errorf("IRQ set at %v, want %v", events.irq, want)
+			}
+		})
+	}
+}
+
+/*
+ * A $4017 write restarts the sequence 3 CPU cycles later, or 4 when it
+ * lands in between APU cycles. 5-step mode clocks everything right when
+ * that happens
+ */
+func TestFrameCounterWrite(t *testing.T) {
+	tests := []struct {
+		name  string
+		value uint8
+		odd   bool
+		/* Cycles after the write */
+		quarter []int
+		half    []int
+	}{
+		{"4-step, even", 0x00, false, []int{3 + 7457, 3 + 14913}, []int{3 + 14913}},
+		{"4-step, odd", 0x00, true, []int{4 + 7457, 4 + 14913}, []int{4 + 14913}},
+		{"5-step, even", FRAME_COUNTER_FIVE_STEP, false, []int{3, 3 + 7457, 3 + 14913}, []int{3, 3 + 14913}},
+		{"5-step, odd", FRAME_COUNTER_FIVE_STEP, true, []int{4, 4 + 7457, 4 + 14913}, []int{4, 4 + 14913}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			apu := New(nil)
+
+			// Get somewhere in the middle of the sequence, on the right cycle
+			watchFrameCounter(apu, 5000)
+
+			if test.odd {
+				watchFrameCounter(apu, 1)
+			}
+
+			apu.Write(APU_FRAME, test.value)
+
+			events := watchFrameCounter(apu, 20000)
+
+			if !slices.Equal(events.quarter, test.quarter) {
+				t.Errorf("quarter frames at %v, want %v", events.quarter, test.quarter)
+			}
+
+			if !slices.Equal(events.half, test.half) {
+				t.Errorf("half frames at %v, want %v", events.half, test.half)
+			}
+		})
+	}
+}
+
+/*
+ * The inhibit bit clears the flag and keeps it from coming back, and
+ * reading $4015 acknowledges it
+ */
+func TestFrameCounterIrq(t *testing.T) {
+	apu := New(nil)
+
+	watchFrameCounter(apu, 29827)
+	apu.Clock()
+
+	if !apu.frame.irq_flag {
+		t.Fatalf("no IRQ on cycle 29828")
+	}
+
+	var status uint8
+
+	apu.Read(APU_STATUS, &status)
+
+	if (status&APU_STATUS_FRAME_IRQ) == 0 || apu.frame.irq_flag {
+		t.Errorf("$4015 read: status %02x, flag %v", status, apu.frame.irq_flag)
+	}
+
+	// Set again on the next cycle
+	apu.Clock()
+
+	if !apu.frame.irq_flag {
+		t.Errorf("acknowledging on 29828 stuck")
+	}
+
+	apu.Write(APU_FRAME, FRAME_COUNTER_INHIBIT)
+
+	if apu.frame.irq_flag {
+		t.Errorf("inhibit didn't clear the flag")
+	}
+
+	if events := watchFrameCounter(apu, 2*29830); len(events.irq) != 0 {
+		t.Errorf("IRQ set at %v while inhibited", events.irq)
+	}
+}
@@ -0,0 +1,29 @@
+package apu
+
+/*
+ * The channels get mixed with resistors, not added up, so the output
+ * isn't linear. We use the lookup tables from the wiki, which are within
+ * a fraction of a percent of the real thing
+ *
+ * See: https://www.nesdev.org/wiki/APU_Mixer
+ */
+
+var pulseTable [31]float32
+var tndTable [203]float32
+
+func init() {
+	for i := 1; i < len(pulseTable); i++ {
+		pulseTable[i] = float32(95.52 / (8128.0/float64(i) + 100))
+	}
+
+	for i := 1; i < len(tndTable); i++ {
+		tndTable[i] = float32(163.67 / (24329.0/float64(i) + 100))
+	}
+}
+
+/*
+ * Mix the raw channel outputs into a 0.0 - 1.0 sample
+ */
+func mix(pulse1 uint8, pulse2 uint8, tri uint8, noise uint8, dmc uint8) float32 {
+	return pulseTable[pulse1+pulse2] + tndTable[3*uint16(tri)+2*uint16(noise)+uint16(dmc)]
+}
@@ -0,0 +1,62 @@
+package apu
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMixerTables(t *testing.T) {
+	tests := []struct {
+		name string
+		got  float32
+		want float64
+	}{
+		{"pulse silent", pulseTable[0], 0},
+		{"pulse 1", pulseTable[1], 95.52 / (8128.0 + 100)},
+		{"pulse full", pulseTable[30], 95.52 / (8128.0/30 + 100)},
+		{"tnd silent", tndTable[0], 0},
+		{"tnd 1", tndTable[1], 163.67 / (24329.0 + 100)},
+		{"tnd full", tndTable[202], 163.67 / (24329.0/202 + 100)},
+	}
+
+	for _, test := range tests {
+		if math.Abs(float64(test.got)-test.want) > 1e-6 {
+			t.Errorf("%s: got %f, want %f", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestMix(t *testing.T) {
+	if got := mix(0, 0, 0, 0, 0); got != 0 {
+		t.Errorf("silence: got %f", got)
+	}
+
+	// Everything at full blast comes out just shy of 1.0
+	if got := mix(15, 15, 15, 15, 127); got < 0.99 || got > 1.0 {
+		t.Errorf("full scale: got %f", got)
+	}
+
+	// The triangle counts 3 times, the noise twice and the DMC once
+	if mix(0, 0, 1, 0, 0) != mix(0, 0, 0, 0, 3) || mix(0, 0, 0, 1, 0) != mix(0, 0, 0, 0, 2) {
+		t.Errorf("tnd weights are off")
+	}
+
+	// Louder is louder, but it flattens out towards the top
+	prev := float32(0)
+	prev_step := float32(1)
+
+	for i := range uint8(16) {
+		out := mix(i, i, 0, 0, 0)
+		step := out - prev
+
+		if i > 0 && (step <= 0 || step > prev_step) {
+			t.Errorf("pulse %d: step %f after %f", i, step, prev_step)
+		}
+
+		prev = out
+
+		if i > 0 {
+			prev_step = step
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package apu
+
+import "github.com/beakeyz/gones-emu/pkg/hardware/region"
+
+/*
+ * Noise channel ($400C-$400F)
+ *
+ * A 15 bit linear feedback shift register. Mode 1 takes the feedback
+ * from bit 6 instead of bit 1, which gives a short, metallic sounding
+ * sequence
+ *
+ * See: https://www.nesdev.org/wiki/APU_Noise
+ */
+
+/* Timer periods, in CPU cycles */
+var noisePeriods = map[region.Region][16]uint16{
+	region.REGION_NTSC: {4, 8, 16, 32, 64, 96, 128, 160, 202, 254, 380, 508, 762, 1016, 2034, 4068},
+	region.REGION_PAL:  {4, 8, 14, 30, 60, 88, 118, 148, 188, 236, 354, 472, 708, 944, 1890, 3778},
+}
+
+type noise struct {
+	periods *[16]uint16
+	period  uint16
+	timer   uint16
+	mode    bool
+	shift   uint16
+
+	env    envelope
+	length lengthCounter
+}
+
+func newNoise() *noise {
+	n := &noise{
+		// The shift register starts out as 1, all 0 would get it stuck
+		shift: 1,
+	}
+
+	n.setRegion(region.REGION_NTSC)
+	n.period = n.periods[0]
+
+	return n
+}
+
+/*
+ * Dendy uses the NTSC APU tables
+ */
+func (n *noise) setRegion(r region.Region) {
+	table, ok := noisePeriods[r]
+
+	if !ok {
+		table = noisePeriods[region.REGION_NTSC]
+	}
+
+	n.periods = &table
+}
+
+func (n *noise) write(reg uint16, value uint8) {
+	switch reg {
+	case 0:
+		n.length.halt = (value & 0x20) != 0
+		n.env.write(value)
+	case 2:
+		n.mode = (value & 0x80) != 0
+		n.period = n.periods[value&0x0f]
+	case 3:
+		n.length.load(value >> 3)
+		n.env.start = true
+	}
+}
+
+/*
+ * Clocked every CPU cycle, since the period table is in CPU cycles
+ */
+func (n *noise) clockTimer() {
+	if n.timer > 0 {
+		n.timer--
+		return
+	}
+
+	n.timer = n.period - 1
+
+	tap := uint16(1)
+
+	if n.mode {
+		tap = 6
+	}
+
+	feedback := (n.shift & 0x01) ^ ((n.shift >> tap) & 0x01)
+
+	n.shift = (n.shift >> 1) | (feedback << 14)
+}
+
+func (n *noise) output() uint8 {
+	if !n.length.active() || (n.shift&0x01) != 0 {
+		return 0
+	}
+
+	return n.env.volume()
+}
@@ -0,0 +1,57 @@
+package apu
+
+import (
+	"testing"
+)
+
+/*
+ * The shift register, worked out the long way
+ */
+func noiseSequence(mode bool, steps int) uint16 {
+	shift := uint16(1)
+
+	for range steps {
+		var feedback uint16
+
+		if mode {
+			feedback = (shift ^ shift>>6) & 0x01
+		} else {
+			feedback = (shift ^ shift>>1) & 0x01
+		}
+
+		shift = shift>>1 | feedback<<14
+	}
+
+	return shift
+}
+
+func TestNoise(t *testing.T) {
+	tests := []struct {
+		name string
+		/* $400E, nothing written if it's 0 */
+		value  uint8
+		period int
+	}{
+		// Before anything gets written, on the shortest period
+		{"power on", 0x00, 4},
+		{"period 3", 0x03, 32},
+		{"mode 1", 0x85, 96},
+	}
+
+	for _, test := range tests {
+		apu := New(nil)
+
+		if test.value != 0 {
+			apu.Write(APU_NOISE+2, test.value)
+		}
+
+		// The first clock shifts right away, then every period after that
+		for range 100 * test.period {
+			apu.noise.clockTimer()
+		}
+
+		if want := noiseSequence((test.value&0x80) != 0, 100); apu.noise.shift != want {
+			t.Errorf("%s: shift register %04x, want %04x", test.name, apu.noise.shift, want)
+		}
+	}
+}
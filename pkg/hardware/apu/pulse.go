@@ -0,0 +1,129 @@
+package apu
+
+/*
+ * Pulse channels ($4000-$4007)
+ *
+ * A square wave with 4 duty cycles, an envelope, a length counter and a
+ * sweep unit that can bend the period up or down on its own. The two
+ * channels are the same, except for how they negate the sweep
+ *
+ * See: https://www.nesdev.org/wiki/APU_Pulse
+ * See: https://www.nesdev.org/wiki/APU_Sweep
+ */
+
+var dutyTable = [4][8]uint8{
+	{0, 1, 0, 0, 0, 0, 0, 0},
+	{0, 1, 1, 0, 0, 0, 0, 0},
+	{0, 1, 1, 1, 1, 0, 0, 0},
+	{1, 0, 0, 1, 1, 1, 1, 1},
+}
+
+type pulse struct {
+	/* Pulse 1 negates with ones' complement, pulse 2 with two's */
+	ones_complement bool
+
+	duty     uint8
+	duty_pos uint8
+	period   uint16
+	timer    uint16
+
+	sweep_enabled bool
+	sweep_period  uint8
+	sweep_negate  bool
+	sweep_shift   uint8
+	sweep_divider uint8
+	sweep_reload  bool
+
+	env    envelope
+	length lengthCounter
+}
+
+func newPulse(ones_complement bool) *pulse {
+	return &pulse{
+		ones_complement: ones_complement,
+	}
+}
+
+func (p *pulse) write(reg uint16, value uint8) {
+	switch reg {
+	case 0:
+		p.duty = value >> 6
+		p.length.halt = (value & 0x20) != 0
+		p.env.write(value)
+	case 1:
+		p.sweep_enabled = (value & 0x80) != 0
+		p.sweep_period = (value >> 4) & 0x07
+		p.sweep_negate = (value & 0x08) != 0
+		p.sweep_shift = value & 0x07
+		p.sweep_reload = true
+	case 2:
+		p.period = (p.period & 0x0700) | uint16(value)
+	case 3:
+		p.period = (p.period & 0x00ff) | (uint16(value&0x07) << 8)
+		p.length.load(value >> 3)
+
+		// Writing the high byte restarts the note
+		p.duty_pos = 0
+		p.env.start = true
+	}
+}
+
+/*
+ * Clocked every APU cycle (every other CPU cycle)
+ */
+func (p *pulse) clockTimer() {
+	if p.timer > 0 {
+		p.timer--
+		return
+	}
+
+	p.timer = p.period
+	p.duty_pos = (p.duty_pos + 1) & 0x07
+}
+
+/*
+ * The period the sweep unit wants to move to. It's worked out all the
+ * time, since it can mute the channel even with the sweep disabled
+ */
+func (p *pulse) sweepTarget() uint16 {
+	change := p.period >> p.sweep_shift
+
+	if !p.sweep_negate {
+		return p.period + change
+	}
+
+	if p.ones_complement {
+		change++
+	}
+
+	if change > p.period {
+		return 0
+	}
+
+	return p.period - change
+}
+
+func (p *pulse) sweepMuting() bool {
+	return p.period < 8 || p.sweepTarget() > 0x7ff
+}
+
+func (p *pulse) clockSweep() {
+	if p.sweep_divider == 0 && p.sweep_enabled && p.sweep_shift > 0 && !p.sweepMuting() {
+		p.period = p.sweepTarget()
+	}
+
+	if p.sweep_divider == 0 || p.sweep_reload {
+		p.sweep_divider = p.sweep_period
+		p.sweep_reload = false
+	} else {
+		p.sweep_divider--
+	}
+}
+
+func (p *pulse) output() uint8 {
+	if !p.length.active() || p.sweepMuting() || dutyTable[p.duty][p.duty_pos] == 0 {
+		return 0
+	}
+
+	return p.env.volume()
+}
@@ -0,0 +1,86 @@
+package apu
+
+import (
+	"testing"
+)
+
+func TestSweepTarget(t *testing.T) {
+	tests := []struct {
+		name   string
+		pulse1 bool
+		period uint16
+		/* $4001 */
+		sweep  uint8
+		target uint16
+		muted  bool
+	}{
+		{"up", true, 0x400, 0x01, 0x600, false},
+		{"up too far", true, 0x600, 0x01, 0x900, true},
+		// Shift 0 doubles the period, so this mutes even with the sweep off
+		{"shift 0", false, 0x400, 0x00, 0x800, true},
+		{"shift 0, short", false, 0x3ff, 0x00, 0x7fe, false},
+		{"down, pulse 1", true, 0x100, 0x0a, 0x0bf, false},
+		{"down, pulse 2", false, 0x100, 0x0a, 0x0c0, false},
+		{"down to 0, pulse 1", true, 0x100, 0x08, 0x000, false},
+		{"down to 0, pulse 2", false, 0x100, 0x08, 0x000, false},
+		{"too low", true, 0x007, 0x0f, 0x006, true},
+		{"just high enough", false, 0x008, 0x0f, 0x008, false},
+	}
+
+	for _, test := range tests {
+		apu := New(nil)
+		apu.Write(APU_STATUS, APU_STATUS_PULSE1|APU_STATUS_PULSE2)
+
+		base := uint16(APU_PULSE2)
+		p := apu.pulse2
+
+		if test.pulse1 {
+			base = APU_PULSE1
+			p = apu.pulse1
+		}
+
+		// Constant volume 15, 75% duty so the first step is high
+		apu.Write(base, 0xdf)
+		apu.Write(base+1, test.sweep)
+		apu.Write(base+2, uint8(test.period))
+		apu.Write(base+3, 0x08|uint8(test.period>>8))
+
+		if got := p.sweepTarget(); got != test.target {
+			t.Errorf("%s: target %03x, want %03x", test.name, got, test.target)
+		}
+
+		if muted := p.output() == 0; muted != test.muted {
+			t.Errorf("%s: muted=%v, want %v", test.name, muted, test.muted)
+		}
+	}
+}
+
+func TestSweepUnit(t *testing.T) {
+	apu := New(nil)
+	apu.Write(APU_STATUS, APU_STATUS_PULSE2)
+
+	// Enabled, divider period 1, shift 1, going up
+	apu.Write(APU_PULSE2+1, 0x91)
+	apu.Write(APU_PULSE2+2, 0x00)
+	apu.Write(APU_PULSE2+3, 0x01)
+
+	// The divider starts out at 0, so the first half frame already moves it
+	want := []uint16{0x180, 0x180, 0x240, 0x240, 0x360, 0x360, 0x510, 0x510, 0x798, 0x798}
+
+	for i, w := range want {
+		apu.clockHalfFrame()
+
+		if apu.pulse2.period != w {
+			t.Errorf("half frame %d: period %03x, want %03x", i, apu.pulse2.period, w)
+		}
+	}
+
+	// $798 + $3CC is past $7FF, so it stops and the channel is muted
+	for range 4 {
+		apu.clockHalfFrame()
+	}
+
+	if apu.pulse2.period != 0x798 || !apu.pulse2.sweepMuting() {
+		t.Errorf("sweep went on to %03x", apu.pulse2.period)
+	}
+}
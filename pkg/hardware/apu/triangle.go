@@ -0,0 +1,82 @@
+package apu
+
+/*
+ * Triangle channel ($4008-$400B)
+ *
+ * Steps through a 32 step triangle shaped sequence. No volume control,
+ * but next to the length counter it has a linear counter, which allows
+ * for much finer note lengths
+ *
+ * See: https://www.nesdev.org/wiki/APU_Triangle
+ */
+
+var triangleSequence = [32]uint8{
+	15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0,
+	0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+}
+
+type triangle struct {
+	period  uint16
+	timer   uint16
+	seq_pos uint8
+
+	/* The control flag doubles as the length counter halt */
+	control        bool
+	linear_period  uint8
+	linear_counter uint8
+	linear_reload  bool
+
+	length lengthCounter
+}
+
+func newTriangle() *triangle {
+	return &triangle{}
+}
+
+func (t *triangle) write(reg uint16, value uint8) {
+	switch reg {
+	case 0:
+		t.control = (value & 0x80) != 0
+		t.length.halt = t.control
+		t.linear_period = value & 0x7f
+	case 2:
+		t.period = (t.period & 0x0700) | uint16(value)
+	case 3:
+		t.period = (t.period & 0x00ff) | (uint16(value&0x07) << 8)
+		t.length.load(value >> 3)
+		t.linear_reload = true
+	}
+}
+
+/*
+ * Unlike the others, the triangle timer runs at the CPU clock
+ */
+func (t *triangle) clockTimer() {
+	if t.timer > 0 {
+		t.timer--
+		return
+	}
+
+	t.timer = t.period
+
+	// The sequence just stops when either counter runs out, it doesn't go to 0
+	if t.length.active() && t.linear_counter > 0 {
+		t.seq_pos = (t.seq_pos + 1) & 0x1f
+	}
+}
+
+func (t *triangle) clockLinear() {
+	if t.linear_reload {
+		t.linear_counter = t.linear_period
+	} else if t.linear_counter > 0 {
+		t.linear_counter--
+	}
+
+	if !t.control {
+		t.linear_reload = false
+	}
+}
+
+func (t *triangle) output() uint8 {
+	return triangleSequence[t.seq_pos]
+}
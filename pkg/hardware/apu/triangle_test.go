@@ -0,0 +1,76 @@
+package apu
+
+import (
+	"testing"
+)
+
+func TestLinearCounter(t *testing.T) {
+	tests := []struct {
+		name string
+		/* $4008 */
+		value uint8
+		/* The counter after every quarter frame */
+		want []uint8
+	}{
+		{"counting down", 0x03, []uint8{3, 2, 1, 0, 0}},
+		// With the control flag set the reload flag never clears
+		{"control", 0x83, []uint8{3, 3, 3, 3, 3}},
+		{"zero", 0x00, []uint8{0, 0, 0}},
+	}
+
+	for _, test := range tests {
+		apu := New(nil)
+		apu.Write(APU_STATUS, APU_STATUS_TRIANGLE)
+		apu.Write(APU_TRIANGLE, test.value)
+		apu.Write(APU_TRIANGLE+3, 0x08)
+
+		for i, want := range test.want {
+			apu.clockQuarterFrame()
+
+			if got := apu.triangle.linear_counter; got != want {
+				t.Errorf("%s: quarter frame %d: counter %d, want %d", test.name, i, got, want)
+			}
+		}
+	}
+}
+
+/*
+ * The sequencer only moves while both counters are non-zero, and holds
+ * its output instead of dropping to 0
+ */
+func TestTriangleSequencer(t *testing.T) {
+	apu := New(nil)
+	apu.Write(APU_STATUS, APU_STATUS_TRIANGLE)
+	apu.Write(APU_TRIANGLE, 0x01)
+	apu.Write(APU_TRIANGLE+2, 0x00)
+	apu.Write(APU_TRIANGLE+3, 0x08)
+
+	// Linear counter is still 0, the reload happens on the quarter frame
+	for range 5 {
+		apu.triangle.clockTimer()
+	}
+
+	if apu.triangle.seq_pos != 0 {
+		t.Fatalf("moved without a linear count")
+	}
+
+	apu.clockQuarterFrame()
+
+	for range 5 {
+		apu.triangle.clockTimer()
+	}
+
+	if apu.triangle.seq_pos != 5 || apu.triangle.output() != 10 {
+		t.Errorf("at step %d, output %d, want 5 and 10", apu.triangle.seq_pos, apu.triangle.output())
+	}
+
+	apu.clockQuarterFrame()
+
+	for range 5 {
+		apu.triangle.clockTimer()
+	}
+
+	if apu.triangle.seq_pos != 5 || apu.triangle.output() != 10 {
+		t.Errorf("didn't hold at step 5, output %d: at step %d", apu.triangle.output(), apu.triangle.seq_pos)
+	}
+}
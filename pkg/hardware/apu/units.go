@@ -0,0 +1,106 @@
+package apu
+
+/*
+ * The bits and pieces the channels share. These get clocked by the frame
+ * counter: envelopes (and the triangle's linear counter) on every quarter
+ * frame, length counters and sweeps on every half frame
+ */
+
+/*
+ * What the 5 bit length index written to $4003/$4007/$400B/$400F turns
+ * into
+ */
+var lengthTable = [32]uint8{
+	10, 254, 20, 2, 40, 4, 80, 6, 160, 8, 60, 10, 14, 12, 26, 14,
+	12, 16, 24, 18, 48, 20, 96, 22, 192, 24, 72, 26, 16, 28, 32, 30,
+}
+
+/*
+ * Silences a channel once it runs out, unless it's halted
+ */
+type lengthCounter struct {
+	enabled bool
+	halt    bool
+	counter uint8
+}
+
+/*
+ * Only does anything while the channel is enabled in $4015
+ */
+func (lc *lengthCounter) load(index uint8) {
+	if lc.enabled {
+		lc.counter = lengthTable[index&0x1f]
+	}
+}
+
+func (lc *lengthCounter) setEnabled(enabled bool) {
+	lc.enabled = enabled
+
+	if !enabled {
+		lc.counter = 0
+	}
+}
+
+func (lc *lengthCounter) clock() {
+	if !lc.halt && lc.counter > 0 {
+		lc.counter--
+	}
+}
+
+func (lc *lengthCounter) active() bool {
+	return lc.counter > 0
+}
+
+/*
+ * Volume envelope. Either a constant volume, or a sawtooth that goes from
+ * 15 down to 0 (and optionally loops), one step every period+1 quarter
+ * frames
+ */
+type envelope struct {
+	start    bool
+	loop     bool
+	constant bool
+	/* Constant volume, or the divider period */
+	period  uint8
+	divider uint8
+	decay   uint8
+}
+
+/*
+ * The low 6 bits of $4000/$4004/$400C
+ */
+func (env *envelope) write(value uint8) {
+	env.loop = (value & 0x20) != 0
+	env.constant = (value & 0x10) != 0
+	env.period = value & 0x0f
+}
+
+func (env *envelope) clock() {
+	if env.start {
+		env.start = false
+		env.decay = 15
+		env.divider = env.period
+		return
+	}
+
+	if env.divider > 0 {
+		env.divider--
+		return
+	}
+
+	env.divider = env.period
+
+	if env.decay > 0 {
+		env.decay--
+	} else if env.loop {
+		env.decay = 15
+	}
+}
+
+func (env *envelope) volume() uint8 {
+	if env.constant {
+		return env.period
+	}
+
+	return env.decay
+}
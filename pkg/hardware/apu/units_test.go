@@ -0,0 +1,108 @@
+package apu
+
+import (
+	"testing"
+)
+
+/*
+ * The length table the way the wiki lays it out. Odd indices are mostly
+ * index-1, the even ones are note lengths at two different tempos
+ */
+func expectedLength(index uint8) uint8 {
+	notes := [2][8]uint8{
+		{10, 20, 40, 80, 160, 60, 14, 26},
+		{12, 24, 48, 96, 192, 72, 16, 32},
+	}
+
+	if index == 1 {
+		return 254
+	}
+
+	if (index & 0x01) != 0 {
+		return index - 1
+	}
+
+	return notes[index>>4][(index>>1)&0x07]
+}
+
+func TestLengthTable(t *testing.T) {
+	for index := range uint8(32) {
+		apu := New(nil)
+		apu.Write(APU_STATUS, APU_STATUS_NOISE)
+		apu.Write(APU_NOISE+3, index<<3)
+
+		if got := apu.noise.length.counter; got != expectedLength(index) {
+			t.Errorf("index %d: loaded %d, want %d", index, got, expectedLength(index))
+		}
+	}
+}
+
+func TestLengthCounter(t *testing.T) {
+	apu := New(nil)
+
+	// Disabled channels don't load anything
+	apu.Write(APU_PULSE1+3, 0x18)
+
+	if apu.pulse1.length.counter != 0 {
+		t.Errorf("disabled channel loaded %d", apu.pulse1.length.counter)
+	}
+
+	// Index 3 is a length of 2
+	apu.Write(APU_STATUS, APU_STATUS_PULSE1)
+	apu.Write(APU_PULSE1+3, 0x18)
+
+	var status uint8
+
+	for i, want := range []bool{true, true, false} {
+		apu.Read(APU_STATUS, &status)
+
+		if active := (status & APU_STATUS_PULSE1) != 0; active != want {
+			t.Errorf("half frame %d: active=%v, want %v", i, active, want)
+		}
+
+		apu.clockHalfFrame()
+	}
+
+	// Halted counters stay put
+	apu.Write(APU_PULSE1, 0x20)
+	apu.Write(APU_PULSE1+3, 0x18)
+	apu.clockHalfFrame()
+	apu.clockHalfFrame()
+
+	if apu.pulse1.length.counter != 2 {
+		t.Errorf("halted counter went to %d", apu.pulse1.length.counter)
+	}
+
+	// And disabling the channel zeroes it
+	apu.Write(APU_STATUS, 0)
+
+	if apu.pulse1.length.counter != 0 {
+		t.Errorf("disabling left %d", apu.pulse1.length.counter)
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	apu := New(nil)
+	apu.Write(APU_STATUS, APU_STATUS_NOISE)
+
+	// Decaying with a period of 1, so every other quarter frame
+	apu.Write(APU_NOISE, 0x01)
+	apu.Write(APU_NOISE+3, 0x08)
+
+	want := []uint8{15, 15, 14, 14, 13}
+
+	for i, w := range want {
+		apu.clockQuarterFrame()
+
+		if got := apu.noise.env.volume(); got != w {
+			t.Errorf("quarter frame %d: volume %d, want %d", i, got, w)
+		}
+	}
+
+	// Constant volume ignores all of that
+	apu.Write(APU_NOISE, 0x17)
+
+	if got := apu.noise.env.volume(); got != 7 {
+		t.Errorf("constant volume %d, want 7", got)
+	}
+}
@@ -0,0 +1,83 @@
+package controller
+
+/*
+ * The standard NES controller
+ *
+ * Inside is a 4021 shift register. While the strobe is high it keeps
+ * loading the state of the buttons, and once the strobe goes low every
+ * read shifts out the next one, in this order:
+ *
+ *   A, B, Select, Start, Up, Down, Left, Right
+ *
+ * After those eight an official pad keeps returning 1s
+ *
+ * See: https://www.nesdev.org/wiki/Standard_controller
+ */
+
+type Button uint8
+
+const (
+	BUTTON_A Button = (1 << iota)
+	BUTTON_B
+	BUTTON_SELECT
+	BUTTON_START
+	BUTTON_UP
+	BUTTON_DOWN
+	BUTTON_LEFT
+	BUTTON_RIGHT
+)
+
+type Controller struct {
+	/* What the player is holding right now */
+	buttons uint8
+	/* What's left to shift out */
+	shift  uint8
+	strobe bool
+}
+
+func New() *Controller {
+	return &Controller{}
+}
+
+func (ctl *Controller) SetButton(button Button, pressed bool) {
+	if pressed {
+		ctl.buttons |= uint8(button)
+	} else {
+		ctl.buttons &= ^uint8(button)
+	}
+
+	if ctl.strobe {
+		ctl.shift = ctl.buttons
+	}
+}
+
+func (ctl *Controller) IsPressed(button Button) bool {
+	return (ctl.buttons & uint8(button)) != 0
+}
+
+/*
+ * Bit 0 of a $4016 write. Going low latches the buttons
+ */
+func (ctl *Controller) setStrobe(strobe bool) {
+	ctl.strobe = strobe
+
+	if strobe {
+		ctl.shift = ctl.buttons
+	}
+}
+
+/*
+ * Shift out the next button. With the strobe high we just keep reading A
+ */
+func (ctl *Controller) read() uint8 {
+	if ctl.strobe {
+		return ctl.buttons & 1
+	}
+
+	bit := ctl.shift & 1
+
+	// The serial input is tied high, so 1s come in behind the buttons
+	ctl.shift = (ctl.shift >> 1) | 0x80
+
+	return bit
+}
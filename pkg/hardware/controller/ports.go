@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"github.com/beakeyz/gones-emu/pkg/hardware/comp"
+	"github.com/beakeyz/gones-emu/pkg/hardware/cpu"
+)
+
+/*
+ * The two controller ports, on $4016 and $4017
+ *
+ *   $4016 write: bit 0 is the strobe, for both ports
+ *   $4016 read:  bit 0 is the next button of port 1
+ *   $4017 read:  bit 0 is the next button of port 2
+ *
+ * $4017 writes are the APU frame counter, so those get passed on.
+ *
+ * Only the low bits get driven, the rest of the byte is open bus. That's
+ * whatever was last on the CPU data bus, which for the usual LDA $4016 is
+ * the high byte of the address. So games mostly see $40 or $41
+ *
+ * See: https://www.nesdev.org/wiki/Controller_reading
+ */
+
+const (
+	PORTS_START_ADDR = 0x4016
+	PORTS_END_ADDR   = 0x4017
+
+	PORT_1 = 0x4016
+	PORT_2 = 0x4017
+
+	/* Bits we actually drive on a read, the rest is open bus */
+	PORTS_DRIVEN_MASK = 0x1f
+)
+
+type Ports struct {
+	/* For the open bus bits */
+	cpu cpu.CPU
+
+	/* nil when nothing is plugged in */
+	controllers [2]*Controller
+
+	/* Gets the $4017 writes */
+	frameCounter comp.Component
+}
+
+/*
+ * Two controllers plugged in. @frameCounter is whoever handles $4017 writes
+ */
+func NewPorts(c cpu.CPU, frameCounter comp.Component) *Ports {
+	return &Ports{
+		cpu:          c,
+		controllers:  [2]*Controller{New(), New()},
+		frameCounter: frameCounter,
+	}
+}
+
+/*
+ * The controller in port @port (0 or 1), or nil if there's none
+ */
+func (ports *Ports) Controller(port int) *Controller {
+	if port < 0 || port >= len(ports.controllers) {
+		return nil
+	}
+
+	return ports.controllers[port]
+}
+
+/*
+ * Without a CPU to ask, assume the address was the last thing on the bus
+ */
+func (ports *Ports) openBus(addr uint16) uint8 {
+	if ports.cpu == nil {
+		return uint8(addr >> 8)
+	}
+
+	return ports.cpu.DataBus()
+}
+
+func (ports *Ports) Read(addr uint16, value *uint8) error {
+	var bit uint8
+
+	ctl := ports.controllers[addr-PORT_1]
+
+	// An empty port reads as 0
+	if ctl != nil {
+		bit = ctl.read()
+	}
+
+	*value = (ports.openBus(addr) & ^uint8(PORTS_DRIVEN_MASK)) | bit
+
+	return nil
+}
+
+func (ports *Ports) Write(addr uint16, value uint8) error {
+	if addr == PORT_2 {
+		if ports.frameCounter == nil {
+			return nil
+		}
+
+		return ports.frameCounter.Write(addr, value)
+	}
+
+	for _, ctl := range ports.controllers {
+		if ctl != nil {
+			ctl.setStrobe((value & 1) != 0)
+		}
+	}
+
+	return nil
+}
+
+func (ports *Ports) StartAddr() uint16 {
+	return PORTS_START_ADDR
+}
+
+func (ports *Ports) EndAddr() uint16 {
+	return PORTS_END_ADDR
+}
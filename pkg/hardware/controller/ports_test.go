@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/beakeyz/gones-emu/pkg/hardware/cpu"
+)
+
+/*
+ * Stands in for the APU on $4017 writes
+ */
+type frameCounterStub struct {
+	writes []uint8
+}
+
+func (fc *frameCounterStub) Read(addr uint16, value *uint8) error {
+	return nil
+}
+
+func (fc *frameCounterStub) Write(addr uint16, value uint8) error {
+	fc.writes = append(fc.writes, value)
+	return nil
+}
+
+func (fc *frameCounterStub) StartAddr() uint16 {
+	return PORT_2
+}
+
+func (fc *frameCounterStub) EndAddr() uint16 {
+	return PORT_2
+}
+
+/*
+ * A CPU that only knows what was last on its data bus
+ */
+type dataBusCpu struct {
+	cpu.CPU
+
+	data_bus uint8
+}
+
+func (c *dataBusCpu) DataBus() uint8 {
+	return c.data_bus
+}
+
+/*
+ * Read bit 0 of @addr @n times
+ */
+func readBits(ports *Ports, addr uint16, n int) []uint8 {
+	bits := make([]uint8, n)
+
+	for i := range bits {
+		var value uint8
+
+		ports.Read(addr, &value)
+		bits[i] = value & 1
+	}
+
+	return bits
+}
+
+func TestShiftOrder(t *testing.T) {
+	order := []Button{BUTTON_A, BUTTON_B, BUTTON_SELECT, BUTTON_START, BUTTON_UP, BUTTON_DOWN, BUTTON_LEFT, BUTTON_RIGHT}
+
+	tests := []struct {
+		name    string
+		pressed []Button
+	}{
+		{"nothing", nil},
+		{"a", []Button{BUTTON_A}},
+		{"start", []Button{BUTTON_START}},
+		{"right", []Button{BUTTON_RIGHT}},
+		{"b up left", []Button{BUTTON_B, BUTTON_UP, BUTTON_LEFT}},
+		{"everything", order},
+	}
+
+	for _, test := range tests {
+		ports := NewPorts(nil, nil)
+
+		for _, button := range test.pressed {
+			ports.Controller(0).SetButton(button, true)
+		}
+
+		ports.Write(PORT_1, 1)
+		ports.Write(PORT_1, 0)
+
+		bits := readBits(ports, PORT_1, 12)
+
+		for i, button := range order {
+			want := uint8(0)
+
+			if ports.Controller(0).IsPressed(button) {
+				want = 1
+			}
+
+			if bits[i] != want {
+				t.Errorf("%s: read %d got %d, want %d", test.name, i, bits[i], want)
+			}
+		}
+
+		// An official pad returns 1s once the buttons are out
+		for i := 8; i < len(bits); i++ {
+			if bits[i] != 1 {
+				t.Errorf("%s: read %d got %d, want 1", test.name, i, bits[i])
+			}
+		}
+
+		// Nothing plugged into port 2 shifted along
+		if bits := readBits(ports, PORT_2, 1); bits[0] != 0 {
+			t.Errorf("%s: port 2 moved", test.name)
+		}
+	}
+}
+
+/*
+ * With the strobe high the shift register keeps reloading, so every read
+ * is A
+ */
+func TestStrobeHigh(t *testing.T) {
+	ports := NewPorts(nil, nil)
+	ctl := ports.Controller(0)
+
+	ctl.SetButton(BUTTON_B, true)
+	ports.Write(PORT_1, 1)
+
+	for i, bit := range readBits(ports, PORT_1, 10) {
+		if bit != 0 {
+			t.Errorf("read %d: got %d with A up", i, bit)
+		}
+	}
+
+	ctl.SetButton(BUTTON_A, true)
+
+	for i, bit := range readBits(ports, PORT_1, 10) {
+		if bit != 1 {
+			t.Errorf("read %d: got %d with A down", i, bit)
+		}
+	}
+
+	// Letting go of the strobe starts at A again, then B
+	ports.Write(PORT_1, 0)
+
+	if bits := readBits(ports, PORT_1, 3); bits[0] != 1 || bits[1] != 1 || bits[2] != 0 {
+		t.Errorf("after the strobe: got %v, want [1 1 0]", bits)
+	}
+
+	// Buttons that change after the latch wait for the next one
+	ctl.SetButton(BUTTON_START, true)
+
+	if bits := readBits(ports, PORT_1, 1); bits[0] != 0 {
+		t.Errorf("start showed up without a strobe")
+	}
+}
+
+func TestPortTwo(t *testing.T) {
+	fc := &frameCounterStub{}
+	ports := NewPorts(nil, fc)
+
+	ports.Controller(1).SetButton(BUTTON_SELECT, true)
+
+	// $4016 strobes both, $4017 goes to the frame counter
+	ports.Write(PORT_1, 1)
+	ports.Write(PORT_1, 0)
+	ports.Write(PORT_2, 0xc0)
+
+	if len(fc.writes) != 1 || fc.writes[0] != 0xc0 {
+		t.Errorf("frame counter got %v, want [c0]", fc.writes)
+	}
+
+	if bits := readBits(ports, PORT_2, 4); bits[2] != 1 || bits[0]|bits[1]|bits[3] != 0 {
+		t.Errorf("port 2: got %v, want [0 0 1 0]", bits)
+	}
+
+	if bits := readBits(ports, PORT_1, 1); bits[0] != 0 {
+		t.Errorf("port 1 picked up port 2's buttons")
+	}
+}
+
+/*
+ * The top bits are whatever was last on the data bus
+ */
+func TestOpenBus(t *testing.T) {
+	tests := []struct {
+		name string
+		cpu  cpu.CPU
+		want uint8
+	}{
+		{"no cpu", nil, 0x41},
+		{"cpu", &dataBusCpu{data_bus: 0xff}, 0xe1},
+		{"cpu, low bits", &dataBusCpu{data_bus: 0x1e}, 0x01},
+	}
+
+	for _, test := range tests {
+		var value uint8
+
+		ports := NewPorts(test.cpu, nil)
+		ports.Controller(0).SetButton(BUTTON_A, true)
+		ports.Write(PORT_1, 1)
+		ports.Read(PORT_1, &value)
+
+		if value != test.want {
+			t.Errorf("%s: got %02x, want %02x", test.name, value, test.want)
+		}
+	}
+}
@@ -18,6 +18,19 @@ type Instr struct {
 	Pb_cross_cycles byte
 }
 
+/*
+ * Everything that can pull the IRQ line. The line is level triggered and
+ * wired-OR, so it stays asserted for as long as any of these hold it
+ */
+type IrqSource uint8
+
+const (
+	IRQ_SOURCE_FRAME_COUNTER IrqSource = (1 << 0)
+	IRQ_SOURCE_DMC           IrqSource = (1 << 1)
+	IRQ_SOURCE_MAPPER        IrqSource = (1 << 2)
+	IRQ_SOURCE_EXTERNAL      IrqSource = (1 << 3)
+)
+
 type CPU interface {
 	/* Initializes its internal registers to their initial values */
 	Initialize()
@@ -25,6 +38,16 @@ type CPU interface {
 	Reset()
 	/* Executes a single CPU cycle */
 	DoCycle() error
-    /* Raises an NMI */
-    RaiseNmi()
+	/* Raises an NMI */
+	RaiseNmi()
+	/* Drives the NMI line. The CPU only reacts to it going active */
+	SetNmiLine(active bool)
+	/* Asserts the IRQ line on behalf of @source */
+	RaiseIrq(source IrqSource)
+	/* Lets go of the IRQ line for @source */
+	ReleaseIrq(source IrqSource)
+	/* Stalls the CPU to read a DMC sample byte at @addr, which goes to @done */
+	StartDmcDma(addr uint16, done func(value uint8))
+	/* The last value that went over the data bus, which is what open bus reads see */
+	DataBus() uint8
 }
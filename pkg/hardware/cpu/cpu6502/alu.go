@@ -0,0 +1,264 @@
+package cpu6502
+
+/*
+ * The 6502 ALU. Everything in here updates the flags the exact same way the
+ * real chip does, including the odd ones the NMOS parts produce in
+ * decimal mode
+ *
+ * See: http://www.6502.org/tutorials/decimal_mode.html
+ */
+
+func doNegativeCheck(c *CPU6502, value uint8) {
+	if (value & 0x80) == 0x80 {
+		c.SetFlag(C6502_FLAG_NEGATIVE)
+	} else {
+		c.ClearFlag(C6502_FLAG_NEGATIVE)
+	}
+}
+
+// Overflow happens when both inputs have the same sign, but the result doesn't
+func doOverflowCheck(c *CPU6502, a uint8, m uint8, result uint8) {
+	if ((a ^ result) & (m ^ result) & 0x80) != 0 {
+		c.SetFlag(C6502_FLAG_OVERFLOW)
+	} else {
+		c.ClearFlag(C6502_FLAG_OVERFLOW)
+	}
+}
+
+func doZeroCheck(c *CPU6502, value uint8) {
+	if value == 0 {
+		c.SetFlag(C6502_FLAG_ZERO)
+	} else {
+		c.ClearFlag(C6502_FLAG_ZERO)
+	}
+}
+
+func doCarryCheck(c *CPU6502, value uint16) {
+	if value > 0xff {
+		c.SetFlag(C6502_FLAG_CARRY)
+	} else {
+		c.ClearFlag(C6502_FLAG_CARRY)
+	}
+}
+
+func doSetCarry(c *CPU6502, carry bool) {
+	if carry {
+		c.SetFlag(C6502_FLAG_CARRY)
+	} else {
+		c.ClearFlag(C6502_FLAG_CARRY)
+	}
+}
+
+func doSetFlag(c *CPU6502, flag byte, set bool) {
+	if set {
+		c.SetFlag(flag)
+	} else {
+		c.ClearFlag(flag)
+	}
+}
+
+/*
+ * Should ADC and SBC do BCD right now? The 2A03 has the decimal flag, but
+ * the circuitry behind it got cut out
+ */
+func doDecimal(c *CPU6502) bool {
+	return c.decimal_mode && c.HasFlag(C6502_FLAG_DECIMAL)
+}
+
+func doCarryIn(c *CPU6502) uint8 {
+	if c.HasFlag(C6502_FLAG_CARRY) {
+		return 1
+	}
+
+	return 0
+}
+
+/*
+ * A + M + C -> A
+ */
+func doAddWithCarry(c *CPU6502, value uint8) {
+	if doDecimal(c) {
+		doAddDecimal(c, value)
+		return
+	}
+
+	doAddBinary(c, value)
+}
+
+/*
+ * A - M - (1 - C) -> A
+ */
+func doSubtractWithCarry(c *CPU6502, value uint8) {
+	if doDecimal(c) {
+		doSubtractDecimal(c, value)
+		return
+	}
+
+	// The 6502 subtracts by adding the ones complement and treating carry
+	// as 'not borrow'
+	doAddBinary(c, ^value)
+}
+
+func doAddBinary(c *CPU6502, value uint8) {
+	// Add the two fuckers like they're u16 integers
+	sum := uint16(c.registers.a) + uint16(value) + uint16(doCarryIn(c))
+
+	doCarryCheck(c, sum)
+
+	// Overflow check needs to happen before the accumulator is set
+	doOverflowCheck(c, c.registers.a, value, uint8(sum))
+
+	// Set the registers correctly
+	c.registers.a = uint8(sum)
+
+	doNegativeCheck(c, c.registers.a)
+	doZeroCheck(c, c.registers.a)
+}
+
+/*
+ * BCD addition. Both digits get adjusted separately, and N and V come from
+ * the sum before the high digit gets adjusted. The NMOS parts take Z from
+ * the plain binary sum, the 65C02 fixed that and takes N and Z from the
+ * actual result
+ */
+func doAddDecimal(c *CPU6502, value uint8) {
+	a := c.registers.a
+	carry := doCarryIn(c)
+
+	lo := int(a&0x0f) + int(value&0x0f) + int(carry)
+
+	if lo >= 0x0a {
+		lo = ((lo + 0x06) & 0x0f) + 0x10
+	}
+
+	sum := int(a&0xf0) + int(value&0xf0) + lo
+
+	// The same thing, but signed, for the overflow flag
+	signed := int(int8(a&0xf0)) + int(int8(value&0xf0)) + lo
+
+	doSetFlag(c, C6502_FLAG_OVERFLOW, signed < -128 || signed > 127)
+	doNegativeCheck(c, uint8(sum))
+	doZeroCheck(c, a+value+carry)
+
+	if sum >= 0xa0 {
+		sum += 0x60
+	}
+
+	doSetFlag(c, C6502_FLAG_CARRY, sum >= 0x100)
+
+	c.registers.a = uint8(sum)
+
+	if c.variant == C6502_VARIANT_65C02 {
+		doNegativeCheck(c, c.registers.a)
+		doZeroCheck(c, c.registers.a)
+	}
+}
+
+/*
+ * BCD subtraction. On the NMOS parts all the flags are the same as for a
+ * binary SBC, the 65C02 takes N and Z from the adjusted result
+ */
+func doSubtractDecimal(c *CPU6502, value uint8) {
+	a := c.registers.a
+	borrow := 1 - int(doCarryIn(c))
+
+	// Flags first, since those don't care about decimal mode
+	doAddBinary(c, ^value)
+
+	lo := int(a&0x0f) - int(value&0x0f) - borrow
+
+	if c.variant == C6502_VARIANT_65C02 {
+		diff := int(a) - int(value) - borrow
+
+		if diff < 0 {
+			diff -= 0x60
+		}
+
+		if lo < 0 {
+			diff -= 0x06
+		}
+
+		c.registers.a = uint8(diff)
+
+		doNegativeCheck(c, c.registers.a)
+		doZeroCheck(c, c.registers.a)
+		return
+	}
+
+	if lo < 0 {
+		lo = ((lo - 0x06) & 0x0f) - 0x10
+	}
+
+	diff := int(a&0xf0) - int(value&0xf0) + lo
+
+	if diff < 0 {
+		diff -= 0x60
+	}
+
+	c.registers.a = uint8(diff)
+}
+
+/*
+ * Compare a register against memory. This is a subtraction of which
+ * only the flags are kept, and it never cares about decimal mode
+ */
+func doCompare(c *CPU6502, reg uint8, value uint8) {
+	doSetCarry(c, reg >= value)
+	doZeroCheck(c, reg-value)
+	doNegativeCheck(c, reg-value)
+}
+
+/*
+ * Shifts and rotates. These all push the bit that falls off into the carry
+ */
+func doShiftLeft(c *CPU6502, value uint8) uint8 {
+	// Bit 7 gets shifted into the carry
+	doSetCarry(c, (value&0x80) == 0x80)
+
+	value <<= 1
+
+	doNegativeCheck(c, value)
+	doZeroCheck(c, value)
+
+	return value
+}
+
+func doShiftRight(c *CPU6502, value uint8) uint8 {
+	// Bit 0 gets shifted into the carry
+	doSetCarry(c, (value&0x01) == 0x01)
+
+	value >>= 1
+
+	doNegativeCheck(c, value)
+	doZeroCheck(c, value)
+
+	return value
+}
+
+func doRotateLeft(c *CPU6502, val uint8) uint8 {
+	newval := val << 1
+
+	if c.HasFlag(C6502_FLAG_CARRY) {
+		newval |= 0x01
+	}
+
+	doSetCarry(c, (val&0x80) == 0x80)
+	doNegativeCheck(c, newval)
+	doZeroCheck(c, newval)
+
+	return newval
+}
+
+func doRotateRight(c *CPU6502, val uint8) uint8 {
+	newval := val >> 1
+
+	if c.HasFlag(C6502_FLAG_CARRY) {
+		newval |= 0x80
+	}
+
+	doSetCarry(c, (val&0x01) == 0x01)
+	doNegativeCheck(c, newval)
+	doZeroCheck(c, newval)
+
+	return newval
+}
@@ -0,0 +1,369 @@
+package cpu6502
+
+import (
+	"testing"
+
+	"github.com/beakeyz/gones-emu/pkg/hardware/bus"
+	"github.com/beakeyz/gones-emu/pkg/hardware/memory/ram"
+)
+
+/*
+ * What an ALU operation should produce. @mask says which flags we know the
+ * right answer for, the NMOS parts leave some of them undefined in
+ * decimal mode
+ */
+type aluResult struct {
+	value uint8
+	flags uint8
+	mask  uint8
+}
+
+type aluOp struct {
+	name string
+	/* Loads the first operand (LDA, LDX or LDY immediate) */
+	load byte
+	/* The instruction itself, immediate or accumulator */
+	opcode byte
+	/* Is there an operand byte? */
+	immediate bool
+	/* What should come out. The register is the one @load put a in */
+	expect func(variant Variant, a uint8, m uint8, p uint8) (aluResult, bool)
+}
+
+var aluOps = []aluOp{
+	{"ADC", 0xa9, 0x69, true, expectAdc},
+	{"SBC", 0xa9, 0xe9, true, expectSbc},
+	{"CMP", 0xa9, 0xc9, true, expectCompare},
+	{"CPX", 0xa2, 0xe0, true, expectCompare},
+	{"CPY", 0xa0, 0xc0, true, expectCompare},
+	{"ASL", 0xa9, 0x0a, false, expectAsl},
+	{"LSR", 0xa9, 0x4a, false, expectLsr},
+	{"ROL", 0xa9, 0x2a, false, expectRol},
+	{"ROR", 0xa9, 0x6a, false, expectRor},
+}
+
+/*
+ * A tiny CPU that runs the program at $8000 over and over, so we can just
+ * patch the immediates between runs
+ *
+ *   LDA #p
+ *   PHA
+ *   PLP
+ *   LDx #a
+ *   OP  #m     (or OP A, NOP)
+ *   JMP $8000
+ */
+type aluRig struct {
+	c   *CPU6502
+	prg *ram.Ram
+}
+
+func newAluRig(variant Variant, op *aluOp) *aluRig {
+	sbus, _ := bus.NewSystembus()
+
+	lo := ram.New(0x0000, 0x7fff, 0x8000)
+	hi := ram.New(0x8000, 0xffff, 0x8000)
+
+	sbus.AddComponent(lo)
+	sbus.AddComponent(hi)
+
+	prog := []byte{0xa9, 0x00, 0x48, 0x28, op.load, 0x00, op.opcode}
+
+	if op.immediate {
+		prog = append(prog, 0x00)
+	} else {
+		prog = append(prog, 0xea)
+	}
+
+	prog = append(prog, 0x4c, 0x00, 0x80)
+
+	for i, b := range prog {
+		hi.Write(testOrigin+uint16(i), b)
+	}
+
+	hi.Write(0xfffc, uint8(testOrigin&0xff))
+	hi.Write(0xfffd, uint8(testOrigin>>8))
+
+	c := New(sbus, variant)
+	c.Initialize()
+
+	return &aluRig{c: c, prg: hi}
+}
+
+/*
+ * Run one case and return the register the operation worked on, and P
+ */
+func (rig *aluRig) run(op *aluOp, a uint8, m uint8, p uint8) (uint8, uint8, error) {
+	var cycles int
+	var value uint8
+
+	rig.prg.Write(testOrigin+1, p)
+	rig.prg.Write(testOrigin+5, a)
+
+	if op.immediate {
+		rig.prg.Write(testOrigin+7, m)
+	}
+
+	// LDA, PHA, PLP, LDx and the operation
+	for range 5 {
+		if err := rig.c.ExecuteFrame(&cycles); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	switch op.load {
+	case 0xa2:
+		value = rig.c.GetX()
+	case 0xa0:
+		value = rig.c.GetY()
+	default:
+		value = rig.c.GetAccumulator()
+	}
+
+	flags := rig.c.GetFlags()
+
+	// NOP (if there is one) and JMP
+	for rig.c.GetPC() != testOrigin {
+		if err := rig.c.ExecuteFrame(&cycles); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return value, flags, nil
+}
+
+func nzFlags(value uint8) uint8 {
+	var flags uint8
+
+	if value == 0 {
+		flags |= C6502_FLAG_ZERO
+	}
+
+	return flags | (value & C6502_FLAG_NEGATIVE)
+}
+
+func carryIn(p uint8) int {
+	return int(p & C6502_FLAG_CARRY)
+}
+
+func isBcd(value uint8) bool {
+	return (value&0x0f) <= 9 && (value>>4) <= 9
+}
+
+func fromBcd(value uint8) int {
+	return int(value>>4)*10 + int(value&0x0f)
+}
+
+func toBcd(value int) uint8 {
+	return uint8((value/10)<<4 | (value % 10))
+}
+
+/*
+ * Does this variant do BCD when D is set?
+ */
+func hasDecimal(variant Variant, p uint8) bool {
+	return variant != C6502_VARIANT_2A03 && (p&C6502_FLAG_DECIMAL) != 0
+}
+
+func binaryAdd(a uint8, m uint8, carry int) aluResult {
+	sum := int(a) + int(m) + carry
+	signed := int(int8(a)) + int(int8(m)) + carry
+
+	flags := nzFlags(uint8(sum))
+
+	if sum > 0xff {
+		flags |= C6502_FLAG_CARRY
+	}
+
+	if signed < -128 || signed > 127 {
+		flags |= C6502_FLAG_OVERFLOW
+	}
+
+	return aluResult{value: uint8(sum), flags: flags, mask: C6502_FLAG_NEGATIVE | C6502_FLAG_OVERFLOW | C6502_FLAG_ZERO | C6502_FLAG_CARRY}
+}
+
+func binarySub(a uint8, m uint8, borrow int) aluResult {
+	diff := int(a) - int(m) - borrow
+	signed := int(int8(a)) - int(int8(m)) - borrow
+
+	flags := nzFlags(uint8(diff))
+
+	if diff >= 0 {
+		flags |= C6502_FLAG_CARRY
+	}
+
+	if signed < -128 || signed > 127 {
+		flags |= C6502_FLAG_OVERFLOW
+	}
+
+	return aluResult{value: uint8(diff), flags: flags, mask: C6502_FLAG_NEGATIVE | C6502_FLAG_OVERFLOW | C6502_FLAG_ZERO | C6502_FLAG_CARRY}
+}
+
+/*
+ * In decimal mode we only know the right answer for valid BCD operands.
+ * The NMOS parts take Z from the binary sum and have meaningless N and V,
+ * the 65C02 gets N and Z right
+ */
+func expectAdc(variant Variant, a uint8, m uint8, p uint8) (aluResult, bool) {
+	binary := binaryAdd(a, m, carryIn(p))
+
+	if !hasDecimal(variant, p) {
+		return binary, true
+	}
+
+	if !isBcd(a) || !isBcd(m) {
+		return aluResult{}, false
+	}
+
+	sum := fromBcd(a) + fromBcd(m) + carryIn(p)
+	res := aluResult{value: toBcd(sum % 100), mask: C6502_FLAG_CARRY | C6502_FLAG_ZERO}
+
+	if sum >= 100 {
+		res.flags |= C6502_FLAG_CARRY
+	}
+
+	if variant == C6502_VARIANT_65C02 {
+		res.flags |= nzFlags(res.value)
+		res.mask |= C6502_FLAG_NEGATIVE
+	} else {
+		res.flags |= binary.flags & C6502_FLAG_ZERO
+	}
+
+	return res, true
+}
+
+/*
+ * Decimal SBC leaves all the flags the way binary SBC sets them on NMOS,
+ * the 65C02 takes N and Z from the BCD result
+ */
+func expectSbc(variant Variant, a uint8, m uint8, p uint8) (aluResult, bool) {
+	binary := binarySub(a, m, 1-carryIn(p))
+
+	if !hasDecimal(variant, p) {
+		return binary, true
+	}
+
+	if !isBcd(a) || !isBcd(m) {
+		return aluResult{}, false
+	}
+
+	diff := fromBcd(a) - fromBcd(m) - (1 - carryIn(p))
+
+	if diff < 0 {
+		diff += 100
+	}
+
+	res := binary
+	res.value = toBcd(diff)
+
+	if variant == C6502_VARIANT_65C02 {
+		res.flags = (res.flags & ^uint8(C6502_FLAG_NEGATIVE|C6502_FLAG_ZERO)) | nzFlags(res.value)
+	}
+
+	return res, true
+}
+
+func expectCompare(variant Variant, a uint8, m uint8, p uint8) (aluResult, bool) {
+	res := binarySub(a, m, 0)
+
+	// Compares don't touch the register, or V
+	res.value = a
+	res.mask = C6502_FLAG_NEGATIVE | C6502_FLAG_ZERO | C6502_FLAG_CARRY
+	res.flags |= p & C6502_FLAG_OVERFLOW
+
+	return res, true
+}
+
+func shiftResult(value uint8, carry bool) (aluResult, bool) {
+	flags := nzFlags(value)
+
+	if carry {
+		flags |= C6502_FLAG_CARRY
+	}
+
+	return aluResult{value: value, flags: flags, mask: C6502_FLAG_NEGATIVE | C6502_FLAG_ZERO | C6502_FLAG_CARRY}, true
+}
+
+func expectAsl(variant Variant, a uint8, m uint8, p uint8) (aluResult, bool) {
+	return shiftResult(a<<1, a >= 0x80)
+}
+
+func expectLsr(variant Variant, a uint8, m uint8, p uint8) (aluResult, bool) {
+	return shiftResult(a>>1, (a&1) != 0)
+}
+
+func expectRol(variant Variant, a uint8, m uint8, p uint8) (aluResult, bool) {
+	return shiftResult((a<<1)|(p&C6502_FLAG_CARRY), a >= 0x80)
+}
+
+func expectRor(variant Variant, a uint8, m uint8, p uint8) (aluResult, bool) {
+	return shiftResult((a>>1)|((p&C6502_FLAG_CARRY)<<7), (a&1) != 0)
+}
+
+/* Don't flood the output when something is very wrong */
+const maxReportedMismatches = 8
+
+/*
+ * Run every ALU operation over every A, M, carry and decimal combination
+ * and compare against a plain integer model of the 6502. The 2A03 covers
+ * binary mode, the NMOS and 65C02 parts their own flavour of BCD
+ */
+func TestAluFlags(t *testing.T) {
+	variants := map[string]Variant{
+		"2a03":  C6502_VARIANT_2A03,
+		"nmos":  C6502_VARIANT_NMOS,
+		"65c02": C6502_VARIANT_65C02,
+	}
+
+	for name, variant := range variants {
+		for i := range aluOps {
+			op := &aluOps[i]
+
+			t.Run(name+"/"+op.name, func(t *testing.T) {
+				checkAluOp(t, variant, op)
+			})
+		}
+	}
+}
+
+func checkAluOp(t *testing.T, variant Variant, op *aluOp) {
+	rig := newAluRig(variant, op)
+	mismatches := 0
+
+	for _, p := range []uint8{0x00, C6502_FLAG_CARRY, C6502_FLAG_DECIMAL, C6502_FLAG_DECIMAL | C6502_FLAG_CARRY} {
+		for a := range 256 {
+			for m := range 256 {
+				if !op.immediate && m != 0 {
+					break
+				}
+
+				want, ok := op.expect(variant, uint8(a), uint8(m), p)
+
+				if !ok {
+					continue
+				}
+
+				value, flags, err := rig.run(op, uint8(a), uint8(m), p)
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if value == want.value && (flags&want.mask) == (want.flags&want.mask) {
+					continue
+				}
+
+				mismatches++
+
+				if mismatches <= maxReportedMismatches {
+					t.Errorf("a=$%02x m=$%02x p=$%02x: got $%02x p=$%02x, want $%02x p=$%02x (mask $%02x)",
+						a, m, p, value, flags, want.value, want.flags, want.mask)
+				}
+			}
+		}
+	}
+
+	if mismatches > maxReportedMismatches {
+		t.Errorf("%d more mismatches", mismatches-maxReportedMismatches)
+	}
+}
@@ -0,0 +1,80 @@
+package cpu6502
+
+import (
+	"testing"
+
+	"github.com/beakeyz/gones-emu/pkg/hardware/bus"
+	"github.com/beakeyz/gones-emu/pkg/hardware/memory/ram"
+	"github.com/beakeyz/gones-emu/pkg/hardware/memory/rom"
+)
+
+/*
+ * A little loop that touches most of the addressing modes
+ *
+ * loop:
+ *   LDA $10,X
+ *   ADC #$01
+ *   STA $0200,Y
+ *   LDA ($20),Y
+ *   INX
+ *   INY
+ *   ASL $30
+ *   DEC $31
+ *   CMP $0300,X
+ *   BNE loop
+ *   JMP loop
+ */
+var benchProgram = []byte{
+	0xb5, 0x10,
+	0x69, 0x01,
+	0x99, 0x00, 0x02,
+	0xb1, 0x20,
+	0xe8,
+	0xc8,
+	0x06, 0x30,
+	0xc6, 0x31,
+	0xdd, 0x00, 0x03,
+	0xd0, 0xec,
+	0x4c, 0x00, 0x80,
+}
+
+/*
+ * Instructions per second through ExecuteFrame. Compare runs with
+ * benchstat:
+ *
+ *   go test -run '^$' -bench Execute -count 10 ./pkg/hardware/cpu/cpu6502
+ */
+func BenchmarkExecute(b *testing.B) {
+	var cycles int
+
+	sbus, err := bus.NewSystembus()
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	prg := make([]byte, 0x8000)
+	copy(prg, benchProgram)
+
+	// Reset vector -> 0x8000
+	prg[0x7ffc] = 0x00
+	prg[0x7ffd] = 0x80
+
+	sbus.AddComponent(ram.New(0, 0x1fff, 0x0800))
+	sbus.AddComponent(rom.New(0x8000, 0xffff, 0x8000, prg))
+
+	c := New(sbus, C6502_VARIANT_2A03)
+	c.Initialize()
+
+	b.ResetTimer()
+
+	for range b.N {
+		err = c.ExecuteFrame(&cycles)
+
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "instr/s")
+}
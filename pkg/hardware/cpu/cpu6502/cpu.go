@@ -36,20 +36,88 @@ const (
 	C6502_FLAG_NEGATIVE   = (1 << 7)
 )
 
+/*
+ * What an instruction actually does, separate from how it gets its
+ * operand. Only one of these is set, depending on the kind of instruction,
+ * and the microcode takes care of all the bus cycles around it
+ */
 type InstrImpl struct {
-	Id   cpu.InstrID
-	Impl func(c *CPU6502, i *cpu.Instr, opperand []byte) error
+	Id cpu.InstrID
+	/* Instructions that consume a value (LDA, ADC, CMP, ...) */
+	Read func(c *CPU6502, value uint8)
+	/* Instructions that produce the value to store (STA, SAX, PHP, ...) */
+	Write func(c *CPU6502) uint8
+	/* Read-modify-write instructions (ASL, INC, SLO, ...) */
+	Modify func(c *CPU6502, value uint8) uint8
+	/* Instructions that only touch registers (TAX, CLC, ...) */
+	Implied func(c *CPU6502)
+	/* Branch condition */
+	Branch func(c *CPU6502) bool
 }
 
 type CPU6502 struct {
 	registers CPU6502Register
 	sbus      *bus.SystemBus
+	/* Which flavour of 6502 we are */
+	variant Variant
+	/* Opcode -> instruction table for our variant */
+	decode *decodeTable
+	/* Should we refuse to execute the unstable unofficial opcodes? */
+	trap_unstable bool
+	/* Do ADC and SBC honour the decimal flag? */
+	decimal_mode bool
 	/* Current instruction we're executing */
 	c_instr *cpu.Instr
+	/* Microcode of the current instruction. nil between instructions */
+	ops []microOp
+	/* Which micro op runs next */
+	step int
 	/* Amount of cycles we've spent at this instruction */
-	n_cycles  byte
-	next_pc   uint16
-	impl_list []InstrImpl
+	n_cycles int
+	/* Amount of cycles since power on */
+	cycles uint64
+	/* Effective address of the current instruction */
+	addr uint16
+	/* Address before indexing, to detect page crossings */
+	base uint16
+	/* Zero page pointer for the indirect modes */
+	ptr uint8
+	/* Scratch value that lives across cycles */
+	value uint8
+	/* Last value that was on the data bus. Unmapped reads return this */
+	data_bus uint8
+	/* Set by the JAM opcodes. Only a reset gets us out */
+	jammed bool
+	/* Level of the NMI line, so we can spot it going active */
+	nmi_line bool
+	/* An NMI edge that hasn't been serviced yet */
+	nmi_pending bool
+	/* Everyone that's currently holding the IRQ line */
+	irq_lines cpu.IrqSource
+	/*
+	 * Interrupt polling. The CPU looks at the lines at the end of every
+	 * cycle, but only acts on what it saw on the second to last cycle of
+	 * an instruction. That's why CLI, SEI and PLP take effect one
+	 * instruction late
+	 */
+	poll      bool
+	poll_prev bool
+	/* Skip the poll of this cycle (taken branches that stay on their page) */
+	hold_poll bool
+	/* Run the interrupt sequence instead of fetching the next opcode */
+	take_interrupt bool
+	/* OAM DMA state, see dma.go */
+	dma_active bool
+	dma_halt   bool
+	dma_loaded bool
+	dma_page   uint8
+	dma_index  int
+	dma_value  uint8
+	/* DMC sample fetch that's waiting for (or stalling) the CPU */
+	dmc_pending bool
+	dmc_stall   int
+	dmc_addr    uint16
+	dmc_done    func(value uint8)
 }
 
 func (cpu *CPU6502) Initialize() {
@@ -59,21 +127,18 @@ func (cpu *CPU6502) Initialize() {
 	regs.a = 0
 	regs.x = 0
 	regs.y = 0
-	regs.s = 0xff
+	regs.s = 0
 	regs.p = (C6502_FLAG_INTDISABLE | C6502_FLAG_RESERVED)
 
-	a := uint8(0)
-	b := uint8(0)
+	cpu.cycles = 0
 
-	cpu.sbus.Read(0xfffc, &a)
-	cpu.sbus.Read(0xfffd, &b)
+	// Power on runs the regular reset sequence, which leaves S at 0xfd
+	cpu.Reset()
 
-	debug.Log("a: 0x%x, b: 0x%x\n", a, b)
-
-	regs.pc = uint16(a) | (uint16(b) << 8)
-	cpu.next_pc = regs.pc
+	for !cpu.InstructionDone() {
+		cpu.DoCycle()
+	}
 
-	// 31 e6 -> e631
 	debug.Log("PC: 0x%x\n", regs.pc)
 }
 
@@ -101,27 +166,54 @@ func (self *CPU6502) GetPC() uint16 {
 	return self.registers.pc
 }
 
-func (cpu *CPU6502) Reset() {
-	var regs *CPU6502Register = &cpu.registers
-
-	/* See: https://www.nesdev.org/wiki/CPU_power_up_state */
-	regs.s -= 3
-	regs.p |= C6502_FLAG_INTDISABLE
-	regs.pc = 0xfffc
+/*
+ * Jump somewhere without going through the reset vector. Only makes sense
+ * between instructions, test ROMs like nestest want this
+ */
+func (self *CPU6502) SetPC(pc uint16) {
+	self.registers.pc = pc
 }
 
-func (cpu *CPU6502) fetchOpperand(opperand []byte, len byte) error {
-	var err error = nil
+func (self *CPU6502) GetVariant() Variant {
+	return self.variant
+}
 
-	for i := range len {
-		err = cpu.sbus.Read(cpu.registers.pc+uint16(i), &opperand[i])
+/*
+ * When set, the CPU errors out on the unstable unofficial opcodes (XAA,
+ * SHA, TAS and friends) instead of emulating the usual behaviour
+ */
+func (self *CPU6502) SetTrapUnstable(trap bool) {
+	self.trap_unstable = trap
+}
 
-		if err != nil {
-			break
-		}
-	}
+/*
+ * Turn BCD arithmetic on or off. It's on by default for everything that
+ * isn't a 2A03, which has no decimal mode at all, so it stays off there
+ */
+func (self *CPU6502) SetDecimalMode(enabled bool) {
+	self.decimal_mode = enabled && self.variant != C6502_VARIANT_2A03
+}
 
-	return err
+/*
+ * Start the reset sequence. It takes 7 cycles like any other interrupt,
+ * but the stack writes turn into reads so only S changes
+ *
+ * See: https://www.nesdev.org/wiki/CPU_power_up_state
+ */
+func (cpu *CPU6502) Reset() {
+	cpu.jammed = false
+	cpu.nmi_pending = false
+	cpu.take_interrupt = false
+	cpu.poll = false
+	cpu.poll_prev = false
+	cpu.dma_active = false
+	// A DMC fetch that didn't happen yet gets dropped, the APU resets too
+	cpu.dmc_pending = false
+	cpu.dmc_stall = 0
+	cpu.dmc_done = nil
+	cpu.c_instr = nil
+	cpu.ops = resetMicrocode
+	cpu.step = 0
 }
 
 func (c *CPU6502) SetFlag(flag byte) {
@@ -136,53 +228,85 @@ func (c *CPU6502) HasFlag(flag byte) bool {
 	return (c.registers.p & flag) == flag
 }
 
-func (c *CPU6502) doPush8(value uint8) {
-	c.sbus.Write(0x0100+uint16(c.registers.s), uint8(value))
-	c.registers.s--
+func (c *CPU6502) GetPageAddress(addr uint16) uint16 {
+	return (addr & 0xff00)
 }
 
-func (c *CPU6502) doPop8(value *uint8) {
-	c.registers.s++
-	c.sbus.Read(0x0100+uint16(c.registers.s), value)
+/*
+ * Amount of CPU cycles since power on
+ */
+func (c *CPU6502) GetCycles() uint64 {
+	return c.cycles
 }
 
-// 0xff00 => * 00 ff
-// 00 ff =>
-func (c *CPU6502) doPush16(value uint16) {
-	c.doPush8(uint8(value >> 8))
-	c.doPush8(uint8(value))
+/*
+ * Are we in between instructions?
+ */
+func (c *CPU6502) InstructionDone() bool {
+	return c.ops == nil && !c.dma_active && !c.dmc_pending
 }
 
-func (c *CPU6502) doPop16(value *uint16) {
-	var a uint8
-	var b uint8
+/*
+ * A single bus read. Nothing answers for unmapped addresses, so we get
+ * whatever was last left on the data bus (open bus)
+ */
+func (c *CPU6502) read(addr uint16) uint8 {
+	var value uint8
+
+	if c.sbus.Read(addr, &value) != nil {
+		return c.data_bus
+	}
 
-	c.doPop8(&a)
-	c.doPop8(&b)
+	c.data_bus = value
 
-	*value = uint16(a) | (uint16(b) << 8)
+	return value
 }
 
-func (cpu *CPU6502) fetchInstrImpl(instid cpu.InstrID) *InstrImpl {
-	var impl *InstrImpl
-	if int(instid) >= len(cpu.impl_list) {
-		return nil
-	}
+func (c *CPU6502) write(addr uint16, value uint8) {
+	c.data_bus = value
+	c.sbus.Write(addr, value)
+}
 
-	impl = &cpu.impl_list[instid]
+func (c *CPU6502) DataBus() uint8 {
+	return c.data_bus
+}
 
-	if impl.Id != instid {
-		debug.Error("Got id: %d\n", impl.Id)
-		return nil
-	}
+/*
+ * Read the byte at PC and move past it
+ */
+func (c *CPU6502) fetch() uint8 {
+	value := c.read(c.registers.pc)
+	c.registers.pc++
 
-	return impl
+	return value
 }
 
-func (c *CPU6502) GetPageAddress(addr uint16) uint16 {
-	return (addr & 0xff00)
+func (c *CPU6502) stackAddr() uint16 {
+	return 0x0100 + uint16(c.registers.s)
+}
+
+func (c *CPU6502) push(value uint8) {
+	c.write(c.stackAddr(), value)
+	c.registers.s--
+}
+
+/*
+ * Did indexing move the effective address to another page?
+ */
+func (c *CPU6502) pageCrossed() bool {
+	return c.GetPageAddress(c.base) != c.GetPageAddress(c.addr)
 }
 
+/*
+ * The address the CPU puts on the bus before it fixed up the high byte
+ */
+func (c *CPU6502) uncorrectedAddr() uint16 {
+	return c.GetPageAddress(c.base) | (c.addr & 0x00ff)
+}
+
+/*
+ * Execute a single instruction and report how many cycles it took
+ */
 func (c *CPU6502) ExecuteFrame(cpuCyclesElapsed *int) error {
 
 	var err error
@@ -197,117 +321,201 @@ func (c *CPU6502) ExecuteFrame(cpuCyclesElapsed *int) error {
 	// Execute a single instruction
 	err = c.ExecuteInstruction()
 
-	if err != nil {
-		return err
-	}
-
 	// Export the amount of cycles it took
-	*cpuCyclesElapsed = int(c.n_cycles)
+	*cpuCyclesElapsed = c.n_cycles
 
-	return nil
+	return err
 }
 
 /*
- * Do a single 6502 clockcycle
- *
- * If we're still 'executing' an instruction, wait
- * the appropriate amount of time and skip this cycle
- *
- * Otherwise fetch a new instruction from the PC
+ * Run cycles until we hit the next instruction boundary
  */
 func (c *CPU6502) ExecuteInstruction() error {
-	var err error
-	var c_instr cpu.Instr
-	var impl *InstrImpl
+	for {
+		err := c.DoCycle()
 
-	var c_opcode byte
+		if err != nil {
+			return err
+		}
 
-	// debug.Log("Reading... pc=0x%x\n", c.registers.pc)
+		if c.InstructionDone() {
+			return nil
+		}
+	}
+}
 
-	// Read the opcode from PC
-	err = c.sbus.Read(c.registers.pc, &c_opcode)
+/*
+ * Fetch and decode the opcode at PC. This is the first cycle of every
+ * instruction
+ */
+func (c *CPU6502) fetchInstruction() error {
+	var entry *decodedInstr
 
-	if err != nil {
-		return err
-	}
+	opcode := c.fetch()
 
 	// Try to get the instruction for this opcode
-	c_instr, err = GetInstr(c_opcode)
+	entry = &c.decode[opcode]
 
-	if err != nil {
-		return err
+	if !entry.valid {
+		return fmt.Errorf("cpu6502: invalid opcode 0x%x at 0x%x", opcode, c.registers.pc-1)
 	}
 
-	debug.Log("Executing instruction: 0x%x (opcode=0x%x)\n", c_instr.Instruction, c_instr.Opcode)
+	if c.trap_unstable && IsUnstable(entry.instr.Instruction) {
+		return fmt.Errorf("cpu6502: trapped unstable opcode 0x%x at 0x%x", opcode, c.registers.pc-1)
+	}
 
-	c.c_instr = &c_instr
+	c.c_instr = &entry.instr
+	c.ops = entry.ops
+	c.step = 0
 
-	var opperand []byte = make([]byte, c.c_instr.Len)
+	return nil
+}
 
-	err = c.fetchOpperand(opperand, c.c_instr.Len)
+/*
+ * Do a single 6502 clockcycle
+ *
+ * Every cycle is exactly one read or write on the bus, in the same order
+ * as the real chip does them, dummy accesses included
+ */
+func (c *CPU6502) DoCycle() error {
+	var err error
 
-	if err != nil {
-		return err
+	if c.jammed {
+		return fmt.Errorf("cpu6502: jammed at 0x%x", c.registers.pc)
 	}
 
-	impl = c.fetchInstrImpl(c.c_instr.Instruction)
+	c.n_cycles++
+	c.cycles++
 
-	if impl == nil {
-		return fmt.Errorf("cpu6502: fetching unimplemented instruction: %d\n", c.c_instr.Instruction)
+	// DMA holds the CPU off the bus until it's done
+	if c.dmc_pending {
+		c.dmcCycle()
+		return nil
 	}
 
-	// debug.Log("(0x%x Len:%d): ", c.registers.pc, c.c_instr.Len)
+	if c.dma_active {
+		c.dmaCycle()
+		return nil
+	}
 
-	err = impl.Impl(c, c.c_instr, opperand)
+	if c.ops == nil {
+		if !c.take_interrupt {
+			err = c.fetchInstruction()
+			c.pollInterrupts()
 
-	if err != nil {
-		return err
+			return err
+		}
+
+		// The opcode fetch turns into the first cycle of the interrupt sequence
+		c.take_interrupt = false
+		c.c_instr = nil
+		c.ops = irqMicrocode
+		c.step = 0
 	}
 
-	// Add the size of this instruction if next_pc didn't change
-	if c.next_pc == c.registers.pc {
-		c.next_pc = c.registers.pc + uint16(c.c_instr.Len)
-	} else {
-		// We took a branch. Add a cycle
-		c.n_cycles++
+	done := c.ops[c.step](c)
+	c.step++
 
-		// Add an extra cycle if we crossed a page boundry
-		if c.GetPageAddress(c.next_pc) != c.GetPageAddress(c.registers.pc) {
-			c.n_cycles++
-		}
+	c.pollInterrupts()
+
+	if done {
+		c.ops = nil
+		c.step = 0
+
+		// Interrupt sequences clear the poll, so the handler always gets to
+		// run at least one instruction
+		c.take_interrupt = c.poll_prev
 	}
 
-	// Add this instructions cycles
-	c.n_cycles += c.c_instr.Cycles
+	return nil
+}
 
-	// TODO: Add this instruction page crossed cycles cound maybe
+/*
+ * Sample the interrupt lines at the end of a cycle
+ */
+func (c *CPU6502) pollInterrupts() {
+	if c.hold_poll {
+		c.hold_poll = false
+		return
+	}
 
-	c.registers.pc = c.next_pc
+	c.poll_prev = c.poll
+	c.poll = c.interruptPending()
+}
 
-	// debug.Log("PC is now: 0x%x\n", c.registers.pc)
+/*
+ * Does anything want to interrupt us right now?
+ */
+func (c *CPU6502) interruptPending() bool {
+	return c.nmi_pending || (c.irq_lines != 0 && !c.HasFlag(C6502_FLAG_INTDISABLE))
+}
 
-	return err
+/*
+ * Pick the vector for an interrupt sequence that's about to fetch it.
+ * A pending NMI always wins, even over a BRK or IRQ that's already going
+ */
+func (c *CPU6502) interruptVector(reset bool) uint16 {
+	if reset {
+		return 0xfffc
+	}
+
+	if c.nmi_pending {
+		c.nmi_pending = false
+		return 0xfffa
+	}
+
+	return 0xfffe
 }
 
 /*
- * Transfers CPU control to the Nmi handler
- *
+ * Transfers CPU control to the Nmi handler, as if the NMI line had just
+ * gone active
  */
 func (c *CPU6502) RaiseNmi() {
+	c.nmi_pending = true
+}
+
+/*
+ * The NMI line is edge triggered. Keeping it active doesn't do anything,
+ * it needs to go inactive and back before it fires again
+ */
+func (c *CPU6502) SetNmiLine(active bool) {
+	if active && !c.nmi_line {
+		c.nmi_pending = true
+	}
 
+	c.nmi_line = active
 }
 
-func (c *CPU6502) RaiseIrq() {
+func (c *CPU6502) RaiseIrq(source cpu.IrqSource) {
+	c.irq_lines |= source
+}
 
+func (c *CPU6502) ReleaseIrq(source cpu.IrqSource) {
+	c.irq_lines &= ^source
 }
 
-func New(sbus *bus.SystemBus) *CPU6502 {
+/*
+ * Is anyone holding the IRQ line right now?
+ */
+func (c *CPU6502) IrqAsserted() bool {
+	return c.irq_lines != 0
+}
+
+/*
+ * Create a new 6502 on @sbus. @variant selects the decode table (and
+ * the quirks that come with it). The NES wants C6502_VARIANT_2A03
+ */
+func New(sbus *bus.SystemBus, variant Variant) *CPU6502 {
 	var c CPU6502 = CPU6502{
 		registers: CPU6502Register{},
 		sbus:      sbus,
+		variant:   variant,
+		decode:    getDecodeTable(variant),
 		c_instr:   nil,
-		n_cycles:  0,
-		impl_list: cpu6502_imp,
+		/* The NES CPU has no decimal mode, the rest does */
+		decimal_mode: variant != C6502_VARIANT_2A03,
+		n_cycles:     0,
 	}
 
 	return &c
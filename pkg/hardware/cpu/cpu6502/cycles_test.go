@@ -0,0 +1,280 @@
+package cpu6502
+
+import (
+	"testing"
+
+	"github.com/beakeyz/gones-emu/pkg/hardware/bus"
+	"github.com/beakeyz/gones-emu/pkg/hardware/cpu"
+	"github.com/beakeyz/gones-emu/pkg/hardware/memory/ram"
+)
+
+/*
+ * Cycle counts for every NMOS opcode, unofficial ones included, straight
+ * from the datasheets. This is on purpose not built from the instruction
+ * table, so a wrong entry in there can't agree with itself. 0 is a JAM
+ *
+ * See: https://www.nesdev.org/wiki/CPU_unofficial_opcodes
+ */
+var nmosCycleTable = [256]int{
+	//   0  1  2  3  4  5  6  7  8  9  a  b  c  d  e  f
+	/* 0 */ 7, 6, 0, 8, 3, 3, 5, 5, 3, 2, 2, 2, 4, 4, 6, 6,
+	/* 1 */ 2, 5, 0, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+	/* 2 */ 6, 6, 0, 8, 3, 3, 5, 5, 4, 2, 2, 2, 4, 4, 6, 6,
+	/* 3 */ 2, 5, 0, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+	/* 4 */ 6, 6, 0, 8, 3, 3, 5, 5, 3, 2, 2, 2, 3, 4, 6, 6,
+	/* 5 */ 2, 5, 0, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+	/* 6 */ 6, 6, 0, 8, 3, 3, 5, 5, 4, 2, 2, 2, 5, 4, 6, 6,
+	/* 7 */ 2, 5, 0, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+	/* 8 */ 2, 6, 2, 6, 3, 3, 3, 3, 2, 2, 2, 2, 4, 4, 4, 4,
+	/* 9 */ 2, 6, 0, 6, 4, 4, 4, 4, 2, 5, 2, 5, 5, 5, 5, 5,
+	/* a */ 2, 6, 2, 6, 3, 3, 3, 3, 2, 2, 2, 2, 4, 4, 4, 4,
+	/* b */ 2, 5, 0, 5, 4, 4, 4, 4, 2, 4, 2, 4, 4, 4, 4, 4,
+	/* c */ 2, 6, 2, 8, 3, 3, 5, 5, 2, 2, 2, 2, 4, 4, 6, 6,
+	/* d */ 2, 5, 0, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+	/* e */ 2, 6, 2, 8, 3, 3, 5, 5, 2, 2, 2, 2, 4, 4, 6, 6,
+	/* f */ 2, 5, 0, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
+}
+
+/*
+ * Reads through abs,X, abs,Y and (zp),Y that pay a cycle when the index
+ * crosses a page. Stores and read-modify-writes always take the long way,
+ * so they never pay extra
+ */
+var nmosPageCrossOps = []byte{
+	// ORA, AND, EOR, ADC, LDA, CMP, SBC
+	0x11, 0x19, 0x1d,
+	0x31, 0x39, 0x3d,
+	0x51, 0x59, 0x5d,
+	0x71, 0x79, 0x7d,
+	0xb1, 0xb9, 0xbd,
+	0xd1, 0xd9, 0xdd,
+	0xf1, 0xf9, 0xfd,
+	// LDY abs,X, LDX abs,Y
+	0xbc, 0xbe,
+	// LAX, LAS and the abs,X NOPs
+	0xb3, 0xbf, 0xbb,
+	0x1c, 0x3c, 0x5c, 0x7c, 0xdc, 0xfc,
+}
+
+/*
+ * Where the 65C02 differs from the NMOS table, for the opcodes we have
+ */
+var cmosCycleOverrides = map[byte]int{
+	// (zp)
+	0x12: 5, 0x32: 5, 0x52: 5, 0x72: 5, 0x92: 5, 0xb2: 5, 0xd2: 5, 0xf2: 5,
+	// STZ
+	0x64: 3, 0x74: 4, 0x9c: 4, 0x9e: 5,
+	// BIT #, zp,X and abs,X
+	0x89: 2, 0x34: 4, 0x3c: 4,
+	// BRA (always taken, so really 3)
+	0x80: 2,
+	// INC A, DEC A
+	0x1a: 2, 0x3a: 2,
+	// JMP (abs) without the page wrap bug, JMP (abs,X)
+	0x6c: 6, 0x7c: 6,
+	// TRB, TSB
+	0x14: 5, 0x1c: 6, 0x04: 5, 0x0c: 6,
+	// PHX, PLX, PHY, PLY
+	0xda: 3, 0xfa: 4, 0x5a: 3, 0x7a: 4,
+	// The shifts on abs,X only take the extra cycle on a page cross
+	0x1e: 6, 0x3e: 6, 0x5e: 6, 0x7e: 6,
+}
+
+var cmosPageCrossOps = []byte{
+	0x3c,
+	0x1e, 0x3e, 0x5e, 0x7e,
+}
+
+/* Where the instruction under test lives */
+const testOrigin = 0x8000
+
+/* Zero page pointer used by the indirect modes */
+const testPointer = 0x40
+
+type cycleScenario struct {
+	crossed bool
+	flags   uint8
+}
+
+/*
+ * What the datasheet says @op takes on @variant
+ */
+func expectedCycles(variant Variant, op byte, mode cpu.AddrMode, crossed bool, taken bool) int {
+	cycles := nmosCycleTable[op]
+	penalty := 0
+	cross_ops := nmosPageCrossOps
+
+	if variant == C6502_VARIANT_65C02 {
+		if override, ok := cmosCycleOverrides[op]; ok {
+			cycles = override
+		}
+
+		cross_ops = append(append([]byte{}, cross_ops...), cmosPageCrossOps...)
+	}
+
+	for _, cross_op := range cross_ops {
+		if cross_op == op {
+			penalty = 1
+		}
+	}
+
+	if mode == REL {
+		// A taken branch costs one more, and another one to fix up the page
+		if !taken {
+			return cycles
+		}
+
+		cycles++
+		penalty = 1
+	}
+
+	if crossed {
+		cycles += penalty
+	}
+
+	return cycles
+}
+
+/*
+ * Indexed modes get run with and without a page crossing, branches both
+ * ways with the flags all set and all clear
+ */
+func cycleScenarios(mode cpu.AddrMode) []cycleScenario {
+	switch mode {
+	case ABX, ABY, IDY:
+		return []cycleScenario{{crossed: false}, {crossed: true}}
+	case REL:
+		// Decimal stays clear, the 65C02 charges extra for it on ADC/SBC
+		return []cycleScenario{
+			{crossed: false, flags: 0x00},
+			{crossed: false, flags: 0xf7},
+			{crossed: true, flags: 0x00},
+			{crossed: true, flags: 0xf7},
+		}
+	}
+
+	return []cycleScenario{{}}
+}
+
+/*
+ * Set the registers up through a little prelude and then time the one
+ * instruction we care about. Returns the cycles it took and whether a
+ * branch was taken
+ */
+func runCycleScenario(t *testing.T, variant Variant, inst *cpu.Instr, sc cycleScenario) (int, bool) {
+	var cycles int
+
+	sbus, _ := bus.NewSystembus()
+
+	lo := ram.New(0x0000, 0x7fff, 0x8000)
+	hi := ram.New(0x8000, 0xffff, 0x8000)
+
+	sbus.AddComponent(lo)
+	sbus.AddComponent(hi)
+
+	// Operands: $xx10 stays on its page with an index of 1, $xxff doesn't
+	addr_lo := uint8(0x10)
+	rel := uint8(0x02)
+
+	if sc.crossed {
+		addr_lo = 0xff
+		rel = 0x80
+	}
+
+	// (zp,X) and (zp),Y both find $02xx behind the pointer
+	lo.Write(testPointer, addr_lo)
+	lo.Write(testPointer+1, 0x02)
+	lo.Write(testPointer+2, 0x02)
+
+	prog := []byte{
+		0xa2, 0x01, // LDX #$01
+		0xa0, 0x01, // LDY #$01
+		0xa9, sc.flags, // LDA #flags
+		0x48, // PHA
+		0x28, // PLP
+		inst.Opcode,
+	}
+
+	switch inst.Len {
+	case 2:
+		if inst.Mode == REL {
+			prog = append(prog, rel)
+		} else if inst.Mode == ZPG || inst.Mode == ZPX || inst.Mode == ZPY {
+			prog = append(prog, 0x10)
+		} else {
+			prog = append(prog, testPointer)
+		}
+	case 3:
+		prog = append(prog, addr_lo, 0x02)
+	}
+
+	for i, b := range prog {
+		hi.Write(testOrigin+uint16(i), b)
+	}
+
+	// Vectors all point back at the program, it doesn't matter where we end up
+	for v := uint16(0xfffa); v != 0; v += 2 {
+		hi.Write(v, uint8(testOrigin&0xff))
+		hi.Write(v+1, uint8(testOrigin>>8))
+	}
+
+	c := New(sbus, variant)
+	c.Initialize()
+
+	for range 5 {
+		err := c.ExecuteFrame(&cycles)
+
+		if err != nil {
+			t.Fatalf("opcode 0x%02x: prelude failed: %s", inst.Opcode, err.Error())
+		}
+	}
+
+	next_pc := c.GetPC() + uint16(inst.Len)
+
+	err := c.ExecuteFrame(&cycles)
+
+	if err != nil {
+		t.Fatalf("opcode 0x%02x: %s", inst.Opcode, err.Error())
+	}
+
+	return cycles, inst.Mode == REL && c.GetPC() != next_pc
+}
+
+/*
+ * Run every opcode of every variant and compare the cycles it took
+ * against the datasheet tables above
+ */
+func TestCycles(t *testing.T) {
+	variants := map[string]Variant{
+		"2a03":  C6502_VARIANT_2A03,
+		"nmos":  C6502_VARIANT_NMOS,
+		"65c02": C6502_VARIANT_65C02,
+	}
+
+	for name, variant := range variants {
+		t.Run(name, func(t *testing.T) {
+			for op := range 256 {
+				if !HasOpcode(variant, byte(op)) {
+					continue
+				}
+
+				// JAMs never finish, there's nothing to count
+				if nmosCycleTable[op] == 0 && variant != C6502_VARIANT_65C02 {
+					continue
+				}
+
+				inst, _ := GetInstr(variant, byte(op))
+
+				for _, sc := range cycleScenarios(inst.Mode) {
+					got, taken := runCycleScenario(t, variant, &inst, sc)
+					want := expectedCycles(variant, byte(op), inst.Mode, sc.crossed, taken)
+
+					if got != want {
+						t.Errorf("opcode 0x%02x (crossed=%v taken=%v): took %d cycles, want %d",
+							op, sc.crossed, taken, got, want)
+					}
+				}
+			}
+		})
+	}
+}
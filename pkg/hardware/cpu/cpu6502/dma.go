@@ -0,0 +1,126 @@
+package cpu6502
+
+import "errors"
+
+/*
+ * OAM DMA
+ *
+ * Writing a page number to $4014 makes the 2A03 copy that whole page into
+ * PPU OAM through $2004. The DMA unit shares the bus with the CPU, so the
+ * CPU just sits there while it runs:
+ *
+ *   1 cycle to halt the CPU
+ *   1 more if the halt landed on a put cycle, so the reads line up
+ *   256 read/write pairs
+ *
+ * That's 513 or 514 cycles, which get billed to the instruction that
+ * wrote $4014.
+ *
+ * The DMC fetches its samples the same way, a byte at a time. Those stall
+ * the CPU for 4 cycles: halt, a dummy cycle, alignment and the read.
+ *
+ * See: https://www.nesdev.org/wiki/DMA
+ */
+
+const (
+	OAM_DMA_ADDR = 0x4014
+	OAM_DATA     = 0x2004
+
+	DMC_DMA_CYCLES = 4
+)
+
+/*
+ * Kick off a DMA from page @page. It starts once the current instruction
+ * is done
+ */
+func (c *CPU6502) StartOamDma(page uint8) {
+	c.dma_active = true
+	c.dma_halt = true
+	c.dma_page = page
+	c.dma_index = 0
+	c.dma_loaded = false
+}
+
+/*
+ * Do a single cycle of a running DMA. Reads happen on even (get) cycles,
+ * writes on odd (put) cycles
+ */
+func (c *CPU6502) dmaCycle() {
+	switch {
+	case c.dma_halt:
+		// The CPU was about to read, and it keeps doing that read while it's halted
+		c.read(c.registers.pc)
+		c.dma_halt = false
+	case !c.dma_loaded && (c.cycles&1) != 0:
+		// Alignment cycle
+		c.read(c.registers.pc)
+	case !c.dma_loaded:
+		c.dma_value = c.read(uint16(c.dma_page)<<8 | uint16(c.dma_index))
+		c.dma_loaded = true
+	default:
+		c.write(OAM_DATA, c.dma_value)
+		c.dma_loaded = false
+		c.dma_index++
+
+		if c.dma_index == 256 {
+			c.dma_active = false
+
+			// Whatever came in while we were stalled gets serviced right away
+			c.take_interrupt = c.take_interrupt || c.interruptPending()
+		}
+	}
+}
+
+/*
+ * Queue up a DMC sample fetch. It steals the next few cycles, wherever
+ * the CPU is in its instruction
+ */
+func (c *CPU6502) StartDmcDma(addr uint16, done func(value uint8)) {
+	c.dmc_pending = true
+	c.dmc_addr = addr
+	c.dmc_done = done
+	c.dmc_stall = DMC_DMA_CYCLES
+}
+
+func (c *CPU6502) dmcCycle() {
+	c.dmc_stall--
+
+	if c.dmc_stall > 0 {
+		c.read(c.registers.pc)
+		return
+	}
+
+	c.dmc_pending = false
+	c.dmc_done(c.read(c.dmc_addr))
+}
+
+/*
+ * The $4014 register on the CPU bus
+ */
+type OamDma struct {
+	cpu *CPU6502
+}
+
+func NewOamDma(c *CPU6502) *OamDma {
+	return &OamDma{
+		cpu: c,
+	}
+}
+
+// Write only, so reads give open bus
+func (dma *OamDma) Read(addr uint16, value *uint8) error {
+	return errors.New("cpu6502: OAM DMA register is write only")
+}
+
+func (dma *OamDma) Write(addr uint16, value uint8) error {
+	dma.cpu.StartOamDma(value)
+	return nil
+}
+
+func (dma *OamDma) StartAddr() uint16 {
+	return OAM_DMA_ADDR
+}
+
+func (dma *OamDma) EndAddr() uint16 {
+	return OAM_DMA_ADDR
+}
@@ -1,440 +1,149 @@
 package cpu6502
 
 import (
-	"fmt"
-
 	"github.com/beakeyz/gones-emu/pkg/debug"
-	"github.com/beakeyz/gones-emu/pkg/hardware/cpu"
 )
 
-func get16BitAddressLE(opperand []byte) uint16 {
-	// For example:
-	// f0 0f -> 0x0ff0
-	return uint16(opperand[1]) | (uint16(opperand[2]) << 8)
-}
-
-func getValueBasedOnOpperand(c *CPU6502, i *cpu.Instr, opperand []byte) (uint8, uint16) {
-	var value uint8 = 0
-	var address uint16 = 0
-
-	if i.Mode == IMM {
-		value = opperand[1]
-	} else {
-		switch i.Mode {
-		case REL:
-			offset := int8(opperand[1])
-
-			// I fucking hate this hack
-			if offset > 0 {
-				address = c.registers.pc + uint16(i.Len) + uint16(offset)
-			} else {
-				address = c.registers.pc + uint16(i.Len) - uint16(-offset)
-			}
-		case ZPG:
-			address = uint16(opperand[1]) % 256
-		case ZPX:
-			address = (uint16(opperand[1]) + uint16(c.registers.x)) % 256
-		case ZPY:
-			address = (uint16(opperand[1]) + uint16(c.registers.y)) % 256
-		case ABS:
-			// Grab the 16 bit address
-			address = get16BitAddressLE(opperand)
-		case ABX:
-			address = get16BitAddressLE(opperand) + uint16(c.registers.x)
-		case ABY:
-			address = get16BitAddressLE(opperand) + uint16(c.registers.y)
-		case IDX:
-			var placeholder_1 uint8 = 0
-			var placeholder_2 uint8 = 0
-			// First index into the zero page
-			address = (uint16(opperand[1]) + uint16(c.registers.x)) % 256
-
-			c.sbus.Read(address, &placeholder_1)
-
-			// Index one more to get the second byte of the absolute address
-			address = (uint16(opperand[1]) + (uint16(c.registers.x) + 1)) % 256
-
-			c.sbus.Read(address, &placeholder_2)
-
-			// Construct the full address
-			address = uint16(placeholder_1) | (uint16(placeholder_2) << 8)
-		case IDY:
-			var placeholder_1 uint8 = 0
-			var placeholder_2 uint8 = 0
-			// First index into the zero page
-			address = uint16(opperand[1]) % 256
-
-			c.sbus.Read(address, &placeholder_1)
-
-			// Index one more to get the second byte of the absolute address
-			address = (uint16(opperand[1]) + 1) % 256
-
-			c.sbus.Read(address, &placeholder_2)
-
-			// Construct the full address
-			address = uint16(placeholder_1) | (uint16(placeholder_2) << 8)
-			// Add the conents of the y register
-			address += uint16(c.registers.y)
-		}
-
-		// Read from the retrived address following the correct mode
-		c.sbus.Read(address, &value)
-	}
-
-	return value, address
-}
-
-func doNegativeCheck(c *CPU6502, value uint8) {
-	if (value & 0x80) == 0x80 {
-		c.SetFlag(C6502_FLAG_NEGATIVE)
-	} else {
-		c.ClearFlag(C6502_FLAG_NEGATIVE)
-	}
-}
+/*
+ * The SHA/SHX/SHY/TAS family stores @value & (H + 1), where H is the high
+ * byte of the unindexed base address. When indexing crosses a page, the
+ * stored value also ends up replacing the high byte of the target address
+ */
+func doUnstableStore(c *CPU6502, value uint8) uint8 {
+	value &= uint8(c.base>>8) + 1
 
-// Overflow check needs to happen before the accumulator is set
-func doOverflowCheck(c *CPU6502, prev_value uint16, value uint16) {
-	if prev_value <= 127 && (prev_value+value) >= 128 {
-		c.SetFlag(C6502_FLAG_OVERFLOW)
-	} else {
-		c.ClearFlag(C6502_FLAG_OVERFLOW)
+	if c.pageCrossed() {
+		c.addr = (uint16(value) << 8) | (c.addr & 0x00ff)
 	}
-}
 
-func doZeroCheck(c *CPU6502, value uint8) {
-	if value == 0 {
-		c.SetFlag(C6502_FLAG_ZERO)
-	} else {
-		c.ClearFlag(C6502_FLAG_ZERO)
-	}
+	return value
 }
 
-func doCarryCheck(c *CPU6502, value uint16) {
-	if value > 256 {
-		c.SetFlag(C6502_FLAG_CARRY)
-	} else {
-		c.ClearFlag(C6502_FLAG_CARRY)
-	}
+/*
+ * The B flag doesn't exist in the actual register, and bit 5 always reads as set
+ */
+func doPullFlags(c *CPU6502, value uint8) {
+	c.registers.p = (value & ^uint8(C6502_FLAG_BFLAG)) | C6502_FLAG_RESERVED
 }
 
+/*
+ * What every instruction actually does. The bus traffic around it (fetching
+ * operands, resolving addresses, dummy accesses) lives in the microcode, so
+ * these only ever see the final value
+ *
+ * NOTE: This list is indexed by instruction ID, so keep it in the same order
+ */
 var cpu6502_imp = []InstrImpl{
 	// Add memory to regs.a with cary
 	// A + M + C -> A, C
-	{Id: symADC, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-
-		debug.Log("Executing ADC instruction: ")
-
-		// Grab the value for this intruction
-		value, _ := getValueBasedOnOpperand(c, i, opperand)
-
-		// Add the two fuckers like they're u16 integers
-		sum := uint16(c.registers.a) + uint16(value)
-
-		carry_val := 0
-
-		if c.HasFlag(C6502_FLAG_CARRY) {
-			sum++
-			carry_val = 1
-		}
-
-		new_carry_val := 0
+	{Id: symADC, Read: func(c *CPU6502, value uint8) {
+		debug.Log("Executing ADC instruction: a:%d + m:%d\n", c.registers.a, value)
 
-		doCarryCheck(c, sum)
-
-		if c.HasFlag(C6502_FLAG_CARRY) {
-			new_carry_val = 1
-		}
-
-		debug.Log("a:%d + m:%d + c:%d => a:%d, c:%d\n", c.registers.a, value, carry_val, uint8(sum), new_carry_val)
-
-		// Overflow check needs to happen before the accumulator is set
-		doOverflowCheck(c, uint16(c.registers.a), sum)
-
-		// Set the registers correctly
-		c.registers.a = uint8(sum)
-
-		doNegativeCheck(c, c.registers.a)
-		doZeroCheck(c, c.registers.a)
-
-		return nil
+		doAddWithCarry(c, value)
 	}},
 	// And shit together
 	// A & M -> A
-	{Id: symAND, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-
-		debug.Log("Executing AND instruction")
-
-		// Get the value
-		value, _ := getValueBasedOnOpperand(c, i, opperand)
-
-		debug.Log("Result: %d & %d => %d\n", c.registers.a, value, (c.registers.a & value))
+	{Id: symAND, Read: func(c *CPU6502, value uint8) {
+		debug.Log("Executing AND instruction: %d & %d => %d\n", c.registers.a, value, (c.registers.a & value))
 
 		// Do the opperation
 		c.registers.a &= value
 
 		doZeroCheck(c, c.registers.a)
 		doNegativeCheck(c, c.registers.a)
-
-		return nil
 	}},
-	{Id: symASL, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-
-		debug.Log("ASL: mode=0x%x\n", i.Mode)
-
-		if i.Mode == ACC {
-			if (c.registers.a & 0x7) == 0x7 {
-				c.SetFlag(C6502_FLAG_CARRY)
-			}
-
-			c.registers.a <<= 1
-
-			// Do other checks
-			doNegativeCheck(c, c.registers.a)
-			doZeroCheck(c, c.registers.a)
-		} else {
-			address := get16BitAddressLE(opperand)
-			value, _ := getValueBasedOnOpperand(c, i, opperand)
-
-			// Do the cary check
-			if (value & 0x7) == 0x7 {
-				c.SetFlag(C6502_FLAG_CARRY)
-			}
-
-			// Shift the value
-			value <<= 1
-
-			// Do other checks
-			doNegativeCheck(c, value)
-			doZeroCheck(c, value)
-
-			// Write back to disk
-			c.sbus.Write(address, value)
-
-		}
+	{Id: symASL, Modify: func(c *CPU6502, value uint8) uint8 {
+		debug.Log("ASL: 0x%x\n", value)
 
-		return nil
+		return doShiftLeft(c, value)
 	}},
-	{Id: symBCC, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-
-		debug.Log("Executing BCC instruction\n")
-
-		// No need to do shit if there is no carry flag
-		if c.HasFlag(C6502_FLAG_CARRY) {
-			return nil
-		}
-
-		_, addr := getValueBasedOnOpperand(c, i, opperand)
-
-		c.next_pc = addr
-		return nil
+	{Id: symBCC, Branch: func(c *CPU6502) bool {
+		return !c.HasFlag(C6502_FLAG_CARRY)
 	}},
-	{Id: symBCS, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-
-		debug.Log("Executing BCS instruction\n")
-
-		// No need to do shit if there is a carry flag
-		if !c.HasFlag(C6502_FLAG_CARRY) {
-			return nil
-		}
-
-		_, addr := getValueBasedOnOpperand(c, i, opperand)
-		c.next_pc = addr
-		return nil
+	{Id: symBCS, Branch: func(c *CPU6502) bool {
+		return c.HasFlag(C6502_FLAG_CARRY)
 	}},
-	{Id: symBEQ, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-
-		debug.Log("executing BEQ\n")
-
-		// No need to do shit if there is a carry flag
-		if !c.HasFlag(C6502_FLAG_ZERO) {
-			return nil
-		}
-
-		_, addr := getValueBasedOnOpperand(c, i, opperand)
-		c.next_pc = addr
-		return nil
+	{Id: symBEQ, Branch: func(c *CPU6502) bool {
+		return c.HasFlag(C6502_FLAG_ZERO)
 	}},
-	{Id: symBIT, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
+	{Id: symBIT, Read: func(c *CPU6502, value uint8) {
 		debug.Log("executing BIT\n")
 
-		value, _ := getValueBasedOnOpperand(c, i, opperand)
-
 		result := c.registers.a & value
 
-		// Set bit 6 and 7 of the value into the status register
+		// Set bit 6 and 7 of the value into the status register. The 65C02s
+		// BIT #imm is the exception, that one only touches Z
 
-		c.registers.p = (c.registers.p & 0x3f) | (value & 0xC0)
+		if c.c_instr.Mode != IMM {
+			c.registers.p = (c.registers.p & 0x3f) | (value & 0xC0)
+		}
 
 		// Also perform zero check on this instruction
 
 		doZeroCheck(c, result)
-
-		// return fmt.Errorf("executing unimplemented instruction: %d", i.Instruction)
-		return nil
 	}},
-	{Id: symBMI, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-
-		debug.Log("executing BMI\n")
-
-		if !c.HasFlag(C6502_FLAG_NEGATIVE) {
-			return nil
-		}
-
-		_, addr := getValueBasedOnOpperand(c, i, opperand)
-		c.next_pc = addr
-		return nil
+	{Id: symBMI, Branch: func(c *CPU6502) bool {
+		return c.HasFlag(C6502_FLAG_NEGATIVE)
 	}},
-	{Id: symBNE, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		debug.Log("executing BNE\n")
-
-		if c.HasFlag(C6502_FLAG_ZERO) {
-			return nil
-		}
-
-		_, addr := getValueBasedOnOpperand(c, i, opperand)
-		c.next_pc = addr
-		return nil
+	{Id: symBNE, Branch: func(c *CPU6502) bool {
+		return !c.HasFlag(C6502_FLAG_ZERO)
 	}},
-	{Id: symBPL, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		debug.Log("executing BPL\n")
-
-		if c.HasFlag(C6502_FLAG_NEGATIVE) {
-			return nil
-		}
-
-		_, addr := getValueBasedOnOpperand(c, i, opperand)
-		c.next_pc = addr
-		return nil
+	{Id: symBPL, Branch: func(c *CPU6502) bool {
+		return !c.HasFlag(C6502_FLAG_NEGATIVE)
 	}},
-	{Id: symBRA, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		return fmt.Errorf("executing unimplemented instruction: %d", i.Instruction)
+	{Id: symBRA, Branch: func(c *CPU6502) bool {
+		return true
 	}},
-	{Id: symBRK, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		return fmt.Errorf("executing unimplemented instruction: %d", i.Instruction)
+	// BRK is all bus work, see brkMicrocode
+	{Id: symBRK},
+	{Id: symBVC, Branch: func(c *CPU6502) bool {
+		return !c.HasFlag(C6502_FLAG_OVERFLOW)
 	}},
-	{Id: symBVC, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		debug.Log("executing BVC\n")
-
-		if c.HasFlag(C6502_FLAG_OVERFLOW) {
-			return nil
-		}
-
-		_, addr := getValueBasedOnOpperand(c, i, opperand)
-		c.next_pc = addr
-		return nil
+	{Id: symBVS, Branch: func(c *CPU6502) bool {
+		return c.HasFlag(C6502_FLAG_OVERFLOW)
 	}},
-	{Id: symBVS, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		debug.Log("executing BVS\n")
-
-		if !c.HasFlag(C6502_FLAG_NEGATIVE) {
-			return nil
-		}
-
-		_, addr := getValueBasedOnOpperand(c, i, opperand)
-		c.next_pc = addr
-		return nil
-	}},
-	{Id: symCLC, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
+	{Id: symCLC, Implied: func(c *CPU6502) {
 		debug.Log("Clearing carry\n")
 		c.ClearFlag(C6502_FLAG_CARRY)
-		return nil
 	}},
-	{Id: symCLD, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
+	{Id: symCLD, Implied: func(c *CPU6502) {
 		debug.Log("Clearing decimal\n")
 		c.ClearFlag(C6502_FLAG_DECIMAL)
-		return nil
 	}},
-	{Id: symCLI, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
+	{Id: symCLI, Implied: func(c *CPU6502) {
 		debug.Log("Clearing interupt disable\n")
 		c.ClearFlag(C6502_FLAG_INTDISABLE)
-		return nil
 	}},
-	{Id: symCLV, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
+	{Id: symCLV, Implied: func(c *CPU6502) {
 		debug.Log("Clearing overflow\n")
 		c.ClearFlag(C6502_FLAG_OVERFLOW)
-		return nil
 	}},
-	{Id: symCMP, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		value, _ := getValueBasedOnOpperand(c, i, opperand)
-
-		newval := int8(c.registers.a) - int8(value)
-
-		debug.Log("Executing CMP: a:%d - m:%d = %d\n", c.registers.a, value, newval)
-
-		doNegativeCheck(c, uint8(newval))
-
-		c.ClearFlag(C6502_FLAG_CARRY)
-		c.ClearFlag(C6502_FLAG_ZERO)
-
-		if newval <= 0 {
-			c.SetFlag(C6502_FLAG_CARRY)
+	{Id: symCMP, Read: func(c *CPU6502, value uint8) {
+		debug.Log("Executing CMP: a:%d - m:%d\n", c.registers.a, value)
 
-			if newval == 0 {
-				c.SetFlag(C6502_FLAG_ZERO)
-			}
-		}
-
-		return nil
+		doCompare(c, c.registers.a, value)
 	}},
-	{Id: symCPX, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		value, _ := getValueBasedOnOpperand(c, i, opperand)
-
-		newval := int8(c.registers.x) - int8(value)
-
-		debug.Log("Executing CPX: x:%d - m:%d = %d\n", c.registers.y, value, newval)
-
-		doNegativeCheck(c, uint8(newval))
-
-		c.ClearFlag(C6502_FLAG_CARRY)
-		c.ClearFlag(C6502_FLAG_ZERO)
+	{Id: symCPX, Read: func(c *CPU6502, value uint8) {
+		debug.Log("Executing CPX: x:%d - m:%d\n", c.registers.x, value)
 
-		if newval <= 0 {
-			c.SetFlag(C6502_FLAG_CARRY)
-
-			if newval == 0 {
-				c.SetFlag(C6502_FLAG_ZERO)
-			}
-		}
-
-		return nil
+		doCompare(c, c.registers.x, value)
 	}},
-	{Id: symCPY, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		value, _ := getValueBasedOnOpperand(c, i, opperand)
-
-		newval := int8(c.registers.y) - int8(value)
-
-		debug.Log("Executing CPY: y:%d - m:%d = %d\n", c.registers.y, value, newval)
-
-		doNegativeCheck(c, uint8(newval))
-
-		c.ClearFlag(C6502_FLAG_CARRY)
-		c.ClearFlag(C6502_FLAG_ZERO)
+	{Id: symCPY, Read: func(c *CPU6502, value uint8) {
+		debug.Log("Executing CPY: y:%d - m:%d\n", c.registers.y, value)
 
-		if newval <= 0 {
-			c.SetFlag(C6502_FLAG_CARRY)
-
-			if newval == 0 {
-				c.SetFlag(C6502_FLAG_ZERO)
-			}
-		}
-
-		return nil
+		doCompare(c, c.registers.y, value)
 	}},
-	{Id: symDEC, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		value, addr := getValueBasedOnOpperand(c, i, opperand)
-
-		debug.Log("DEC: 0x%x - 1 -> 0x%x\n", value, addr)
-
+	{Id: symDEC, Modify: func(c *CPU6502, value uint8) uint8 {
 		// Do the decrement
 		value--
 
+		debug.Log("DEC: -> 0x%x\n", value)
+
 		doNegativeCheck(c, value)
 		doZeroCheck(c, value)
-
-		c.sbus.Write(addr, value)
-		return nil
+		return value
 	}},
-	{Id: symDEX, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
+	{Id: symDEX, Implied: func(c *CPU6502) {
 		debug.Log("DEX: x:0x%x - 1 -> x\n", c.registers.x)
 
 		// Do the decrement
@@ -442,9 +151,8 @@ var cpu6502_imp = []InstrImpl{
 
 		doNegativeCheck(c, c.registers.x)
 		doZeroCheck(c, c.registers.x)
-		return nil
 	}},
-	{Id: symDEY, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
+	{Id: symDEY, Implied: func(c *CPU6502) {
 		debug.Log("DEY: y:0x%x - 1 -> y\n", c.registers.y)
 
 		// Do the decrement
@@ -452,339 +160,421 @@ var cpu6502_imp = []InstrImpl{
 
 		doNegativeCheck(c, c.registers.y)
 		doZeroCheck(c, c.registers.y)
-		return nil
-	}},
-	{Id: symEOR, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		return fmt.Errorf("executing unimplemented instruction: %d", i.Instruction)
 	}},
-	{Id: symINC, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		value, addr := getValueBasedOnOpperand(c, i, opperand)
+	// A ^ M -> A
+	{Id: symEOR, Read: func(c *CPU6502, value uint8) {
+		debug.Log("EOR: a:%d ^ m:%d -> a\n", c.registers.a, value)
 
-		debug.Log("INC: 0x%x + 1 -> 0x%x\n", value, addr)
+		c.registers.a ^= value
 
-		// Do the decrement
+		doNegativeCheck(c, c.registers.a)
+		doZeroCheck(c, c.registers.a)
+	}},
+	{Id: symINC, Modify: func(c *CPU6502, value uint8) uint8 {
+		// Do the increment
 		value++
 
+		debug.Log("INC: -> 0x%x\n", value)
+
 		doNegativeCheck(c, value)
 		doZeroCheck(c, value)
-
-		c.sbus.Write(addr, value)
-		return nil
+		return value
 	}},
-	{Id: symINX, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
+	{Id: symINX, Implied: func(c *CPU6502) {
 		debug.Log("INX: x:0x%x + 1 -> x\n", c.registers.x)
 
-		// Do the decrement
+		// Do the increment
 		c.registers.x++
 
 		doNegativeCheck(c, c.registers.x)
 		doZeroCheck(c, c.registers.x)
-		return nil
 	}},
-	{Id: symINY, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
+	{Id: symINY, Implied: func(c *CPU6502) {
 		debug.Log("INY: y:0x%x + 1 -> y\n", c.registers.y)
 
-		// Do the decrement
+		// Do the increment
 		c.registers.y++
 
 		doNegativeCheck(c, c.registers.y)
 		doZeroCheck(c, c.registers.y)
-		return nil
-	}},
-	{Id: symJMP, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		address := get16BitAddressLE(opperand)
-
-		if i.Mode == IND {
-			var a uint8
-			var b uint8
-			c.sbus.Read(address, &a)
-			c.sbus.Read(address+1, &b)
-
-			debug.Log(" (Old: 0x%x) ", address)
-
-			address = uint16(a) | (uint16(b) << 8)
-		}
-
-		debug.Log("JMP: to addr: 0x%x\n", address)
-
-		c.next_pc = address
-		return nil
 	}},
-	{Id: symJSR, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		_, target_addr := getValueBasedOnOpperand(c, i, opperand)
-		return_addr := c.registers.pc + uint16(i.Len) - 1
-
-		debug.Log("Doing JSR: retaddr=0x%x, targetaddr=0x%x\n", return_addr, target_addr)
-
-		// Push the return address
-		c.doPush16(return_addr)
-
-		// Do the CPU push
-		c.next_pc = (target_addr)
-		return nil
-	}},
-	{Id: symLDA, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		value, _ := getValueBasedOnOpperand(c, i, opperand)
-
-		doZeroCheck(c, value)
-		doNegativeCheck(c, value)
-
+	// JMP, JSR, RTI and RTS only move the PC around, see their microcode
+	{Id: symJMP},
+	{Id: symJSR},
+	{Id: symLDA, Read: func(c *CPU6502, value uint8) {
 		debug.Log("Executing LDA v=0x%x\n", value)
 
 		c.registers.a = value
-		return nil
-	}},
-	{Id: symLDX, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		value, _ := getValueBasedOnOpperand(c, i, opperand)
 
 		doZeroCheck(c, value)
 		doNegativeCheck(c, value)
-
+	}},
+	{Id: symLDX, Read: func(c *CPU6502, value uint8) {
 		debug.Log("Executing LDX v: %d\n", value)
 
 		c.registers.x = value
-		return nil
-	}},
-	{Id: symLDY, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		value, _ := getValueBasedOnOpperand(c, i, opperand)
 
 		doZeroCheck(c, value)
 		doNegativeCheck(c, value)
-
+	}},
+	{Id: symLDY, Read: func(c *CPU6502, value uint8) {
 		debug.Log("Executing LDY v: %d\n", value)
 
 		c.registers.y = value
-		return nil
+
+		doZeroCheck(c, value)
+		doNegativeCheck(c, value)
 	}},
-	{Id: symLSR, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		return fmt.Errorf("executing unimplemented instruction: %d", i.Instruction)
+	{Id: symLSR, Modify: func(c *CPU6502, value uint8) uint8 {
+		debug.Log("LSR: 0x%x\n", value)
+
+		return doShiftRight(c, value)
 	}},
-	{Id: symNOP, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
+	// The unofficial NOPs with an operand still do the read, hence the Read
+	{Id: symNOP, Implied: func(c *CPU6502) {
+		debug.Log("NOPe")
+	}, Read: func(c *CPU6502, value uint8) {
 		debug.Log("NOPe")
-		return nil
 	}},
-	{Id: symORA, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		value, _ := getValueBasedOnOpperand(c, i, opperand)
-
+	{Id: symORA, Read: func(c *CPU6502, value uint8) {
 		// Do the OR opperation
-		result := value & c.registers.a
+		result := value | c.registers.a
 
-		debug.Log("ORA: m:%d & a:%d = a:%d\n", value, c.registers.a, result)
+		debug.Log("ORA: m:%d | a:%d = a:%d\n", value, c.registers.a, result)
 
 		doNegativeCheck(c, result)
 		doZeroCheck(c, result)
 
 		// Write back result
 		c.registers.a = result
-		return nil
 	}},
-	{Id: symPHA, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
+	{Id: symPHA, Write: func(c *CPU6502) uint8 {
 		debug.Log("PHA: a:%d\n", c.registers.a)
 
-		c.doPush8(c.registers.a)
-		return nil
+		return c.registers.a
 	}},
-	{Id: symPHP, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
+	{Id: symPHP, Write: func(c *CPU6502) uint8 {
 		debug.Log("PHP: p:0x%x\n", c.registers.p)
 
-		c.doPush8(c.registers.p)
-		return nil
+		// Software pushes always have the B flag set
+		return c.registers.p | C6502_FLAG_BFLAG | C6502_FLAG_RESERVED
 	}},
-	{Id: symPHX, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		// Illigal?
-		return fmt.Errorf("(PHX) executing unimplemented instruction: %d", i.Instruction)
+	{Id: symPHX, Write: func(c *CPU6502) uint8 {
+		debug.Log("PHX: x:%d\n", c.registers.x)
+
+		return c.registers.x
 	}},
-	{Id: symPHY, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		// Illigal?
-		return fmt.Errorf("(PHY) executing unimplemented instruction: %d", i.Instruction)
+	{Id: symPHY, Write: func(c *CPU6502) uint8 {
+		debug.Log("PHY: y:%d\n", c.registers.y)
+
+		return c.registers.y
 	}},
-	{Id: symPLA, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		var stacval byte
+	{Id: symPLA, Read: func(c *CPU6502, value uint8) {
+		debug.Log("PLA: s:%d -> a\n", value)
 
-		c.doPop8(&stacval)
+		c.registers.a = value
 
-		debug.Log("PLA: s:%d -> a:%d\n", stacval, c.registers.a)
+		doNegativeCheck(c, c.registers.a)
+		doZeroCheck(c, c.registers.a)
+	}},
+	{Id: symPLP, Read: func(c *CPU6502, value uint8) {
+		debug.Log("PLP: s:%d -> p:%d\n", value, c.registers.p)
 
-		c.registers.a = stacval
-		return nil
+		doPullFlags(c, value)
 	}},
-	{Id: symPLP, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		var stacval byte
+	{Id: symPLX, Read: func(c *CPU6502, value uint8) {
+		debug.Log("PLX: -> x:%d\n", value)
+
+		c.registers.x = value
 
-		c.doPop8(&stacval)
+		doNegativeCheck(c, c.registers.x)
+		doZeroCheck(c, c.registers.x)
+	}},
+	{Id: symPLY, Read: func(c *CPU6502, value uint8) {
+		debug.Log("PLY: -> y:%d\n", value)
 
-		debug.Log("PLP: s:%d -> p:%d\n", stacval, c.registers.p)
+		c.registers.y = value
 
-		c.registers.p = stacval & ^uint8(C6502_FLAG_BFLAG|C6502_FLAG_RESERVED)
-		return nil
+		doNegativeCheck(c, c.registers.y)
+		doZeroCheck(c, c.registers.y)
 	}},
-	{Id: symPLX, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		return fmt.Errorf("(PLX) executing unimplemented instruction: %d", i.Instruction)
+	{Id: symROL, Modify: func(c *CPU6502, value uint8) uint8 {
+		debug.Log("ROL: 0x%x\n", value)
+
+		return doRotateLeft(c, value)
 	}},
-	{Id: symPLY, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		return fmt.Errorf("(PLY) executing unimplemented instruction: %d", i.Instruction)
+	{Id: symROR, Modify: func(c *CPU6502, value uint8) uint8 {
+		debug.Log("ROR: 0x%x\n", value)
+
+		return doRotateRight(c, value)
 	}},
-	{Id: symROL, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
+	{Id: symRTI},
+	{Id: symRTS},
+	// A - M - ~C -> A
+	{Id: symSBC, Read: func(c *CPU6502, value uint8) {
+		debug.Log("SBC: a:%d - m:%d\n", c.registers.a, value)
 
-		caryfunc := func(val uint8) uint8 {
-			newval := val << 1
+		doSubtractWithCarry(c, value)
+	}},
+	{Id: symSEC, Implied: func(c *CPU6502) {
+		c.SetFlag(C6502_FLAG_CARRY)
+	}},
+	{Id: symSED, Implied: func(c *CPU6502) {
+		c.SetFlag(C6502_FLAG_DECIMAL)
+	}},
+	{Id: symSEI, Implied: func(c *CPU6502) {
+		c.SetFlag(C6502_FLAG_INTDISABLE)
+	}},
+	{Id: symSTA, Write: func(c *CPU6502) uint8 {
+		debug.Log("Executing STA: putting a:%d into 0x%x\n", c.registers.a, c.addr)
 
-			if c.HasFlag(C6502_FLAG_CARRY) {
-				newval |= 1
-			}
+		return c.registers.a
+	}},
+	{Id: symSTZ, Write: func(c *CPU6502) uint8 {
+		debug.Log("Executing STZ: clearing 0x%x\n", c.addr)
 
-			if (val & 0x7) == 0x7 {
-				c.SetFlag(C6502_FLAG_CARRY)
-			} else {
-				c.ClearFlag(C6502_FLAG_CARRY)
-			}
+		return 0
+	}},
+	{Id: symSTX, Write: func(c *CPU6502) uint8 {
+		debug.Log("Executing STX: putting x:%d into 0x%x\n", c.registers.x, c.addr)
 
-			return newval
-		}
+		return c.registers.x
+	}},
+	{Id: symSTY, Write: func(c *CPU6502) uint8 {
+		debug.Log("Executing STY: putting y:%d into 0x%x\n", c.registers.y, c.addr)
 
-		debug.Log("ROL: rotating mode: %d\n", i.Mode)
+		return c.registers.y
+	}},
+	{Id: symTAX, Implied: func(c *CPU6502) {
+		debug.Log("TAX: a:0x%x -> x\n", c.registers.a)
 
-		if i.Mode == ACC {
-			c.registers.a = caryfunc(c.registers.a)
-		} else {
-			val, addr := getValueBasedOnOpperand(c, i, opperand)
+		c.registers.x = c.registers.a
 
-			// Calculate new value
-			val = caryfunc(val)
+		doNegativeCheck(c, c.registers.x)
+		doZeroCheck(c, c.registers.x)
+	}},
+	{Id: symTAY, Implied: func(c *CPU6502) {
+		debug.Log("TAY: a:0x%x -> y\n", c.registers.a)
 
-			c.sbus.Write(addr, val)
-		}
+		c.registers.y = c.registers.a
 
-		return nil
+		doNegativeCheck(c, c.registers.y)
+		doZeroCheck(c, c.registers.y)
 	}},
-	{Id: symROR, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		caryfunc := func(val uint8) uint8 {
-			newval := val >> 1
-
-			if c.HasFlag(C6502_FLAG_CARRY) {
-				newval |= 0x7
-			}
+	// Test and reset bits: Z from A & M, then M & ~A -> M
+	{Id: symTRB, Modify: func(c *CPU6502, value uint8) uint8 {
+		doZeroCheck(c, c.registers.a&value)
 
-			if (val & 0x1) == 0x1 {
-				c.SetFlag(C6502_FLAG_CARRY)
-			} else {
-				c.ClearFlag(C6502_FLAG_CARRY)
-			}
+		return value & ^c.registers.a
+	}},
+	// Test and set bits: Z from A & M, then M | A -> M
+	{Id: symTSB, Modify: func(c *CPU6502, value uint8) uint8 {
+		doZeroCheck(c, c.registers.a&value)
 
-			return newval
-		}
+		return value | c.registers.a
+	}},
+	{Id: symTSX, Implied: func(c *CPU6502) {
+		debug.Log("TSX: s:0x%x -> x\n", c.registers.s)
 
-		debug.Log("ROR: rotating mode: %d\n", i.Mode)
+		c.registers.x = c.registers.s
 
-		if i.Mode == ACC {
-			c.registers.a = caryfunc(c.registers.a)
-		} else {
-			val, addr := getValueBasedOnOpperand(c, i, opperand)
+		doNegativeCheck(c, c.registers.x)
+		doZeroCheck(c, c.registers.x)
+	}},
+	{Id: symTXA, Implied: func(c *CPU6502) {
+		debug.Log("TXA: x:0x%x -> a\n", c.registers.x)
 
-			// Calculate new value
-			val = caryfunc(val)
+		c.registers.a = c.registers.x
 
-			c.sbus.Write(addr, val)
-		}
+		doNegativeCheck(c, c.registers.a)
+		doZeroCheck(c, c.registers.a)
+	}},
+	{Id: symTXS, Implied: func(c *CPU6502) {
+		debug.Log("TXS: x:0x%x -> s\n", c.registers.x)
 
-		return nil
+		// The only transfer that doesn't touch the flags
+		c.registers.s = c.registers.x
 	}},
-	{Id: symRTI, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
+	{Id: symTYA, Implied: func(c *CPU6502) {
+		debug.Log("TYA: y:0x%x -> a\n", c.registers.y)
 
-		var pc byte
+		c.registers.a = c.registers.y
+
+		doNegativeCheck(c, c.registers.a)
+		doZeroCheck(c, c.registers.a)
+	}},
 
-		c.doPop8(&c.registers.p)
-		c.doPop8(&pc)
+	/*
+	 * Unofficial opcodes. Most of these are two official instructions
+	 * glued together, since the decode ROM happily enables both at once
+	 */
 
-		debug.Log("RTI: returning to pc:%d\n", pc)
+	// A & M, then LSR A
+	{Id: symALR, Read: func(c *CPU6502, value uint8) {
+		debug.Log("ALR: (a:%d & m:%d) >> 1\n", c.registers.a, value)
 
-		// Set the pc
-		c.registers.pc = uint16(pc)
-		return nil
+		c.registers.a = doShiftRight(c, c.registers.a&value)
 	}},
-	{Id: symRTS, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		// Pop this shit back
-		c.doPop16(&c.next_pc)
+	// A & M, with bit 7 of the result copied into the carry
+	{Id: symANC, Read: func(c *CPU6502, value uint8) {
+		debug.Log("ANC: a:%d & m:%d\n", c.registers.a, value)
 
-		// Add a bit lul
-		c.next_pc++
+		c.registers.a &= value
 
-		debug.Log("RTS: returning to pc:%d\n", c.registers.pc)
-		return nil
+		doNegativeCheck(c, c.registers.a)
+		doZeroCheck(c, c.registers.a)
+		doSetCarry(c, (c.registers.a&0x80) == 0x80)
 	}},
-	{Id: symSBC, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		return fmt.Errorf("executing unimplemented instruction: %d", i.Instruction)
+	// A & M, then ROR A. C and V come from the adder though: C is bit 6, V is bit 6 ^ bit 5
+	{Id: symARR, Read: func(c *CPU6502, value uint8) {
+		debug.Log("ARR: (a:%d & m:%d) ror 1\n", c.registers.a, value)
+
+		c.registers.a = doRotateRight(c, c.registers.a&value)
+
+		doSetCarry(c, (c.registers.a&0x40) == 0x40)
+
+		if ((c.registers.a>>6)^(c.registers.a>>5))&0x01 == 0x01 {
+			c.SetFlag(C6502_FLAG_OVERFLOW)
+		} else {
+			c.ClearFlag(C6502_FLAG_OVERFLOW)
+		}
 	}},
-	{Id: symSEC, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		c.SetFlag(C6502_FLAG_CARRY)
-		return nil
+	// (A & X) - M -> X, setting flags like CMP does
+	{Id: symAXS, Read: func(c *CPU6502, value uint8) {
+		debug.Log("AXS: (a:%d & x:%d) - m:%d -> x\n", c.registers.a, c.registers.x, value)
+
+		doCompare(c, c.registers.a&c.registers.x, value)
+
+		c.registers.x = (c.registers.a & c.registers.x) - value
 	}},
-	{Id: symSED, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		c.SetFlag(C6502_FLAG_DECIMAL)
-		return nil
+	// DEC M, then CMP M
+	{Id: symDCP, Modify: func(c *CPU6502, value uint8) uint8 {
+		value--
+
+		debug.Log("DCP: a:%d cmp m:%d\n", c.registers.a, value)
+
+		doCompare(c, c.registers.a, value)
+		return value
 	}},
-	{Id: symSEI, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		c.SetFlag(C6502_FLAG_INTDISABLE)
-		return nil
+	// INC M, then SBC M
+	{Id: symISC, Modify: func(c *CPU6502, value uint8) uint8 {
+		value++
+
+		debug.Log("ISC: a:%d - m:%d\n", c.registers.a, value)
+
+		doSubtractWithCarry(c, value)
+		return value
 	}},
-	{Id: symSTA, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
+	// JAM locks up the CPU, see jamMicrocode
+	{Id: symJAM},
+	// M & S -> A, X, S
+	{Id: symLAS, Read: func(c *CPU6502, value uint8) {
+		value &= c.registers.s
 
-		_, addr := getValueBasedOnOpperand(c, i, opperand)
+		debug.Log("LAS: -> a, x, s:%d\n", value)
 
-		debug.Log("Executing STA(%d): putting a:%d into 0x%x\n", i.Mode, c.registers.a, addr)
+		c.registers.a = value
+		c.registers.x = value
+		c.registers.s = value
 
-		c.sbus.Write(addr, c.registers.a)
-		return nil
+		doNegativeCheck(c, value)
+		doZeroCheck(c, value)
 	}},
-	{Id: symSTZ, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		return fmt.Errorf("(STZ) executing unimplemented instruction: %d", i.Instruction)
+	// LDA and LDX at the same time
+	{Id: symLAX, Read: func(c *CPU6502, value uint8) {
+		debug.Log("LAX: v:%d -> a, x\n", value)
+
+		c.registers.a = value
+		c.registers.x = value
+
+		doNegativeCheck(c, value)
+		doZeroCheck(c, value)
 	}},
-	{Id: symSTX, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		_, addr := getValueBasedOnOpperand(c, i, opperand)
+	// (A | magic) & M -> A, X. The 2A03 seems to use 0xff for the magic constant
+	{Id: symLXA, Read: func(c *CPU6502, value uint8) {
+		value &= c.registers.a | 0xff
 
-		debug.Log("Executing STX: putting x:%d into 0x%x\n", c.registers.x, addr)
+		debug.Log("LXA: v:%d -> a, x\n", value)
+
+		c.registers.a = value
+		c.registers.x = value
 
-		c.sbus.Write(addr, c.registers.x)
-		return nil
+		doNegativeCheck(c, value)
+		doZeroCheck(c, value)
 	}},
-	{Id: symSTY, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		_, addr := getValueBasedOnOpperand(c, i, opperand)
+	// ROL M, then AND M
+	{Id: symRLA, Modify: func(c *CPU6502, value uint8) uint8 {
+		value = doRotateLeft(c, value)
+
+		debug.Log("RLA: a:%d & m:%d\n", c.registers.a, value)
 
-		debug.Log("Executing STY: putting y:%d into 0x%x\n", c.registers.y, addr)
+		c.registers.a &= value
 
-		c.sbus.Write(addr, c.registers.y)
-		return nil
+		doNegativeCheck(c, c.registers.a)
+		doZeroCheck(c, c.registers.a)
+		return value
 	}},
-	{Id: symTAX, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		return fmt.Errorf("executing unimplemented instruction: %d", i.Instruction)
+	// ROR M, then ADC M
+	{Id: symRRA, Modify: func(c *CPU6502, value uint8) uint8 {
+		value = doRotateRight(c, value)
+
+		debug.Log("RRA: a:%d + m:%d\n", c.registers.a, value)
+
+		doAddWithCarry(c, value)
+		return value
 	}},
-	{Id: symTAY, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		return fmt.Errorf("executing unimplemented instruction: %d", i.Instruction)
+	// A & X -> M, no flags
+	{Id: symSAX, Write: func(c *CPU6502) uint8 {
+		debug.Log("SAX: a:%d & x:%d -> 0x%x\n", c.registers.a, c.registers.x, c.addr)
+
+		return c.registers.a & c.registers.x
 	}},
-	{Id: symTRB, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		return fmt.Errorf("executing unimplemented instruction: %d", i.Instruction)
+	{Id: symSHA, Write: func(c *CPU6502) uint8 {
+		return doUnstableStore(c, c.registers.a&c.registers.x)
 	}},
-	{Id: symTSB, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		return fmt.Errorf("executing unimplemented instruction: %d", i.Instruction)
+	{Id: symSHX, Write: func(c *CPU6502) uint8 {
+		return doUnstableStore(c, c.registers.x)
 	}},
-	{Id: symTSX, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		return fmt.Errorf("executing unimplemented instruction: %d", i.Instruction)
+	{Id: symSHY, Write: func(c *CPU6502) uint8 {
+		return doUnstableStore(c, c.registers.y)
 	}},
-	{Id: symTXA, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		// return fmt.Errorf("executing unimplemented instruction: %d", i.Instruction)
-		debug.Log("TSA: pushing a=0x%x on the stack\n", c.registers.a)
+	// ASL M, then ORA M
+	{Id: symSLO, Modify: func(c *CPU6502, value uint8) uint8 {
+		value = doShiftLeft(c, value)
+
+		debug.Log("SLO: a:%d | m:%d\n", c.registers.a, value)
+
+		c.registers.a |= value
 
-		c.doPush8(c.registers.a)
-		return nil
+		doNegativeCheck(c, c.registers.a)
+		doZeroCheck(c, c.registers.a)
+		return value
 	}},
-	{Id: symTXS, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
+	// LSR M, then EOR M
+	{Id: symSRE, Modify: func(c *CPU6502, value uint8) uint8 {
+		value = doShiftRight(c, value)
 
-		debug.Log("TSX: pushing x=0x%x on the stack\n", c.registers.x)
+		debug.Log("SRE: a:%d ^ m:%d\n", c.registers.a, value)
 
-		c.doPush8(c.registers.x)
-		return nil
+		c.registers.a ^= value
+
+		doNegativeCheck(c, c.registers.a)
+		doZeroCheck(c, c.registers.a)
+		return value
+	}},
+	// A & X -> S, then SHA with the new S
+	{Id: symTAS, Write: func(c *CPU6502) uint8 {
+		c.registers.s = c.registers.a & c.registers.x
+
+		return doUnstableStore(c, c.registers.s)
 	}},
-	{Id: symTYA, Impl: func(c *CPU6502, i *cpu.Instr, opperand []byte) error {
-		return fmt.Errorf("executing unimplemented instruction: %d", i.Instruction)
+	// (A | magic) & X & M -> A. Magic is usually 0xee
+	{Id: symXAA, Read: func(c *CPU6502, value uint8) {
+		c.registers.a = (c.registers.a | 0xee) & c.registers.x & value
+
+		debug.Log("XAA: -> a:%d\n", c.registers.a)
+
+		doNegativeCheck(c, c.registers.a)
+		doZeroCheck(c, c.registers.a)
 	}},
 }
@@ -77,8 +77,129 @@ const (
 	symTXA
 	symTXS
 	symTYA
+
+	/* Unofficial NMOS opcodes */
+	symALR
+	symANC
+	symARR
+	symAXS
+	symDCP
+	symISC
+	symJAM
+	symLAS
+	symLAX
+	symLXA
+	symRLA
+	symRRA
+	symSAX
+	symSHA
+	symSHX
+	symSHY
+	symSLO
+	symSRE
+	symTAS
+	symXAA
 )
 
+/*
+ * Mnemonics, for disassembly. ISC goes by ISB here, since that's what
+ * nestest.log calls it
+ */
+var instrNames = [...]string{
+	symADC: "ADC",
+	symAND: "AND",
+	symASL: "ASL",
+	symBCC: "BCC",
+	symBCS: "BCS",
+	symBEQ: "BEQ",
+	symBIT: "BIT",
+	symBMI: "BMI",
+	symBNE: "BNE",
+	symBPL: "BPL",
+	symBRA: "BRA",
+	symBRK: "BRK",
+	symBVC: "BVC",
+	symBVS: "BVS",
+	symCLC: "CLC",
+	symCLD: "CLD",
+	symCLI: "CLI",
+	symCLV: "CLV",
+	symCMP: "CMP",
+	symCPX: "CPX",
+	symCPY: "CPY",
+	symDEC: "DEC",
+	symDEX: "DEX",
+	symDEY: "DEY",
+	symEOR: "EOR",
+	symINC: "INC",
+	symINX: "INX",
+	symINY: "INY",
+	symJMP: "JMP",
+	symJSR: "JSR",
+	symLDA: "LDA",
+	symLDX: "LDX",
+	symLDY: "LDY",
+	symLSR: "LSR",
+	symNOP: "NOP",
+	symORA: "ORA",
+	symPHA: "PHA",
+	symPHP: "PHP",
+	symPHX: "PHX",
+	symPHY: "PHY",
+	symPLA: "PLA",
+	symPLP: "PLP",
+	symPLX: "PLX",
+	symPLY: "PLY",
+	symROL: "ROL",
+	symROR: "ROR",
+	symRTI: "RTI",
+	symRTS: "RTS",
+	symSBC: "SBC",
+	symSEC: "SEC",
+	symSED: "SED",
+	symSEI: "SEI",
+	symSTA: "STA",
+	symSTZ: "STZ",
+	symSTX: "STX",
+	symSTY: "STY",
+	symTAX: "TAX",
+	symTAY: "TAY",
+	symTRB: "TRB",
+	symTSB: "TSB",
+	symTSX: "TSX",
+	symTXA: "TXA",
+	symTXS: "TXS",
+	symTYA: "TYA",
+	symALR: "ALR",
+	symANC: "ANC",
+	symARR: "ARR",
+	symAXS: "AXS",
+	symDCP: "DCP",
+	symISC: "ISB",
+	symJAM: "JAM",
+	symLAS: "LAS",
+	symLAX: "LAX",
+	symLXA: "LXA",
+	symRLA: "RLA",
+	symRRA: "RRA",
+	symSAX: "SAX",
+	symSHA: "SHA",
+	symSHX: "SHX",
+	symSHY: "SHY",
+	symSLO: "SLO",
+	symSRE: "SRE",
+	symTAS: "TAS",
+	symXAA: "XAA",
+}
+
+func InstrName(id cpu.InstrID) string {
+	if int(id) >= len(instrNames) {
+		return "???"
+	}
+
+	return instrNames[id]
+}
+
 const (
 	IMM cpu.AddrMode = iota // Immediate
 	IMP                     // Implied (no operand)
@@ -93,10 +214,26 @@ const (
 	IDX                     // (Indirect,X)
 	IDY                     // (Indirect),Y
 	ACC                     // Accumulator (no operand)
+	IZP                     // (Zero Page), 65C02 only
+	IAX                     // (Absolute,X), 65C02 only
+)
+
+/*
+ * The flavours of 6502 this package can pretend to be
+ */
+type Variant byte
+
+const (
+	/* Ricoh 2A03, the NES CPU. An NMOS 6502 with the decimal mode cut out */
+	C6502_VARIANT_2A03 Variant = iota
+	/* Plain NMOS 6502 */
+	C6502_VARIANT_NMOS
+	/* CMOS 65C02, which adds a handful of instructions and the (zp) mode */
+	C6502_VARIANT_65C02
 )
 
-// All valid (opcode, mode) pairs
-var cpuInstructions = []cpu.Instr{
+// All valid (opcode, mode) pairs of the original NMOS 6502
+var nmosInstructions = []cpu.Instr{
 	{Instruction: symLDA, Mode: IMM, Opcode: 0xa9, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
 	{Instruction: symLDA, Mode: ZPG, Opcode: 0xa5, Len: 2, Cycles: 3, Pb_cross_cycles: 0},
 	{Instruction: symLDA, Mode: ZPX, Opcode: 0xb5, Len: 2, Cycles: 4, Pb_cross_cycles: 0},
@@ -105,7 +242,6 @@ var cpuInstructions = []cpu.Instr{
 	{Instruction: symLDA, Mode: ABY, Opcode: 0xb9, Len: 3, Cycles: 4, Pb_cross_cycles: 1},
 	{Instruction: symLDA, Mode: IDX, Opcode: 0xa1, Len: 2, Cycles: 6, Pb_cross_cycles: 0},
 	{Instruction: symLDA, Mode: IDY, Opcode: 0xb1, Len: 2, Cycles: 5, Pb_cross_cycles: 1},
-	{Instruction: symLDA, Mode: IND, Opcode: 0xb2, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
 
 	{Instruction: symLDX, Mode: IMM, Opcode: 0xa2, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
 	{Instruction: symLDX, Mode: ZPG, Opcode: 0xa6, Len: 2, Cycles: 3, Pb_cross_cycles: 0},
@@ -126,7 +262,6 @@ var cpuInstructions = []cpu.Instr{
 	{Instruction: symSTA, Mode: ABY, Opcode: 0x99, Len: 3, Cycles: 5, Pb_cross_cycles: 0},
 	{Instruction: symSTA, Mode: IDX, Opcode: 0x81, Len: 2, Cycles: 6, Pb_cross_cycles: 0},
 	{Instruction: symSTA, Mode: IDY, Opcode: 0x91, Len: 2, Cycles: 6, Pb_cross_cycles: 0},
-	{Instruction: symSTA, Mode: IND, Opcode: 0x92, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
 
 	{Instruction: symSTX, Mode: ZPG, Opcode: 0x86, Len: 2, Cycles: 3, Pb_cross_cycles: 0},
 	{Instruction: symSTX, Mode: ZPY, Opcode: 0x96, Len: 2, Cycles: 4, Pb_cross_cycles: 0},
@@ -136,11 +271,6 @@ var cpuInstructions = []cpu.Instr{
 	{Instruction: symSTY, Mode: ZPX, Opcode: 0x94, Len: 2, Cycles: 4, Pb_cross_cycles: 0},
 	{Instruction: symSTY, Mode: ABS, Opcode: 0x8c, Len: 3, Cycles: 4, Pb_cross_cycles: 0},
 
-	{Instruction: symSTZ, Mode: ZPG, Opcode: 0x64, Len: 2, Cycles: 3, Pb_cross_cycles: 0},
-	{Instruction: symSTZ, Mode: ZPX, Opcode: 0x74, Len: 2, Cycles: 4, Pb_cross_cycles: 0},
-	{Instruction: symSTZ, Mode: ABS, Opcode: 0x9c, Len: 3, Cycles: 4, Pb_cross_cycles: 0},
-	{Instruction: symSTZ, Mode: ABX, Opcode: 0x9e, Len: 3, Cycles: 5, Pb_cross_cycles: 0},
-
 	{Instruction: symADC, Mode: IMM, Opcode: 0x69, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
 	{Instruction: symADC, Mode: ZPG, Opcode: 0x65, Len: 2, Cycles: 3, Pb_cross_cycles: 0},
 	{Instruction: symADC, Mode: ZPX, Opcode: 0x75, Len: 2, Cycles: 4, Pb_cross_cycles: 0},
@@ -149,7 +279,6 @@ var cpuInstructions = []cpu.Instr{
 	{Instruction: symADC, Mode: ABY, Opcode: 0x79, Len: 3, Cycles: 4, Pb_cross_cycles: 1},
 	{Instruction: symADC, Mode: IDX, Opcode: 0x61, Len: 2, Cycles: 6, Pb_cross_cycles: 0},
 	{Instruction: symADC, Mode: IDY, Opcode: 0x71, Len: 2, Cycles: 5, Pb_cross_cycles: 1},
-	{Instruction: symADC, Mode: IND, Opcode: 0x72, Len: 2, Cycles: 5, Pb_cross_cycles: 1},
 
 	{Instruction: symSBC, Mode: IMM, Opcode: 0xe9, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
 	{Instruction: symSBC, Mode: ZPG, Opcode: 0xe5, Len: 2, Cycles: 3, Pb_cross_cycles: 0},
@@ -159,7 +288,6 @@ var cpuInstructions = []cpu.Instr{
 	{Instruction: symSBC, Mode: ABY, Opcode: 0xf9, Len: 3, Cycles: 4, Pb_cross_cycles: 1},
 	{Instruction: symSBC, Mode: IDX, Opcode: 0xe1, Len: 2, Cycles: 6, Pb_cross_cycles: 0},
 	{Instruction: symSBC, Mode: IDY, Opcode: 0xf1, Len: 2, Cycles: 5, Pb_cross_cycles: 1},
-	{Instruction: symSBC, Mode: IND, Opcode: 0xf2, Len: 2, Cycles: 5, Pb_cross_cycles: 1},
 
 	{Instruction: symCMP, Mode: IMM, Opcode: 0xc9, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
 	{Instruction: symCMP, Mode: ZPG, Opcode: 0xc5, Len: 2, Cycles: 3, Pb_cross_cycles: 0},
@@ -169,7 +297,6 @@ var cpuInstructions = []cpu.Instr{
 	{Instruction: symCMP, Mode: ABY, Opcode: 0xd9, Len: 3, Cycles: 4, Pb_cross_cycles: 1},
 	{Instruction: symCMP, Mode: IDX, Opcode: 0xc1, Len: 2, Cycles: 6, Pb_cross_cycles: 0},
 	{Instruction: symCMP, Mode: IDY, Opcode: 0xd1, Len: 2, Cycles: 5, Pb_cross_cycles: 1},
-	{Instruction: symCMP, Mode: IND, Opcode: 0xd2, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
 
 	{Instruction: symCPX, Mode: IMM, Opcode: 0xe0, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
 	{Instruction: symCPX, Mode: ZPG, Opcode: 0xe4, Len: 2, Cycles: 3, Pb_cross_cycles: 0},
@@ -179,11 +306,8 @@ var cpuInstructions = []cpu.Instr{
 	{Instruction: symCPY, Mode: ZPG, Opcode: 0xc4, Len: 2, Cycles: 3, Pb_cross_cycles: 0},
 	{Instruction: symCPY, Mode: ABS, Opcode: 0xcc, Len: 3, Cycles: 4, Pb_cross_cycles: 0},
 
-	{Instruction: symBIT, Mode: IMM, Opcode: 0x89, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
 	{Instruction: symBIT, Mode: ZPG, Opcode: 0x24, Len: 2, Cycles: 3, Pb_cross_cycles: 0},
-	{Instruction: symBIT, Mode: ZPX, Opcode: 0x34, Len: 2, Cycles: 4, Pb_cross_cycles: 0},
 	{Instruction: symBIT, Mode: ABS, Opcode: 0x2c, Len: 3, Cycles: 4, Pb_cross_cycles: 0},
-	{Instruction: symBIT, Mode: ABX, Opcode: 0x3c, Len: 3, Cycles: 4, Pb_cross_cycles: 1},
 
 	{Instruction: symCLC, Mode: IMP, Opcode: 0x18, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
 	{Instruction: symSEC, Mode: IMP, Opcode: 0x38, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
@@ -201,7 +325,6 @@ var cpuInstructions = []cpu.Instr{
 	{Instruction: symBPL, Mode: REL, Opcode: 0x10, Len: 2, Cycles: 2, Pb_cross_cycles: 1},
 	{Instruction: symBVC, Mode: REL, Opcode: 0x50, Len: 2, Cycles: 2, Pb_cross_cycles: 1},
 	{Instruction: symBVS, Mode: REL, Opcode: 0x70, Len: 2, Cycles: 2, Pb_cross_cycles: 1},
-	{Instruction: symBRA, Mode: REL, Opcode: 0x80, Len: 2, Cycles: 2, Pb_cross_cycles: 1},
 
 	{Instruction: symBRK, Mode: IMP, Opcode: 0x00, Len: 1, Cycles: 7, Pb_cross_cycles: 0},
 
@@ -213,7 +336,6 @@ var cpuInstructions = []cpu.Instr{
 	{Instruction: symAND, Mode: ABY, Opcode: 0x39, Len: 3, Cycles: 4, Pb_cross_cycles: 1},
 	{Instruction: symAND, Mode: IDX, Opcode: 0x21, Len: 2, Cycles: 6, Pb_cross_cycles: 0},
 	{Instruction: symAND, Mode: IDY, Opcode: 0x31, Len: 2, Cycles: 5, Pb_cross_cycles: 1},
-	{Instruction: symAND, Mode: IND, Opcode: 0x32, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
 
 	{Instruction: symORA, Mode: IMM, Opcode: 0x09, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
 	{Instruction: symORA, Mode: ZPG, Opcode: 0x05, Len: 2, Cycles: 3, Pb_cross_cycles: 0},
@@ -223,7 +345,6 @@ var cpuInstructions = []cpu.Instr{
 	{Instruction: symORA, Mode: ABY, Opcode: 0x19, Len: 3, Cycles: 4, Pb_cross_cycles: 1},
 	{Instruction: symORA, Mode: IDX, Opcode: 0x01, Len: 2, Cycles: 6, Pb_cross_cycles: 0},
 	{Instruction: symORA, Mode: IDY, Opcode: 0x11, Len: 2, Cycles: 5, Pb_cross_cycles: 1},
-	{Instruction: symORA, Mode: IND, Opcode: 0x12, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
 
 	{Instruction: symEOR, Mode: IMM, Opcode: 0x49, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
 	{Instruction: symEOR, Mode: ZPG, Opcode: 0x45, Len: 2, Cycles: 3, Pb_cross_cycles: 0},
@@ -233,19 +354,16 @@ var cpuInstructions = []cpu.Instr{
 	{Instruction: symEOR, Mode: ABY, Opcode: 0x59, Len: 3, Cycles: 4, Pb_cross_cycles: 1},
 	{Instruction: symEOR, Mode: IDX, Opcode: 0x41, Len: 2, Cycles: 6, Pb_cross_cycles: 0},
 	{Instruction: symEOR, Mode: IDY, Opcode: 0x51, Len: 2, Cycles: 5, Pb_cross_cycles: 1},
-	{Instruction: symEOR, Mode: IND, Opcode: 0x52, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
 
 	{Instruction: symINC, Mode: ZPG, Opcode: 0xe6, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
 	{Instruction: symINC, Mode: ZPX, Opcode: 0xf6, Len: 2, Cycles: 6, Pb_cross_cycles: 0},
 	{Instruction: symINC, Mode: ABS, Opcode: 0xee, Len: 3, Cycles: 6, Pb_cross_cycles: 0},
 	{Instruction: symINC, Mode: ABX, Opcode: 0xfe, Len: 3, Cycles: 7, Pb_cross_cycles: 0},
-	{Instruction: symINC, Mode: ACC, Opcode: 0x1a, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
 
 	{Instruction: symDEC, Mode: ZPG, Opcode: 0xc6, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
 	{Instruction: symDEC, Mode: ZPX, Opcode: 0xd6, Len: 2, Cycles: 6, Pb_cross_cycles: 0},
 	{Instruction: symDEC, Mode: ABS, Opcode: 0xce, Len: 3, Cycles: 6, Pb_cross_cycles: 0},
 	{Instruction: symDEC, Mode: ABX, Opcode: 0xde, Len: 3, Cycles: 7, Pb_cross_cycles: 0},
-	{Instruction: symDEC, Mode: ACC, Opcode: 0x3a, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
 
 	{Instruction: symINX, Mode: IMP, Opcode: 0xe8, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
 	{Instruction: symINY, Mode: IMP, Opcode: 0xc8, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
@@ -254,7 +372,6 @@ var cpuInstructions = []cpu.Instr{
 	{Instruction: symDEY, Mode: IMP, Opcode: 0x88, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
 
 	{Instruction: symJMP, Mode: ABS, Opcode: 0x4c, Len: 3, Cycles: 3, Pb_cross_cycles: 0},
-	{Instruction: symJMP, Mode: ABX, Opcode: 0x7c, Len: 3, Cycles: 6, Pb_cross_cycles: 0},
 	{Instruction: symJMP, Mode: IND, Opcode: 0x6c, Len: 3, Cycles: 5, Pb_cross_cycles: 0},
 
 	{Instruction: symJSR, Mode: ABS, Opcode: 0x20, Len: 3, Cycles: 6, Pb_cross_cycles: 0},
@@ -271,19 +388,10 @@ var cpuInstructions = []cpu.Instr{
 	{Instruction: symTXS, Mode: IMP, Opcode: 0x9a, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
 	{Instruction: symTSX, Mode: IMP, Opcode: 0xba, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
 
-	{Instruction: symTRB, Mode: ZPG, Opcode: 0x14, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
-	{Instruction: symTRB, Mode: ABS, Opcode: 0x1c, Len: 3, Cycles: 6, Pb_cross_cycles: 0},
-	{Instruction: symTSB, Mode: ZPG, Opcode: 0x04, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
-	{Instruction: symTSB, Mode: ABS, Opcode: 0x0c, Len: 3, Cycles: 6, Pb_cross_cycles: 0},
-
 	{Instruction: symPHA, Mode: IMP, Opcode: 0x48, Len: 1, Cycles: 3, Pb_cross_cycles: 0},
 	{Instruction: symPLA, Mode: IMP, Opcode: 0x68, Len: 1, Cycles: 4, Pb_cross_cycles: 0},
 	{Instruction: symPHP, Mode: IMP, Opcode: 0x08, Len: 1, Cycles: 3, Pb_cross_cycles: 0},
 	{Instruction: symPLP, Mode: IMP, Opcode: 0x28, Len: 1, Cycles: 4, Pb_cross_cycles: 0},
-	{Instruction: symPHX, Mode: IMP, Opcode: 0xda, Len: 1, Cycles: 3, Pb_cross_cycles: 0},
-	{Instruction: symPLX, Mode: IMP, Opcode: 0xfa, Len: 1, Cycles: 4, Pb_cross_cycles: 0},
-	{Instruction: symPHY, Mode: IMP, Opcode: 0x5a, Len: 1, Cycles: 3, Pb_cross_cycles: 0},
-	{Instruction: symPLY, Mode: IMP, Opcode: 0x7a, Len: 1, Cycles: 4, Pb_cross_cycles: 0},
 
 	{Instruction: symASL, Mode: ACC, Opcode: 0x0a, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
 	{Instruction: symASL, Mode: ZPG, Opcode: 0x06, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
@@ -310,15 +418,320 @@ var cpuInstructions = []cpu.Instr{
 	{Instruction: symROR, Mode: ABX, Opcode: 0x7e, Len: 3, Cycles: 7, Pb_cross_cycles: 0},
 }
 
-func GetInstr(opcode byte) (cpu.Instr, error) {
-	/* Ew linear scan */
-	for _, inst := range cpuInstructions {
-		if inst.Opcode == opcode {
-			return inst, nil
+/*
+ * The unofficial opcodes of the NMOS die. These fill every hole in the
+ * NMOS table, so with these added all 256 opcodes decode
+ *
+ * See: https://www.nesdev.org/wiki/CPU_unofficial_opcodes
+ */
+var illegalInstructions = []cpu.Instr{
+	{Instruction: symSLO, Mode: ZPG, Opcode: 0x07, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
+	{Instruction: symSLO, Mode: ZPX, Opcode: 0x17, Len: 2, Cycles: 6, Pb_cross_cycles: 0},
+	{Instruction: symSLO, Mode: ABS, Opcode: 0x0f, Len: 3, Cycles: 6, Pb_cross_cycles: 0},
+	{Instruction: symSLO, Mode: ABX, Opcode: 0x1f, Len: 3, Cycles: 7, Pb_cross_cycles: 0},
+	{Instruction: symSLO, Mode: ABY, Opcode: 0x1b, Len: 3, Cycles: 7, Pb_cross_cycles: 0},
+	{Instruction: symSLO, Mode: IDX, Opcode: 0x03, Len: 2, Cycles: 8, Pb_cross_cycles: 0},
+	{Instruction: symSLO, Mode: IDY, Opcode: 0x13, Len: 2, Cycles: 8, Pb_cross_cycles: 0},
+
+	{Instruction: symRLA, Mode: ZPG, Opcode: 0x27, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
+	{Instruction: symRLA, Mode: ZPX, Opcode: 0x37, Len: 2, Cycles: 6, Pb_cross_cycles: 0},
+	{Instruction: symRLA, Mode: ABS, Opcode: 0x2f, Len: 3, Cycles: 6, Pb_cross_cycles: 0},
+	{Instruction: symRLA, Mode: ABX, Opcode: 0x3f, Len: 3, Cycles: 7, Pb_cross_cycles: 0},
+	{Instruction: symRLA, Mode: ABY, Opcode: 0x3b, Len: 3, Cycles: 7, Pb_cross_cycles: 0},
+	{Instruction: symRLA, Mode: IDX, Opcode: 0x23, Len: 2, Cycles: 8, Pb_cross_cycles: 0},
+	{Instruction: symRLA, Mode: IDY, Opcode: 0x33, Len: 2, Cycles: 8, Pb_cross_cycles: 0},
+
+	{Instruction: symSRE, Mode: ZPG, Opcode: 0x47, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
+	{Instruction: symSRE, Mode: ZPX, Opcode: 0x57, Len: 2, Cycles: 6, Pb_cross_cycles: 0},
+	{Instruction: symSRE, Mode: ABS, Opcode: 0x4f, Len: 3, Cycles: 6, Pb_cross_cycles: 0},
+	{Instruction: symSRE, Mode: ABX, Opcode: 0x5f, Len: 3, Cycles: 7, Pb_cross_cycles: 0},
+	{Instruction: symSRE, Mode: ABY, Opcode: 0x5b, Len: 3, Cycles: 7, Pb_cross_cycles: 0},
+	{Instruction: symSRE, Mode: IDX, Opcode: 0x43, Len: 2, Cycles: 8, Pb_cross_cycles: 0},
+	{Instruction: symSRE, Mode: IDY, Opcode: 0x53, Len: 2, Cycles: 8, Pb_cross_cycles: 0},
+
+	{Instruction: symRRA, Mode: ZPG, Opcode: 0x67, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
+	{Instruction: symRRA, Mode: ZPX, Opcode: 0x77, Len: 2, Cycles: 6, Pb_cross_cycles: 0},
+	{Instruction: symRRA, Mode: ABS, Opcode: 0x6f, Len: 3, Cycles: 6, Pb_cross_cycles: 0},
+	{Instruction: symRRA, Mode: ABX, Opcode: 0x7f, Len: 3, Cycles: 7, Pb_cross_cycles: 0},
+	{Instruction: symRRA, Mode: ABY, Opcode: 0x7b, Len: 3, Cycles: 7, Pb_cross_cycles: 0},
+	{Instruction: symRRA, Mode: IDX, Opcode: 0x63, Len: 2, Cycles: 8, Pb_cross_cycles: 0},
+	{Instruction: symRRA, Mode: IDY, Opcode: 0x73, Len: 2, Cycles: 8, Pb_cross_cycles: 0},
+
+	{Instruction: symDCP, Mode: ZPG, Opcode: 0xc7, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
+	{Instruction: symDCP, Mode: ZPX, Opcode: 0xd7, Len: 2, Cycles: 6, Pb_cross_cycles: 0},
+	{Instruction: symDCP, Mode: ABS, Opcode: 0xcf, Len: 3, Cycles: 6, Pb_cross_cycles: 0},
+	{Instruction: symDCP, Mode: ABX, Opcode: 0xdf, Len: 3, Cycles: 7, Pb_cross_cycles: 0},
+	{Instruction: symDCP, Mode: ABY, Opcode: 0xdb, Len: 3, Cycles: 7, Pb_cross_cycles: 0},
+	{Instruction: symDCP, Mode: IDX, Opcode: 0xc3, Len: 2, Cycles: 8, Pb_cross_cycles: 0},
+	{Instruction: symDCP, Mode: IDY, Opcode: 0xd3, Len: 2, Cycles: 8, Pb_cross_cycles: 0},
+
+	{Instruction: symISC, Mode: ZPG, Opcode: 0xe7, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
+	{Instruction: symISC, Mode: ZPX, Opcode: 0xf7, Len: 2, Cycles: 6, Pb_cross_cycles: 0},
+	{Instruction: symISC, Mode: ABS, Opcode: 0xef, Len: 3, Cycles: 6, Pb_cross_cycles: 0},
+	{Instruction: symISC, Mode: ABX, Opcode: 0xff, Len: 3, Cycles: 7, Pb_cross_cycles: 0},
+	{Instruction: symISC, Mode: ABY, Opcode: 0xfb, Len: 3, Cycles: 7, Pb_cross_cycles: 0},
+	{Instruction: symISC, Mode: IDX, Opcode: 0xe3, Len: 2, Cycles: 8, Pb_cross_cycles: 0},
+	{Instruction: symISC, Mode: IDY, Opcode: 0xf3, Len: 2, Cycles: 8, Pb_cross_cycles: 0},
+
+	{Instruction: symLAX, Mode: ZPG, Opcode: 0xa7, Len: 2, Cycles: 3, Pb_cross_cycles: 0},
+	{Instruction: symLAX, Mode: ZPY, Opcode: 0xb7, Len: 2, Cycles: 4, Pb_cross_cycles: 0},
+	{Instruction: symLAX, Mode: ABS, Opcode: 0xaf, Len: 3, Cycles: 4, Pb_cross_cycles: 0},
+	{Instruction: symLAX, Mode: ABY, Opcode: 0xbf, Len: 3, Cycles: 4, Pb_cross_cycles: 1},
+	{Instruction: symLAX, Mode: IDX, Opcode: 0xa3, Len: 2, Cycles: 6, Pb_cross_cycles: 0},
+	{Instruction: symLAX, Mode: IDY, Opcode: 0xb3, Len: 2, Cycles: 5, Pb_cross_cycles: 1},
+
+	{Instruction: symSAX, Mode: ZPG, Opcode: 0x87, Len: 2, Cycles: 3, Pb_cross_cycles: 0},
+	{Instruction: symSAX, Mode: ZPY, Opcode: 0x97, Len: 2, Cycles: 4, Pb_cross_cycles: 0},
+	{Instruction: symSAX, Mode: ABS, Opcode: 0x8f, Len: 3, Cycles: 4, Pb_cross_cycles: 0},
+	{Instruction: symSAX, Mode: IDX, Opcode: 0x83, Len: 2, Cycles: 6, Pb_cross_cycles: 0},
+
+	{Instruction: symANC, Mode: IMM, Opcode: 0x0b, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symANC, Mode: IMM, Opcode: 0x2b, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symALR, Mode: IMM, Opcode: 0x4b, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symARR, Mode: IMM, Opcode: 0x6b, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symAXS, Mode: IMM, Opcode: 0xcb, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symSBC, Mode: IMM, Opcode: 0xeb, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
+
+	// The unstable ones. These depend on analog effects, so they can be trapped instead
+	{Instruction: symXAA, Mode: IMM, Opcode: 0x8b, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symLXA, Mode: IMM, Opcode: 0xab, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symSHA, Mode: ABY, Opcode: 0x9f, Len: 3, Cycles: 5, Pb_cross_cycles: 0},
+	{Instruction: symSHA, Mode: IDY, Opcode: 0x93, Len: 2, Cycles: 6, Pb_cross_cycles: 0},
+	{Instruction: symSHX, Mode: ABY, Opcode: 0x9e, Len: 3, Cycles: 5, Pb_cross_cycles: 0},
+	{Instruction: symSHY, Mode: ABX, Opcode: 0x9c, Len: 3, Cycles: 5, Pb_cross_cycles: 0},
+	{Instruction: symTAS, Mode: ABY, Opcode: 0x9b, Len: 3, Cycles: 5, Pb_cross_cycles: 0},
+	{Instruction: symLAS, Mode: ABY, Opcode: 0xbb, Len: 3, Cycles: 4, Pb_cross_cycles: 1},
+
+	{Instruction: symNOP, Mode: IMP, Opcode: 0x1a, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symNOP, Mode: IMP, Opcode: 0x3a, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symNOP, Mode: IMP, Opcode: 0x5a, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symNOP, Mode: IMP, Opcode: 0x7a, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symNOP, Mode: IMP, Opcode: 0xda, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symNOP, Mode: IMP, Opcode: 0xfa, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symNOP, Mode: IMM, Opcode: 0x80, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symNOP, Mode: IMM, Opcode: 0x82, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symNOP, Mode: IMM, Opcode: 0x89, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symNOP, Mode: IMM, Opcode: 0xc2, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symNOP, Mode: IMM, Opcode: 0xe2, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symNOP, Mode: ZPG, Opcode: 0x04, Len: 2, Cycles: 3, Pb_cross_cycles: 0},
+	{Instruction: symNOP, Mode: ZPG, Opcode: 0x44, Len: 2, Cycles: 3, Pb_cross_cycles: 0},
+	{Instruction: symNOP, Mode: ZPG, Opcode: 0x64, Len: 2, Cycles: 3, Pb_cross_cycles: 0},
+	{Instruction: symNOP, Mode: ZPX, Opcode: 0x14, Len: 2, Cycles: 4, Pb_cross_cycles: 0},
+	{Instruction: symNOP, Mode: ZPX, Opcode: 0x34, Len: 2, Cycles: 4, Pb_cross_cycles: 0},
+	{Instruction: symNOP, Mode: ZPX, Opcode: 0x54, Len: 2, Cycles: 4, Pb_cross_cycles: 0},
+	{Instruction: symNOP, Mode: ZPX, Opcode: 0x74, Len: 2, Cycles: 4, Pb_cross_cycles: 0},
+	{Instruction: symNOP, Mode: ZPX, Opcode: 0xd4, Len: 2, Cycles: 4, Pb_cross_cycles: 0},
+	{Instruction: symNOP, Mode: ZPX, Opcode: 0xf4, Len: 2, Cycles: 4, Pb_cross_cycles: 0},
+	{Instruction: symNOP, Mode: ABS, Opcode: 0x0c, Len: 3, Cycles: 4, Pb_cross_cycles: 0},
+	{Instruction: symNOP, Mode: ABX, Opcode: 0x1c, Len: 3, Cycles: 4, Pb_cross_cycles: 1},
+	{Instruction: symNOP, Mode: ABX, Opcode: 0x3c, Len: 3, Cycles: 4, Pb_cross_cycles: 1},
+	{Instruction: symNOP, Mode: ABX, Opcode: 0x5c, Len: 3, Cycles: 4, Pb_cross_cycles: 1},
+	{Instruction: symNOP, Mode: ABX, Opcode: 0x7c, Len: 3, Cycles: 4, Pb_cross_cycles: 1},
+	{Instruction: symNOP, Mode: ABX, Opcode: 0xdc, Len: 3, Cycles: 4, Pb_cross_cycles: 1},
+	{Instruction: symNOP, Mode: ABX, Opcode: 0xfc, Len: 3, Cycles: 4, Pb_cross_cycles: 1},
+
+	// These lock up the CPU until it gets reset
+	{Instruction: symJAM, Mode: IMP, Opcode: 0x02, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symJAM, Mode: IMP, Opcode: 0x12, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symJAM, Mode: IMP, Opcode: 0x22, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symJAM, Mode: IMP, Opcode: 0x32, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symJAM, Mode: IMP, Opcode: 0x42, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symJAM, Mode: IMP, Opcode: 0x52, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symJAM, Mode: IMP, Opcode: 0x62, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symJAM, Mode: IMP, Opcode: 0x72, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symJAM, Mode: IMP, Opcode: 0x92, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symJAM, Mode: IMP, Opcode: 0xb2, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symJAM, Mode: IMP, Opcode: 0xd2, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symJAM, Mode: IMP, Opcode: 0xf2, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
+}
+
+/*
+ * Opcodes the 65C02 adds on top of the NMOS set. Entries in here take
+ * precedence over the NMOS ones with the same opcode
+ */
+var c65c02Instructions = []cpu.Instr{
+	{Instruction: symLDA, Mode: IZP, Opcode: 0xb2, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
+	{Instruction: symSTA, Mode: IZP, Opcode: 0x92, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
+	{Instruction: symADC, Mode: IZP, Opcode: 0x72, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
+	{Instruction: symSBC, Mode: IZP, Opcode: 0xf2, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
+	{Instruction: symCMP, Mode: IZP, Opcode: 0xd2, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
+	{Instruction: symAND, Mode: IZP, Opcode: 0x32, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
+	{Instruction: symORA, Mode: IZP, Opcode: 0x12, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
+	{Instruction: symEOR, Mode: IZP, Opcode: 0x52, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
+
+	{Instruction: symSTZ, Mode: ZPG, Opcode: 0x64, Len: 2, Cycles: 3, Pb_cross_cycles: 0},
+	{Instruction: symSTZ, Mode: ZPX, Opcode: 0x74, Len: 2, Cycles: 4, Pb_cross_cycles: 0},
+	{Instruction: symSTZ, Mode: ABS, Opcode: 0x9c, Len: 3, Cycles: 4, Pb_cross_cycles: 0},
+	{Instruction: symSTZ, Mode: ABX, Opcode: 0x9e, Len: 3, Cycles: 5, Pb_cross_cycles: 0},
+
+	{Instruction: symBIT, Mode: IMM, Opcode: 0x89, Len: 2, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symBIT, Mode: ZPX, Opcode: 0x34, Len: 2, Cycles: 4, Pb_cross_cycles: 0},
+	{Instruction: symBIT, Mode: ABX, Opcode: 0x3c, Len: 3, Cycles: 4, Pb_cross_cycles: 1},
+
+	{Instruction: symBRA, Mode: REL, Opcode: 0x80, Len: 2, Cycles: 2, Pb_cross_cycles: 1},
+
+	{Instruction: symINC, Mode: ACC, Opcode: 0x1a, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
+	{Instruction: symDEC, Mode: ACC, Opcode: 0x3a, Len: 1, Cycles: 2, Pb_cross_cycles: 0},
+
+	// The 65C02 fixed the page wrapping bug, at the cost of a cycle
+	{Instruction: symJMP, Mode: IND, Opcode: 0x6c, Len: 3, Cycles: 6, Pb_cross_cycles: 0},
+	{Instruction: symJMP, Mode: IAX, Opcode: 0x7c, Len: 3, Cycles: 6, Pb_cross_cycles: 0},
+
+	{Instruction: symTRB, Mode: ZPG, Opcode: 0x14, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
+	{Instruction: symTRB, Mode: ABS, Opcode: 0x1c, Len: 3, Cycles: 6, Pb_cross_cycles: 0},
+	{Instruction: symTSB, Mode: ZPG, Opcode: 0x04, Len: 2, Cycles: 5, Pb_cross_cycles: 0},
+	{Instruction: symTSB, Mode: ABS, Opcode: 0x0c, Len: 3, Cycles: 6, Pb_cross_cycles: 0},
+
+	{Instruction: symPHX, Mode: IMP, Opcode: 0xda, Len: 1, Cycles: 3, Pb_cross_cycles: 0},
+	{Instruction: symPLX, Mode: IMP, Opcode: 0xfa, Len: 1, Cycles: 4, Pb_cross_cycles: 0},
+	{Instruction: symPHY, Mode: IMP, Opcode: 0x5a, Len: 1, Cycles: 3, Pb_cross_cycles: 0},
+	{Instruction: symPLY, Mode: IMP, Opcode: 0x7a, Len: 1, Cycles: 4, Pb_cross_cycles: 0},
+
+	// Shifts on abs,X only pay for the fixup when they cross a page
+	{Instruction: symASL, Mode: ABX, Opcode: 0x1e, Len: 3, Cycles: 6, Pb_cross_cycles: 1},
+	{Instruction: symLSR, Mode: ABX, Opcode: 0x5e, Len: 3, Cycles: 6, Pb_cross_cycles: 1},
+	{Instruction: symROL, Mode: ABX, Opcode: 0x3e, Len: 3, Cycles: 6, Pb_cross_cycles: 1},
+	{Instruction: symROR, Mode: ABX, Opcode: 0x7e, Len: 3, Cycles: 6, Pb_cross_cycles: 1},
+}
+
+/*
+ * The 65C02 opcode list. The CMOS additions come first, so when
+ * buildDecodeTable merges the list into its [256] table, they claim their
+ * slot before the NMOS entries they replace get there
+ */
+var cmosInstructions = append(append([]cpu.Instr{}, c65c02Instructions...), nmosInstructions...)
+
+/*
+ * The full NMOS die, unofficial opcodes included
+ */
+var nmosFullInstructions = append(append([]cpu.Instr{}, nmosInstructions...), illegalInstructions...)
+
+/*
+ * Grab the opcode list for a specific 6502 variant
+ *
+ * The 2A03 decodes exactly like an NMOS 6502, it just has its decimal
+ * mode disconnected
+ */
+func variantInstructions(variant Variant) []cpu.Instr {
+	switch variant {
+	case C6502_VARIANT_65C02:
+		return cmosInstructions
+	default:
+		return nmosFullInstructions
+	}
+}
+
+/*
+ * Unstable opcodes are the ones whose result depends on analog effects
+ * on the die and differ between chips. We go with the commonly documented
+ * behaviour, but a CPU can be told to trap on them instead
+ */
+func IsUnstable(id cpu.InstrID) bool {
+	switch id {
+	case symXAA, symLXA, symSHA, symSHX, symSHY, symTAS, symLAS:
+		return true
+	}
+
+	return false
+}
+
+/*
+ * How many cycles @inst should take according to the table. Indexed reads
+ * pay Pb_cross_cycles extra when the index crosses a page. Branches pay one
+ * cycle for being taken, and Pb_cross_cycles on top of that when they land
+ * on another page
+ */
+func InstrCycles(inst *cpu.Instr, page_crossed bool, branch_taken bool) int {
+	cycles := int(inst.Cycles)
+
+	if inst.Mode == REL {
+		if !branch_taken {
+			return cycles
+		}
+
+		cycles++
+	}
+
+	if page_crossed {
+		cycles += int(inst.Pb_cross_cycles)
+	}
+
+	return cycles
+}
+
+/*
+ * A fully decoded opcode. The per-cycle microcode gets built when the
+ * table is, so executing an instruction is just an index and a few calls
+ */
+type decodedInstr struct {
+	instr cpu.Instr
+	ops   []microOp
+	valid bool
+	/* Documented by the manufacturer? */
+	official bool
+}
+
+type decodeTable [256]decodedInstr
+
+/* One table per variant, built once when the package loads */
+var decodeTables = map[Variant]*decodeTable{
+	C6502_VARIANT_2A03:  buildDecodeTable(C6502_VARIANT_2A03),
+	C6502_VARIANT_NMOS:  buildDecodeTable(C6502_VARIANT_NMOS),
+	C6502_VARIANT_65C02: buildDecodeTable(C6502_VARIANT_65C02),
+}
+
+func buildDecodeTable(variant Variant) *decodeTable {
+	var table decodeTable
+	var illegal [256]bool
+
+	if variant != C6502_VARIANT_65C02 {
+		for _, inst := range illegalInstructions {
+			illegal[inst.Opcode] = true
 		}
 	}
 
-	debug.Error("Looking for: 0x%x\n", opcode)
+	for _, inst := range variantInstructions(variant) {
+		entry := &table[inst.Opcode]
+
+		// First one wins, so the 65C02 entries can shadow the NMOS ones
+		if entry.valid {
+			continue
+		}
+
+		entry.instr = inst
+		entry.ops = buildMicrocode(variant, &entry.instr, &cpu6502_imp[inst.Instruction])
+		entry.valid = true
+		entry.official = !illegal[inst.Opcode]
+	}
+
+	return &table
+}
+
+func getDecodeTable(variant Variant) *decodeTable {
+	table, ok := decodeTables[variant]
+
+	if !ok {
+		return decodeTables[C6502_VARIANT_2A03]
+	}
+
+	return table
+}
+
+/*
+ * Does @variant decode @opcode to anything at all?
+ */
+func HasOpcode(variant Variant, opcode byte) bool {
+	return getDecodeTable(variant)[opcode].valid
+}
+
+func GetInstr(variant Variant, opcode byte) (cpu.Instr, error) {
+	entry := &getDecodeTable(variant)[opcode]
+
+	if !entry.valid {
+		debug.Error("Looking for: 0x%x\n", opcode)
+
+		return cpu.Instr{}, errors.New("invalid opcode")
+	}
 
-	return cpu.Instr{}, errors.New("invalid opcode")
+	return entry.instr, nil
 }
@@ -0,0 +1,283 @@
+package cpu6502
+
+import (
+	"testing"
+
+	"github.com/beakeyz/gones-emu/pkg/hardware/bus"
+	"github.com/beakeyz/gones-emu/pkg/hardware/cpu"
+	"github.com/beakeyz/gones-emu/pkg/hardware/memory/ram"
+)
+
+/* Where the NMI and IRQ/BRK vectors send us */
+const (
+	testNmiHandler = 0x9000
+	testIrqHandler = 0xa000
+)
+
+/*
+ * A CPU with NOPs everywhere in ROM space, @prog at @origin and the
+ * vectors pointing at the handlers above. Reset is already done
+ */
+type interruptRig struct {
+	c  *CPU6502
+	lo *ram.Ram
+}
+
+func newInterruptRig(origin uint16, prog []byte) *interruptRig {
+	sbus, _ := bus.NewSystembus()
+
+	lo := ram.New(0x0000, 0x7fff, 0x8000)
+	hi := ram.New(0x8000, 0xffff, 0x8000)
+
+	sbus.AddComponent(lo)
+	sbus.AddComponent(hi)
+
+	for addr := 0x8000; addr < 0xfffa; addr++ {
+		hi.Write(uint16(addr), 0xea)
+	}
+
+	for i, b := range prog {
+		hi.Write(origin+uint16(i), b)
+	}
+
+	for vector, target := range map[uint16]uint16{0xfffa: testNmiHandler, 0xfffc: origin, 0xfffe: testIrqHandler} {
+		hi.Write(vector, uint8(target))
+		hi.Write(vector+1, uint8(target>>8))
+	}
+
+	c := New(sbus, C6502_VARIANT_2A03)
+	c.Initialize()
+
+	return &interruptRig{c: c, lo: lo}
+}
+
+/*
+ * Run to the next instruction boundary and say where we ended up
+ */
+func (rig *interruptRig) next(t *testing.T) uint16 {
+	t.Helper()
+
+	if err := rig.c.ExecuteInstruction(); err != nil {
+		t.Fatal(err)
+	}
+
+	return rig.c.GetPC()
+}
+
+func (rig *interruptRig) cycles(t *testing.T, n int) {
+	t.Helper()
+
+	for range n {
+		if err := rig.c.DoCycle(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+/*
+ * What the last interrupt left on the stack: P and the return address
+ */
+func (rig *interruptRig) stacked() (uint8, uint16) {
+	var p, lo, hi uint8
+
+	s := uint16(rig.c.GetStackpointer())
+
+	rig.lo.Read(0x100+s+1, &p)
+	rig.lo.Read(0x100+s+2, &lo)
+	rig.lo.Read(0x100+s+3, &hi)
+
+	return p, uint16(hi)<<8 | uint16(lo)
+}
+
+/*
+ * CLI, SEI and PLP change I on their last cycle, after the poll that
+ * counts. So the IRQ line gets looked at with the old I for one more
+ * instruction
+ */
+func TestInterruptFlagDelay(t *testing.T) {
+	tests := []struct {
+		name string
+		prog []byte
+		/* IRQ held from the start, or raised when PC gets to @raise */
+		held  bool
+		raise uint16
+		/* PC after every instruction */
+		want []uint16
+		/* Is I set in the pushed P */
+		pushed_i bool
+	}{
+		{
+			"CLI", []byte{0x58, 0xea, 0xea}, true, 0,
+			[]uint16{0x8001, 0x8002, testIrqHandler, testIrqHandler + 1}, false,
+		},
+		{
+			"SEI", []byte{0x58, 0xea, 0x78, 0xea}, false, 0x8002,
+			[]uint16{0x8001, 0x8002, 0x8003, testIrqHandler, testIrqHandler + 1}, true,
+		},
+		{
+			"CLI SEI", []byte{0x58, 0x78, 0xea}, true, 0,
+			[]uint16{0x8001, 0x8002, testIrqHandler, testIrqHandler + 1}, true,
+		},
+		{
+			// LDA #$00, PHA, PLP
+			"PLP clearing I", []byte{0xa9, 0x00, 0x48, 0x28, 0xea, 0xea}, true, 0,
+			[]uint16{0x8002, 0x8003, 0x8004, 0x8005, testIrqHandler}, false,
+		},
+		{
+			// CLI, LDA #$04, PHA, PLP
+			"PLP setting I", []byte{0x58, 0xa9, 0x04, 0x48, 0x28, 0xea}, false, 0x8004,
+			[]uint16{0x8001, 0x8003, 0x8004, 0x8005, testIrqHandler}, true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rig := newInterruptRig(testOrigin, test.prog)
+
+			if test.held {
+				rig.c.RaiseIrq(cpu.IRQ_SOURCE_EXTERNAL)
+			}
+
+			for i, want := range test.want {
+				if rig.c.GetPC() == test.raise {
+					rig.c.RaiseIrq(cpu.IRQ_SOURCE_EXTERNAL)
+				}
+
+				if got := rig.next(t); got != want {
+					t.Fatalf("instruction %d: PC=$%04x, want $%04x", i, got, want)
+				}
+			}
+
+			p, _ := rig.stacked()
+
+			if pushed_i := (p & C6502_FLAG_INTDISABLE) != 0; pushed_i != test.pushed_i {
+				t.Errorf("pushed I=%v, want %v", pushed_i, test.pushed_i)
+			}
+		})
+	}
+}
+
+/*
+ * Taken branches that stay on their page skip the poll on their last
+ * cycle. An IRQ that shows up after the opcode fetch has to wait for the
+ * instruction after the branch
+ */
+func TestBranchInterruptPoll(t *testing.T) {
+	const branch = 0x80fa
+
+	tests := []struct {
+		name   string
+		opcode byte
+		offset byte
+		/* Cycles into the branch before the IRQ shows up */
+		raise int
+		/* Where the IRQ returns to */
+		want uint16
+	}{
+		{"taken", 0x90, 0x02, 0, 0x80fe},
+		{"taken, late irq", 0x90, 0x02, 1, 0x80ff},
+		{"taken across a page", 0x90, 0x10, 1, 0x810c},
+		{"taken across a page, later irq", 0x90, 0x10, 2, 0x810c},
+		{"taken across a page, too late", 0x90, 0x10, 3, 0x810d},
+		{"not taken", 0xb0, 0x10, 0, 0x80fc},
+		{"not taken, late irq", 0xb0, 0x10, 1, 0x80fd},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// CLI, CLC, Bxx
+			rig := newInterruptRig(branch-2, []byte{0x58, 0x18, test.opcode, test.offset})
+
+			rig.next(t)
+			rig.next(t)
+
+			rig.cycles(t, test.raise)
+			rig.c.RaiseIrq(cpu.IRQ_SOURCE_EXTERNAL)
+
+			// The branch, maybe an instruction after it and the interrupt
+			for range 3 {
+				if rig.next(t) == testIrqHandler {
+					break
+				}
+			}
+
+			if rig.c.GetPC() != testIrqHandler {
+				t.Fatalf("never got the IRQ")
+			}
+
+			if _, ret := rig.stacked(); ret != test.want {
+				t.Errorf("IRQ came in at $%04x, want $%04x", ret, test.want)
+			}
+		})
+	}
+}
+
+/*
+ * The vector gets picked late in the interrupt sequence. An NMI that shows
+ * up before that takes over a BRK or IRQ that's already running, which
+ * keeps its pushed P (B flag and all)
+ */
+func TestNmiHijack(t *testing.T) {
+	tests := []struct {
+		name string
+		/* BRK, or an IRQ after a NOP */
+		brk bool
+		/* Cycles into the sequence before the NMI edge */
+		nmi int
+		/* Where the sequence goes */
+		vector uint16
+	}{
+		{"BRK", true, 1, testNmiHandler},
+		{"BRK, late", true, 4, testNmiHandler},
+		{"BRK, too late", true, 5, testIrqHandler},
+		{"IRQ", false, 1, testNmiHandler},
+		{"IRQ, late", false, 4, testNmiHandler},
+		{"IRQ, too late", false, 5, testIrqHandler},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var rig *interruptRig
+
+			if test.brk {
+				rig = newInterruptRig(testOrigin, []byte{0x00, 0xff})
+			} else {
+				rig = newInterruptRig(testOrigin, []byte{0x58, 0xea})
+				rig.c.RaiseIrq(cpu.IRQ_SOURCE_EXTERNAL)
+
+				rig.next(t)
+				rig.next(t)
+			}
+
+			rig.cycles(t, test.nmi)
+			rig.c.SetNmiLine(true)
+
+			if got := rig.next(t); got != test.vector {
+				t.Fatalf("went to $%04x, want $%04x", got, test.vector)
+			}
+
+			p, ret := rig.stacked()
+
+			if brk := (p & C6502_FLAG_BFLAG) != 0; brk != test.brk {
+				t.Errorf("pushed B=%v, want %v", brk, test.brk)
+			}
+
+			// Past BRK and its padding byte, or past CLI and NOP
+			if ret != testOrigin+2 {
+				t.Errorf("returns to $%04x, want $%04x", ret, testOrigin+2)
+			}
+
+			// The handler always gets one instruction in, then the NMI we
+			// didn't take (if any) goes next
+			if got := rig.next(t); got != test.vector+1 {
+				t.Errorf("first handler instruction: PC=$%04x, want $%04x", got, test.vector+1)
+			}
+
+			if test.vector == testIrqHandler {
+				if got := rig.next(t); got != testNmiHandler {
+					t.Errorf("NMI got lost, PC=$%04x", got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,642 @@
+package cpu6502
+
+import (
+	"github.com/beakeyz/gones-emu/pkg/hardware/cpu"
+)
+
+/*
+ * A single CPU cycle worth of work. Every micro op does exactly one bus
+ * access, just like the real chip, and returns true when it was the last
+ * cycle of the instruction
+ *
+ * The opcode fetch itself isn't part of this, DoCycle does that one. So the
+ * first micro op of an instruction runs on its second cycle
+ *
+ * See: https://www.nesdev.org/6502_cpu.txt
+ */
+type microOp func(c *CPU6502) bool
+
+/*
+ * Fetching operands and resolving addresses
+ */
+
+func opFetchZp(c *CPU6502) bool {
+	c.addr = uint16(c.fetch())
+	return false
+}
+
+func opZpIndexX(c *CPU6502) bool {
+	// The CPU reads the unindexed address while it does the add
+	c.read(c.addr)
+	c.addr = uint16(uint8(c.addr) + c.registers.x)
+	return false
+}
+
+func opZpIndexY(c *CPU6502) bool {
+	c.read(c.addr)
+	c.addr = uint16(uint8(c.addr) + c.registers.y)
+	return false
+}
+
+func opFetchLo(c *CPU6502) bool {
+	c.addr = uint16(c.fetch())
+	return false
+}
+
+func opFetchHi(c *CPU6502) bool {
+	c.addr |= uint16(c.fetch()) << 8
+	return false
+}
+
+func opFetchHiIndexX(c *CPU6502) bool {
+	c.base = c.addr | (uint16(c.fetch()) << 8)
+	c.addr = c.base + uint16(c.registers.x)
+	return false
+}
+
+func opFetchHiIndexY(c *CPU6502) bool {
+	c.base = c.addr | (uint16(c.fetch()) << 8)
+	c.addr = c.base + uint16(c.registers.y)
+	return false
+}
+
+func opFetchPtr(c *CPU6502) bool {
+	c.ptr = c.fetch()
+	return false
+}
+
+func opPtrIndexX(c *CPU6502) bool {
+	c.read(uint16(c.ptr))
+	c.ptr += c.registers.x
+	return false
+}
+
+func opPtrLo(c *CPU6502) bool {
+	c.addr = uint16(c.read(uint16(c.ptr)))
+	return false
+}
+
+func opPtrHi(c *CPU6502) bool {
+	// The pointer wraps around inside the zero page
+	c.addr |= uint16(c.read(uint16(c.ptr+1))) << 8
+	return false
+}
+
+func opPtrHiIndexY(c *CPU6502) bool {
+	c.base = c.addr | (uint16(c.read(uint16(c.ptr+1))) << 8)
+	c.addr = c.base + uint16(c.registers.y)
+	return false
+}
+
+/*
+ * Indexing only fixes up the high byte a cycle later, so the CPU always
+ * reads from the 'wrong' page first. Stores and read-modify-writes always
+ * pay for this cycle
+ */
+func opIndexedDummyRead(c *CPU6502) bool {
+	c.read(c.uncorrectedAddr())
+	return false
+}
+
+/*
+ * Reads get to skip the fixup when the index didn't cross a page, since
+ * the first read was from the right address after all
+ */
+func indexedReadOp(f func(c *CPU6502, value uint8)) microOp {
+	return func(c *CPU6502) bool {
+		value := c.read(c.uncorrectedAddr())
+
+		if c.pageCrossed() {
+			return false
+		}
+
+		f(c, value)
+		return true
+	}
+}
+
+/*
+ * The final access of an instruction
+ */
+
+func readOp(f func(c *CPU6502, value uint8)) microOp {
+	return func(c *CPU6502) bool {
+		f(c, c.read(c.addr))
+		return true
+	}
+}
+
+func writeOp(f func(c *CPU6502) uint8) microOp {
+	return func(c *CPU6502) bool {
+		// Compute the value first, since SHA and friends may mess with c.addr
+		value := f(c)
+
+		c.write(c.addr, value)
+		return true
+	}
+}
+
+/*
+ * The 65C02 shifts on abs,X skip the fixup cycle when the index stayed
+ * on the page, and get the operand right away
+ */
+func opCmosShiftIndexedRead(c *CPU6502) bool {
+	if c.pageCrossed() {
+		c.read(c.uncorrectedAddr())
+		return false
+	}
+
+	c.value = c.read(c.addr)
+
+	// That was opModifyRead's job, so skip it
+	c.step++
+	return false
+}
+
+func opModifyRead(c *CPU6502) bool {
+	c.value = c.read(c.addr)
+	return false
+}
+
+/*
+ * Read-modify-write instructions write the unmodified value back while
+ * the ALU is busy, and only then write the result
+ */
+func modifyDummyWriteOp(f func(c *CPU6502, value uint8) uint8) microOp {
+	return func(c *CPU6502) bool {
+		c.write(c.addr, c.value)
+		c.value = f(c, c.value)
+		return false
+	}
+}
+
+func opModifyWrite(c *CPU6502) bool {
+	c.write(c.addr, c.value)
+	return true
+}
+
+/*
+ * Instructions without a memory operand
+ */
+
+func impliedMicrocode(f func(c *CPU6502)) []microOp {
+	return []microOp{
+		func(c *CPU6502) bool {
+			// Reads the next byte and throws it away
+			c.read(c.registers.pc)
+			f(c)
+			return true
+		},
+	}
+}
+
+func accumulatorMicrocode(f func(c *CPU6502, value uint8) uint8) []microOp {
+	return []microOp{
+		func(c *CPU6502) bool {
+			c.read(c.registers.pc)
+			c.registers.a = f(c, c.registers.a)
+			return true
+		},
+	}
+}
+
+func immediateMicrocode(f func(c *CPU6502, value uint8)) []microOp {
+	return []microOp{
+		func(c *CPU6502) bool {
+			f(c, c.fetch())
+			return true
+		},
+	}
+}
+
+/*
+ * Branches take 2 cycles, 3 if taken and 4 if the target is on a different page
+ */
+func branchMicrocode(cond func(c *CPU6502) bool) []microOp {
+	return []microOp{
+		func(c *CPU6502) bool {
+			c.value = c.fetch()
+			return !cond(c)
+		},
+		func(c *CPU6502) bool {
+			c.read(c.registers.pc)
+
+			c.addr = c.registers.pc + uint16(int8(c.value))
+
+			// Only the low byte gets the offset added this cycle
+			c.registers.pc = (c.registers.pc & 0xff00) | (c.addr & 0x00ff)
+
+			if c.registers.pc != c.addr {
+				return false
+			}
+
+			// Interrupts don't get polled again on this cycle, so whatever
+			// shows up now waits for the next instruction
+			c.hold_poll = true
+			return true
+		},
+		func(c *CPU6502) bool {
+			c.read(c.registers.pc)
+			c.registers.pc = c.addr
+			return true
+		},
+	}
+}
+
+/*
+ * Stack shuffling
+ */
+
+func pushMicrocode(f func(c *CPU6502) uint8) []microOp {
+	return []microOp{
+		func(c *CPU6502) bool {
+			c.read(c.registers.pc)
+			return false
+		},
+		func(c *CPU6502) bool {
+			c.push(f(c))
+			return true
+		},
+	}
+}
+
+func pullMicrocode(f func(c *CPU6502, value uint8)) []microOp {
+	return []microOp{
+		func(c *CPU6502) bool {
+			c.read(c.registers.pc)
+			return false
+		},
+		func(c *CPU6502) bool {
+			// Reads the stack while incrementing S
+			c.read(c.stackAddr())
+			c.registers.s++
+			return false
+		},
+		func(c *CPU6502) bool {
+			f(c, c.read(c.stackAddr()))
+			return true
+		},
+	}
+}
+
+var jsrMicrocode = []microOp{
+	func(c *CPU6502) bool {
+		c.value = c.fetch()
+		return false
+	},
+	func(c *CPU6502) bool {
+		c.read(c.stackAddr())
+		return false
+	},
+	func(c *CPU6502) bool {
+		// PC points at the high byte of the target here, which is what gets pushed
+		c.push(uint8(c.registers.pc >> 8))
+		return false
+	},
+	func(c *CPU6502) bool {
+		c.push(uint8(c.registers.pc))
+		return false
+	},
+	func(c *CPU6502) bool {
+		c.registers.pc = uint16(c.value) | (uint16(c.read(c.registers.pc)) << 8)
+		return true
+	},
+}
+
+var rtsMicrocode = []microOp{
+	func(c *CPU6502) bool {
+		c.read(c.registers.pc)
+		return false
+	},
+	func(c *CPU6502) bool {
+		c.read(c.stackAddr())
+		c.registers.s++
+		return false
+	},
+	func(c *CPU6502) bool {
+		c.addr = uint16(c.read(c.stackAddr()))
+		c.registers.s++
+		return false
+	},
+	func(c *CPU6502) bool {
+		c.addr |= uint16(c.read(c.stackAddr())) << 8
+		c.registers.pc = c.addr
+		return false
+	},
+	func(c *CPU6502) bool {
+		// JSR pushed the address of its last byte, so step over it
+		c.fetch()
+		return true
+	},
+}
+
+var rtiMicrocode = []microOp{
+	func(c *CPU6502) bool {
+		c.read(c.registers.pc)
+		return false
+	},
+	func(c *CPU6502) bool {
+		c.read(c.stackAddr())
+		c.registers.s++
+		return false
+	},
+	func(c *CPU6502) bool {
+		doPullFlags(c, c.read(c.stackAddr()))
+		c.registers.s++
+		return false
+	},
+	func(c *CPU6502) bool {
+		c.addr = uint16(c.read(c.stackAddr()))
+		c.registers.s++
+		return false
+	},
+	func(c *CPU6502) bool {
+		c.addr |= uint16(c.read(c.stackAddr())) << 8
+		c.registers.pc = c.addr
+		return true
+	},
+}
+
+/*
+ * BRK, and the interrupt sequences which are the same thing with the
+ * pushes turned into reads (reset) or without the B flag (NMI, IRQ)
+ *
+ * Which vector gets used is only decided while P gets pushed, so an NMI
+ * that comes in early enough hijacks a BRK or IRQ that's already running
+ */
+func interruptMicrocode(software bool, reset bool) []microOp {
+	var ops []microOp
+	var push func(c *CPU6502, value uint8)
+
+	if software {
+		// BRK skips the padding byte after the opcode
+		ops = []microOp{
+			func(c *CPU6502) bool {
+				c.fetch()
+				return false
+			},
+		}
+	} else {
+		// Hardware interrupts replace the opcode fetch with a read that gets
+		// thrown away, and don't move PC at all
+		dummy := func(c *CPU6502) bool {
+			c.read(c.registers.pc)
+			return false
+		}
+
+		ops = []microOp{dummy, dummy}
+	}
+
+	if reset {
+		// The write line is held high during reset, so the pushes end up as reads
+		push = func(c *CPU6502, value uint8) {
+			c.read(c.stackAddr())
+			c.registers.s--
+		}
+	} else {
+		push = func(c *CPU6502, value uint8) {
+			c.push(value)
+		}
+	}
+
+	return append(ops,
+		func(c *CPU6502) bool {
+			push(c, uint8(c.registers.pc>>8))
+			return false
+		},
+		func(c *CPU6502) bool {
+			push(c, uint8(c.registers.pc))
+			return false
+		},
+		func(c *CPU6502) bool {
+			p := c.registers.p | C6502_FLAG_RESERVED
+
+			if software {
+				p |= C6502_FLAG_BFLAG
+			}
+
+			push(c, p)
+
+			c.base = c.interruptVector(reset)
+			return false
+		},
+		func(c *CPU6502) bool {
+			c.addr = uint16(c.read(c.base))
+			c.SetFlag(C6502_FLAG_INTDISABLE)
+
+			// The 65C02 also drops out of decimal mode
+			if c.variant == C6502_VARIANT_65C02 {
+				c.ClearFlag(C6502_FLAG_DECIMAL)
+			}
+
+			return false
+		},
+		func(c *CPU6502) bool {
+			c.addr |= uint16(c.read(c.base+1)) << 8
+			c.registers.pc = c.addr
+
+			// None of this polls, BRK included. Anything that showed up in
+			// the meantime waits for the first instruction of the handler
+			c.poll = false
+			c.poll_prev = false
+			c.hold_poll = true
+			return true
+		},
+	)
+}
+
+var brkMicrocode = interruptMicrocode(true, false)
+var irqMicrocode = interruptMicrocode(false, false)
+var resetMicrocode = interruptMicrocode(false, true)
+
+/*
+ * JAM opcodes lock the CPU up until it gets reset
+ */
+var jamMicrocode = []microOp{
+	func(c *CPU6502) bool {
+		c.read(c.registers.pc)
+		c.jammed = true
+		return true
+	},
+}
+
+func jmpMicrocode(variant Variant, mode cpu.AddrMode) []microOp {
+	switch mode {
+	case IND:
+		if variant == C6502_VARIANT_65C02 {
+			// Fixed the page wrap bug, at the cost of a cycle
+			return []microOp{
+				opFetchLo,
+				opFetchHi,
+				func(c *CPU6502) bool {
+					c.read(c.registers.pc - 1)
+					return false
+				},
+				func(c *CPU6502) bool {
+					c.value = c.read(c.addr)
+					return false
+				},
+				func(c *CPU6502) bool {
+					c.registers.pc = uint16(c.value) | (uint16(c.read(c.addr+1)) << 8)
+					return true
+				},
+			}
+		}
+
+		return []microOp{
+			opFetchLo,
+			opFetchHi,
+			func(c *CPU6502) bool {
+				c.value = c.read(c.addr)
+				return false
+			},
+			func(c *CPU6502) bool {
+				// The NMOS 6502 doesn't carry into the high byte of the pointer, so
+				// JMP ($xxFF) grabs its high byte from $xx00
+				hi_addr := (c.addr & 0xff00) | ((c.addr + 1) & 0x00ff)
+
+				c.registers.pc = uint16(c.value) | (uint16(c.read(hi_addr)) << 8)
+				return true
+			},
+		}
+	case IAX:
+		return []microOp{
+			opFetchLo,
+			opFetchHi,
+			func(c *CPU6502) bool {
+				c.read(c.registers.pc - 1)
+				c.addr += uint16(c.registers.x)
+				return false
+			},
+			func(c *CPU6502) bool {
+				c.value = c.read(c.addr)
+				return false
+			},
+			func(c *CPU6502) bool {
+				c.registers.pc = uint16(c.value) | (uint16(c.read(c.addr+1)) << 8)
+				return true
+			},
+		}
+	}
+
+	return []microOp{
+		opFetchLo,
+		func(c *CPU6502) bool {
+			c.registers.pc = c.addr | (uint16(c.read(c.registers.pc)) << 8)
+			return true
+		},
+	}
+}
+
+/*
+ * The cycles that turn the operand bytes into an effective address, for
+ * the addressing modes that actually touch memory. Indexed modes end with
+ * c.base holding the unindexed address
+ */
+func addressingMicrocode(mode cpu.AddrMode) []microOp {
+	switch mode {
+	case ZPG:
+		return []microOp{opFetchZp}
+	case ZPX:
+		return []microOp{opFetchZp, opZpIndexX}
+	case ZPY:
+		return []microOp{opFetchZp, opZpIndexY}
+	case ABS:
+		return []microOp{opFetchLo, opFetchHi}
+	case ABX:
+		return []microOp{opFetchLo, opFetchHiIndexX}
+	case ABY:
+		return []microOp{opFetchLo, opFetchHiIndexY}
+	case IDX:
+		return []microOp{opFetchPtr, opPtrIndexX, opPtrLo, opPtrHi}
+	case IDY:
+		return []microOp{opFetchPtr, opPtrLo, opPtrHiIndexY}
+	case IZP:
+		return []microOp{opFetchPtr, opPtrLo, opPtrHi}
+	}
+
+	return nil
+}
+
+func isIndexedMode(mode cpu.AddrMode) bool {
+	return mode == ABX || mode == ABY || mode == IDY
+}
+
+func isCmosIndexedShift(variant Variant, inst *cpu.Instr) bool {
+	if variant != C6502_VARIANT_65C02 || inst.Mode != ABX {
+		return false
+	}
+
+	switch inst.Instruction {
+	case symASL, symLSR, symROL, symROR:
+		return true
+	}
+
+	return false
+}
+
+/*
+ * Put together the per-cycle program for a single opcode
+ */
+func buildMicrocode(variant Variant, inst *cpu.Instr, impl *InstrImpl) []microOp {
+	var ops []microOp
+
+	switch inst.Instruction {
+	case symBRK:
+		return brkMicrocode
+	case symJAM:
+		return jamMicrocode
+	case symJMP:
+		return jmpMicrocode(variant, inst.Mode)
+	case symJSR:
+		return jsrMicrocode
+	case symRTS:
+		return rtsMicrocode
+	case symRTI:
+		return rtiMicrocode
+	case symPHA, symPHP, symPHX, symPHY:
+		return pushMicrocode(impl.Write)
+	case symPLA, symPLP, symPLX, symPLY:
+		return pullMicrocode(impl.Read)
+	}
+
+	if impl.Branch != nil {
+		return branchMicrocode(impl.Branch)
+	}
+
+	switch inst.Mode {
+	case IMP:
+		return impliedMicrocode(impl.Implied)
+	case ACC:
+		return accumulatorMicrocode(impl.Modify)
+	case IMM:
+		return immediateMicrocode(impl.Read)
+	}
+
+	ops = addressingMicrocode(inst.Mode)
+
+	switch {
+	case impl.Read != nil:
+		if isIndexedMode(inst.Mode) {
+			ops = append(ops, indexedReadOp(impl.Read))
+		}
+
+		ops = append(ops, readOp(impl.Read))
+	case impl.Write != nil:
+		if isIndexedMode(inst.Mode) {
+			ops = append(ops, opIndexedDummyRead)
+		}
+
+		ops = append(ops, writeOp(impl.Write))
+	case impl.Modify != nil:
+		if isCmosIndexedShift(variant, inst) {
+			ops = append(ops, opCmosShiftIndexedRead)
+		} else if isIndexedMode(inst.Mode) {
+			ops = append(ops, opIndexedDummyRead)
+		}
+
+		ops = append(ops, opModifyRead, modifyDummyWriteOp(impl.Modify), opModifyWrite)
+	}
+
+	return ops
+}
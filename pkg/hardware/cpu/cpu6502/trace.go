@@ -0,0 +1,145 @@
+package cpu6502
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+ * Read something for the disassembler without touching the hardware. The
+ * I/O registers react to reads, so those show up as $FF, which happens to
+ * be what nestest.log shows for them too
+ */
+func (c *CPU6502) peek(addr uint16) uint8 {
+	var value uint8
+
+	if addr >= 0x2000 && addr < 0x4020 {
+		return 0xff
+	}
+
+	if c.sbus.Read(addr, &value) != nil {
+		return 0xff
+	}
+
+	return value
+}
+
+func (c *CPU6502) peek16(addr uint16) uint16 {
+	return uint16(c.peek(addr)) | (uint16(c.peek(addr+1)) << 8)
+}
+
+/*
+ * Zero page pointers wrap around inside the zero page
+ */
+func (c *CPU6502) peekZp16(ptr uint8) uint16 {
+	return uint16(c.peek(uint16(ptr))) | (uint16(c.peek(uint16(ptr+1))) << 8)
+}
+
+/*
+ * Disassemble the instruction at @pc and return it with its length.
+ * Operands that touch memory also show what's there right now, the way
+ * nestest.log does it, and unofficial opcodes get a '*' in front
+ */
+func (c *CPU6502) Disassemble(pc uint16) (string, byte) {
+	var operand string
+
+	opcode := c.peek(pc)
+	entry := &c.decode[opcode]
+
+	if !entry.valid {
+		return fmt.Sprintf(".db $%02X", opcode), 1
+	}
+
+	inst := &entry.instr
+	lo := c.peek(pc + 1)
+	word := c.peek16(pc + 1)
+
+	switch inst.Mode {
+	case ACC:
+		operand = "A"
+	case IMM:
+		operand = fmt.Sprintf("#$%02X", lo)
+	case ZPG:
+		operand = fmt.Sprintf("$%02X = %02X", lo, c.peek(uint16(lo)))
+	case ZPX:
+		addr := lo + c.registers.x
+		operand = fmt.Sprintf("$%02X,X @ %02X = %02X", lo, addr, c.peek(uint16(addr)))
+	case ZPY:
+		addr := lo + c.registers.y
+		operand = fmt.Sprintf("$%02X,Y @ %02X = %02X", lo, addr, c.peek(uint16(addr)))
+	case ABS:
+		if inst.Instruction == symJMP || inst.Instruction == symJSR {
+			operand = fmt.Sprintf("$%04X", word)
+		} else {
+			operand = fmt.Sprintf("$%04X = %02X", word, c.peek(word))
+		}
+	case ABX:
+		addr := word + uint16(c.registers.x)
+		operand = fmt.Sprintf("$%04X,X @ %04X = %02X", word, addr, c.peek(addr))
+	case ABY:
+		addr := word + uint16(c.registers.y)
+		operand = fmt.Sprintf("$%04X,Y @ %04X = %02X", word, addr, c.peek(addr))
+	case IND:
+		hi_addr := word + 1
+
+		// Same page wrap bug as the real thing
+		if c.variant != C6502_VARIANT_65C02 {
+			hi_addr = (word & 0xff00) | ((word + 1) & 0x00ff)
+		}
+
+		target := uint16(c.peek(word)) | (uint16(c.peek(hi_addr)) << 8)
+		operand = fmt.Sprintf("($%04X) = %04X", word, target)
+	case IDX:
+		ptr := lo + c.registers.x
+		addr := c.peekZp16(ptr)
+		operand = fmt.Sprintf("($%02X,X) @ %02X = %04X = %02X", lo, ptr, addr, c.peek(addr))
+	case IDY:
+		base := c.peekZp16(lo)
+		addr := base + uint16(c.registers.y)
+		operand = fmt.Sprintf("($%02X),Y = %04X @ %04X = %02X", lo, base, addr, c.peek(addr))
+	case IZP:
+		addr := c.peekZp16(lo)
+		operand = fmt.Sprintf("($%02X) = %04X = %02X", lo, addr, c.peek(addr))
+	case IAX:
+		operand = fmt.Sprintf("($%04X,X)", word)
+	case REL:
+		operand = fmt.Sprintf("$%04X", pc+2+uint16(int8(lo)))
+	}
+
+	text := InstrName(inst.Instruction)
+
+	if operand != "" {
+		text += " " + operand
+	}
+
+	if !entry.official {
+		text = "*" + text
+	}
+
+	return text, inst.Len
+}
+
+/*
+ * One line of trace for the instruction we're about to execute, in the
+ * format of nestest.log. The CPU doesn't know where the PPU is, so the
+ * caller passes that in
+ */
+func (c *CPU6502) Trace(scanline int, dot int) string {
+	var bytes strings.Builder
+
+	pc := c.registers.pc
+	text, n := c.Disassemble(pc)
+
+	for i := range n {
+		fmt.Fprintf(&bytes, "%02X ", c.peek(pc+uint16(i)))
+	}
+
+	if !strings.HasPrefix(text, "*") {
+		text = " " + text
+	}
+
+	return fmt.Sprintf("%04X  %-9s%-32s A:%02X X:%02X Y:%02X P:%02X SP:%02X PPU:%3d,%3d CYC:%d",
+		pc, bytes.String(), text,
+		c.registers.a, c.registers.x, c.registers.y, c.registers.p, c.registers.s,
+		scanline, dot, c.cycles)
+}
@@ -0,0 +1,305 @@
+package cpu6502
+
+import (
+	"testing"
+
+	"github.com/beakeyz/gones-emu/pkg/hardware/bus"
+	"github.com/beakeyz/gones-emu/pkg/hardware/memory/ram"
+)
+
+/* Zero page byte the memory operand lives in */
+const unofficialOperand = 0x10
+
+/* Flags the unofficial opcodes can touch */
+const unofficialFlags = C6502_FLAG_NEGATIVE | C6502_FLAG_OVERFLOW | C6502_FLAG_ZERO | C6502_FLAG_CARRY
+
+/*
+ * Everything an unofficial opcode can change: A, X, the byte in memory
+ * (or the immediate, which stays put) and NVZC
+ */
+type unofficialResult struct {
+	a     uint8
+	x     uint8
+	m     uint8
+	flags uint8
+}
+
+type unofficialOp struct {
+	name   string
+	opcode byte
+	/* Is the operand an immediate instead of a zero page byte? */
+	immediate bool
+	expect    func(a uint8, x uint8, m uint8, p uint8) unofficialResult
+}
+
+var unofficialOps = []unofficialOp{
+	{"LAX", 0xa7, false, expectLax},
+	{"SAX", 0x87, false, expectSax},
+	{"DCP", 0xc7, false, expectDcp},
+	{"ISC", 0xe7, false, expectIsc},
+	{"SLO", 0x07, false, expectSlo},
+	{"RLA", 0x27, false, expectRla},
+	{"SRE", 0x47, false, expectSre},
+	{"RRA", 0x67, false, expectRra},
+	{"ANC", 0x0b, true, expectAnc},
+	{"ALR", 0x4b, true, expectAlr},
+	{"ARR", 0x6b, true, expectArr},
+	{"AXS", 0xcb, true, expectAxs},
+}
+
+/*
+ * Like the ALU rig, a program at $8000 that runs over and over with the
+ * immediates patched in between runs
+ *
+ *   LDA #p
+ *   PHA
+ *   LDX #x
+ *   LDA #a
+ *   PLP
+ *   OP  #m     (or OP $10)
+ *   JMP $8000
+ */
+type unofficialRig struct {
+	c   *CPU6502
+	zp  *ram.Ram
+	prg *ram.Ram
+}
+
+func newUnofficialRig(variant Variant, op *unofficialOp) *unofficialRig {
+	sbus, _ := bus.NewSystembus()
+
+	lo := ram.New(0x0000, 0x7fff, 0x8000)
+	hi := ram.New(0x8000, 0xffff, 0x8000)
+
+	sbus.AddComponent(lo)
+	sbus.AddComponent(hi)
+
+	prog := []byte{0xa9, 0x00, 0x48, 0xa2, 0x00, 0xa9, 0x00, 0x28, op.opcode, unofficialOperand, 0x4c, 0x00, 0x80}
+
+	for i, b := range prog {
+		hi.Write(testOrigin+uint16(i), b)
+	}
+
+	hi.Write(0xfffc, uint8(testOrigin&0xff))
+	hi.Write(0xfffd, uint8(testOrigin>>8))
+
+	c := New(sbus, variant)
+	c.Initialize()
+
+	return &unofficialRig{c: c, zp: lo, prg: hi}
+}
+
+func (rig *unofficialRig) run(op *unofficialOp, a uint8, x uint8, m uint8, p uint8) (unofficialResult, error) {
+	var cycles int
+	var res unofficialResult
+
+	rig.prg.Write(testOrigin+1, p)
+	rig.prg.Write(testOrigin+4, x)
+	rig.prg.Write(testOrigin+6, a)
+
+	if op.immediate {
+		rig.prg.Write(testOrigin+9, m)
+	} else {
+		rig.zp.Write(unofficialOperand, m)
+	}
+
+	// LDA, PHA, LDX, LDA, PLP and the operation
+	for range 6 {
+		if err := rig.c.ExecuteFrame(&cycles); err != nil {
+			return res, err
+		}
+	}
+
+	res.a = rig.c.GetAccumulator()
+	res.x = rig.c.GetX()
+	res.flags = rig.c.GetFlags() & unofficialFlags
+
+	if op.immediate {
+		res.m = m
+	} else {
+		rig.zp.Read(unofficialOperand, &res.m)
+	}
+
+	// JMP
+	if err := rig.c.ExecuteFrame(&cycles); err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+/*
+ * The flags the operation doesn't touch, straight from P
+ */
+func keptFlags(p uint8, changed uint8) uint8 {
+	return p & unofficialFlags & ^changed
+}
+
+func nzResult(p uint8, a uint8, x uint8, m uint8, nz uint8) unofficialResult {
+	return unofficialResult{a: a, x: x, m: m, flags: keptFlags(p, C6502_FLAG_NEGATIVE|C6502_FLAG_ZERO) | nzFlags(nz)}
+}
+
+func carryFlag(set bool) uint8 {
+	if set {
+		return C6502_FLAG_CARRY
+	}
+
+	return 0
+}
+
+/* LDA and LDX in one go */
+func expectLax(a uint8, x uint8, m uint8, p uint8) unofficialResult {
+	return nzResult(p, m, m, m, m)
+}
+
+/* Stores A & X, no flags */
+func expectSax(a uint8, x uint8, m uint8, p uint8) unofficialResult {
+	return unofficialResult{a: a, x: x, m: a & x, flags: keptFlags(p, 0)}
+}
+
+/* DEC, then CMP */
+func expectDcp(a uint8, x uint8, m uint8, p uint8) unofficialResult {
+	m--
+	cmp := binarySub(a, m, 0)
+
+	return unofficialResult{a: a, x: x, m: m, flags: keptFlags(p, C6502_FLAG_NEGATIVE|C6502_FLAG_ZERO|C6502_FLAG_CARRY) |
+		(cmp.flags & ^uint8(C6502_FLAG_OVERFLOW))}
+}
+
+/* INC, then SBC */
+func expectIsc(a uint8, x uint8, m uint8, p uint8) unofficialResult {
+	m++
+	sub := binarySub(a, m, 1-carryIn(p))
+
+	return unofficialResult{a: sub.value, x: x, m: m, flags: sub.flags}
+}
+
+/* ASL, then ORA */
+func expectSlo(a uint8, x uint8, m uint8, p uint8) unofficialResult {
+	res := nzResult(p, a|(m<<1), x, m<<1, a|(m<<1))
+	res.flags = res.flags&^C6502_FLAG_CARRY | carryFlag((m&0x80) != 0)
+
+	return res
+}
+
+/* ROL, then AND */
+func expectRla(a uint8, x uint8, m uint8, p uint8) unofficialResult {
+	rol := m<<1 | uint8(carryIn(p))
+
+	res := nzResult(p, a&rol, x, rol, a&rol)
+	res.flags = res.flags&^C6502_FLAG_CARRY | carryFlag((m&0x80) != 0)
+
+	return res
+}
+
+/* LSR, then EOR */
+func expectSre(a uint8, x uint8, m uint8, p uint8) unofficialResult {
+	res := nzResult(p, a^(m>>1), x, m>>1, a^(m>>1))
+	res.flags = res.flags&^C6502_FLAG_CARRY | carryFlag((m&0x01) != 0)
+
+	return res
+}
+
+/* ROR, then ADC with the carry that fell out */
+func expectRra(a uint8, x uint8, m uint8, p uint8) unofficialResult {
+	ror := m>>1 | uint8(carryIn(p))<<7
+	sum := binaryAdd(a, ror, int(m&0x01))
+
+	return unofficialResult{a: sum.value, x: x, m: ror, flags: sum.flags}
+}
+
+/* AND, with bit 7 copied into C */
+func expectAnc(a uint8, x uint8, m uint8, p uint8) unofficialResult {
+	res := nzResult(p, a&m, x, m, a&m)
+	res.flags = res.flags&^C6502_FLAG_CARRY | carryFlag(((a&m)&0x80) != 0)
+
+	return res
+}
+
+/* AND, then LSR A */
+func expectAlr(a uint8, x uint8, m uint8, p uint8) unofficialResult {
+	res := nzResult(p, (a&m)>>1, x, m, (a&m)>>1)
+	res.flags = res.flags&^C6502_FLAG_CARRY | carryFlag(((a&m)&0x01) != 0)
+
+	return res
+}
+
+/*
+ * AND, then ROR A, except C comes from bit 6 of the result and V from
+ * bit 6 XOR bit 5
+ */
+func expectArr(a uint8, x uint8, m uint8, p uint8) unofficialResult {
+	value := (a&m)>>1 | uint8(carryIn(p))<<7
+
+	res := nzResult(p, value, x, m, value)
+	res.flags &= ^uint8(C6502_FLAG_CARRY | C6502_FLAG_OVERFLOW)
+	res.flags |= carryFlag((value & 0x40) != 0)
+
+	if ((value>>6)^(value>>5))&0x01 != 0 {
+		res.flags |= C6502_FLAG_OVERFLOW
+	}
+
+	return res
+}
+
+/* X = (A & X) - m, setting flags like CMP does */
+func expectAxs(a uint8, x uint8, m uint8, p uint8) unofficialResult {
+	cmp := binarySub(a&x, m, 0)
+
+	return unofficialResult{a: a, x: cmp.value, m: m, flags: keptFlags(p, C6502_FLAG_NEGATIVE|C6502_FLAG_ZERO|C6502_FLAG_CARRY) |
+		(cmp.flags & ^uint8(C6502_FLAG_OVERFLOW))}
+}
+
+func TestUnofficialOps(t *testing.T) {
+	variants := map[string]Variant{
+		"2a03": C6502_VARIANT_2A03,
+		"nmos": C6502_VARIANT_NMOS,
+	}
+
+	for name, variant := range variants {
+		for i := range unofficialOps {
+			op := &unofficialOps[i]
+
+			t.Run(name+"/"+op.name, func(t *testing.T) {
+				checkUnofficialOp(t, variant, op)
+			})
+		}
+	}
+}
+
+func checkUnofficialOp(t *testing.T, variant Variant, op *unofficialOp) {
+	rig := newUnofficialRig(variant, op)
+	mismatches := 0
+
+	// Decimal mode stays off, none of these care on the 2A03. The other
+	// flags go both ways, to see which ones get left alone
+	for _, p := range []uint8{C6502_FLAG_CARRY, C6502_FLAG_OVERFLOW | C6502_FLAG_NEGATIVE | C6502_FLAG_ZERO} {
+		for a := range 256 {
+			// X with the nibbles of A swapped, so A & X isn't just A
+			x := uint8(a<<4 | a>>4)
+
+			for m := range 256 {
+				want := op.expect(uint8(a), x, uint8(m), p)
+				got, err := rig.run(op, uint8(a), x, uint8(m), p)
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got == want {
+					continue
+				}
+
+				mismatches++
+
+				if mismatches <= maxReportedMismatches {
+					t.Errorf("a=$%02x x=$%02x m=$%02x p=$%02x: got %+v, want %+v", a, x, m, p, got, want)
+				}
+			}
+		}
+	}
+
+	if mismatches > maxReportedMismatches {
+		t.Errorf("%d more mismatches", mismatches-maxReportedMismatches)
+	}
+}
@@ -0,0 +1,87 @@
+package hardware
+
+import (
+	"fmt"
+
+	"github.com/beakeyz/gones-emu/pkg/hardware/controller"
+	"github.com/beakeyz/gones-emu/pkg/video"
+)
+
+/*
+ * Which host key holds down which button, on which controller
+ */
+type Binding struct {
+	Key    video.Key
+	Port   int
+	Button controller.Button
+}
+
+/*
+ * Player 1 on the arrows, player 2 on WASD. These stay clear of the run
+ * loop hotkeys (P, N, I, TAB, F5, F9 and ESC)
+ */
+func DefaultBindings() []Binding {
+	return []Binding{
+		{video.KEY_UP, 0, controller.BUTTON_UP},
+		{video.KEY_DOWN, 0, controller.BUTTON_DOWN},
+		{video.KEY_LEFT, 0, controller.BUTTON_LEFT},
+		{video.KEY_RIGHT, 0, controller.BUTTON_RIGHT},
+		{video.KEY_X, 0, controller.BUTTON_A},
+		{video.KEY_Z, 0, controller.BUTTON_B},
+		{video.KEY_RSHIFT, 0, controller.BUTTON_SELECT},
+		{video.KEY_RETURN, 0, controller.BUTTON_START},
+
+		{video.KEY_W, 1, controller.BUTTON_UP},
+		{video.KEY_S, 1, controller.BUTTON_DOWN},
+		{video.KEY_A, 1, controller.BUTTON_LEFT},
+		{video.KEY_D, 1, controller.BUTTON_RIGHT},
+		{video.KEY_G, 1, controller.BUTTON_A},
+		{video.KEY_F, 1, controller.BUTTON_B},
+		{video.KEY_1, 1, controller.BUTTON_SELECT},
+		{video.KEY_2, 1, controller.BUTTON_START},
+	}
+}
+
+/*
+ * Use @bindings instead of the defaults. Every binding has to go to a
+ * port that exists
+ */
+func (system *NESSystem) SetBindings(bindings []Binding) error {
+	for _, b := range bindings {
+		if system.Ports.Controller(b.Port) == nil {
+			return fmt.Errorf("hardware: no controller in port %d", b.Port)
+		}
+	}
+
+	system.bindings = bindings
+
+	return nil
+}
+
+/*
+ * Copy the host keyboard over to the controllers. Once a frame is plenty,
+ * games only read them once a frame anyway
+ */
+func (system *NESSystem) pollInput() {
+	var held [2]uint8
+
+	for _, b := range system.bindings {
+		if system.vbackend.IsKeyPressed(b.Key) {
+			held[b.Port] |= uint8(b.Button)
+		}
+	}
+
+	for port := range held {
+		ctl := system.Ports.Controller(port)
+
+		if ctl == nil {
+			continue
+		}
+
+		for i := range 8 {
+			button := controller.Button(1 << i)
+
+			ctl.SetButton(button, (held[port]&uint8(button)) != 0)
+		}
+	}
+}
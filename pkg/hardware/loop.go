@@ -0,0 +1,204 @@
+package hardware
+
+import (
+	"time"
+
+	"github.com/beakeyz/gones-emu/pkg/debug"
+	"github.com/beakeyz/gones-emu/pkg/video"
+)
+
+const (
+	/* If we're this many frames behind, give up on catching up */
+	PACER_MAX_LAG_FRAMES = 4
+)
+
+type RunMode int
+
+const (
+	/* Frames at the speed of a real NES */
+	RUN_MODE_NORMAL RunMode = iota
+	/* Nothing runs, unless we get asked to advance a frame or step */
+	RUN_MODE_PAUSED
+	/* Frames as fast as we can make them */
+	RUN_MODE_FAST_FORWARD
+)
+
+/*
+ * Hotkeys for the run loop
+ */
+const (
+	KEY_QUIT          = video.KEY_ESCAPE
+	KEY_PAUSE         = video.KEY_P
+	KEY_FRAME_ADVANCE = video.KEY_N
+	KEY_STEP          = video.KEY_I
+	KEY_FAST_FORWARD  = video.KEY_TAB
+	KEY_NEXT_PALETTE  = video.KEY_F5
+	KEY_RECORD        = video.KEY_F9
+)
+
+/*
+ * Keeps us at the frame rate of our region (60.0988 Hz for NTSC, ~50 Hz
+ * for PAL and Dendy). Deadlines are absolute, so oversleeping
+ * one frame gets made up on the next one instead of adding up
+ */
+type framePacer struct {
+	period time.Duration
+	next   time.Time
+}
+
+func newFramePacer(rate float64) *framePacer {
+	pacer := &framePacer{
+		period: time.Duration(float64(time.Second) / rate),
+	}
+
+	pacer.reset()
+
+	return pacer
+}
+
+/*
+ * Start counting from now, after we weren't running in real time
+ */
+func (pacer *framePacer) reset() {
+	pacer.next = time.Now().Add(pacer.period)
+}
+
+/*
+ * Sleep until the current frame is due
+ */
+func (pacer *framePacer) wait() {
+	now := time.Now()
+
+	if pacer.next.After(now) {
+		time.Sleep(pacer.next.Sub(now))
+	} else if now.Sub(pacer.next) > PACER_MAX_LAG_FRAMES*pacer.period {
+		// We're way behind (debugger, slow host, ...). Don't try to run a
+		// bunch of frames back to back, just continue from here
+		pacer.reset()
+		return
+	}
+
+	pacer.next = pacer.next.Add(pacer.period)
+}
+
+/*
+ * Deal with everything the backend has for us. Returns false when we
+ * should quit
+ */
+func (system *NESSystem) handleEvents(mode *RunMode, advance *bool, step *bool) bool {
+	for {
+		event := system.vbackend.CollectEvent()
+
+		if event == nil {
+			return true
+		}
+
+		switch e := event.(type) {
+		case *video.QuitEvent:
+			return false
+		case *video.KeyEvent:
+			// Hotkeys act once per press, holding P shouldn't flicker
+			if !e.Pressed || e.Repeat {
+				break
+			}
+
+			switch e.Key {
+			case KEY_QUIT:
+				return false
+			case KEY_PAUSE:
+				if *mode == RUN_MODE_PAUSED {
+					*mode = RUN_MODE_NORMAL
+				} else {
+					*mode = RUN_MODE_PAUSED
+				}
+			case KEY_FRAME_ADVANCE:
+				*mode = RUN_MODE_PAUSED
+				*advance = true
+			case KEY_STEP:
+				*mode = RUN_MODE_PAUSED
+				*step = true
+			case KEY_NEXT_PALETTE:
+				system.nextPalette()
+			case KEY_RECORD:
+				system.toggleRecording()
+			}
+		}
+	}
+}
+
+func (mode RunMode) String() string {
+	switch mode {
+	case RUN_MODE_PAUSED:
+		return "paused"
+	case RUN_MODE_FAST_FORWARD:
+		return "fast forward"
+	}
+
+	return ""
+}
+
+/*
+ * The main loop. Runs a frame, draws it and waits for the next one to be
+ * due, unless we're paused or fast-forwarding
+ *
+ * P pauses, N advances a single frame, I steps a single instruction and
+ * holding TAB fast-forwards. F9 starts and stops recording the audio
+ */
+func (system *NESSystem) StartLoop() {
+	var err error
+
+	mode := RUN_MODE_NORMAL
+	pacer := newFramePacer(system.region.Timing().FrameRate())
+
+	for {
+		advance := false
+		step := false
+
+		if !system.handleEvents(&mode, &advance, &step) {
+			return
+		}
+
+		// Fast-forward only lasts as long as the key is held
+		if system.vbackend.IsKeyPressed(KEY_FAST_FORWARD) {
+			if mode == RUN_MODE_NORMAL {
+				mode = RUN_MODE_FAST_FORWARD
+			}
+		} else if mode == RUN_MODE_FAST_FORWARD {
+			mode = RUN_MODE_NORMAL
+			pacer.reset()
+		}
+
+		system.pollInput()
+
+		switch {
+		case mode != RUN_MODE_PAUSED || advance:
+			err = system.runFrame()
+		case step:
+			err = system.SystemFrame()
+		}
+
+		if err != nil {
+			debug.Error("Stopped with the error: %s\n", err.Error())
+			return
+		}
+
+		system.preDraw()
+
+		if mode != RUN_MODE_NORMAL {
+			system.vbackend.DrawText(0, 8, mode.String(), video.ColorWhite())
+		}
+
+		if system.IsRecording() {
+			system.vbackend.DrawText(0, 16, "recording", video.ColorWhite())
+		}
+
+		system.vbackend.Flush()
+
+		if mode == RUN_MODE_FAST_FORWARD {
+			continue
+		}
+
+		// Pausing still goes at frame rate, so we don't spin on the events
+		pacer.wait()
+	}
+}
@@ -2,20 +2,62 @@ package cartridge
 
 import (
 	"fmt"
+	"hash/crc32"
 	"os"
 
 	"github.com/beakeyz/gones-emu/pkg/debug"
 	"github.com/beakeyz/gones-emu/pkg/hardware/bus"
+	"github.com/beakeyz/gones-emu/pkg/hardware/comp"
+	"github.com/beakeyz/gones-emu/pkg/hardware/memory/ram"
 	"github.com/beakeyz/gones-emu/pkg/hardware/memory/rom"
 	"github.com/beakeyz/gones-emu/pkg/hardware/mirror"
+	"github.com/beakeyz/gones-emu/pkg/hardware/ppu"
+	"github.com/beakeyz/gones-emu/pkg/hardware/region"
+)
+
+const (
+	/* iNES flags 6 */
+	INES_FLAG_VERTICAL_MIRROR = 0x01
+	INES_FLAG_BATTERY         = 0x02
+	INES_FLAG_TRAINER         = 0x04
+	INES_FLAG_FOUR_SCREEN     = 0x08
+
+	/* iNES flags 7 */
+	INES_FLAG_NES2_MASK = 0x0c
+	INES_FLAG_NES2      = 0x08
+
+	/* iNES flags 9 */
+	INES_FLAG_PAL = 0x01
+
+	/* NES 2.0 byte 12, CPU/PPU timing */
+	NES2_TIMING_MASK  = 0x03
+	NES2_TIMING_NTSC  = 0
+	NES2_TIMING_PAL   = 1
+	NES2_TIMING_MULTI = 2
+	NES2_TIMING_DENDY = 3
 )
 
 type NESFileHeader struct {
 	sig       [4]byte
 	prgrom_sz int
 	chrrom_sz int
-	flags     [5]byte
-	reserved  [5]byte
+	/* Header bytes 6 to 10 */
+	flags [5]byte
+	/* Header bytes 11 to 15. Padding on iNES, used by NES 2.0 */
+	reserved [5]byte
+}
+
+/*
+ * What we found out about a cartridge while loading it
+ */
+type Info struct {
+	Mapper    uint8
+	Mirroring ppu.Mirroring
+	/* CRC32 of everything after the header (PRG + CHR) */
+	Crc32 uint32
+	/* The region the cartridge is for, if anything told us */
+	Region      region.Region
+	RegionKnown bool
 }
 
 func newNesHeader(data []byte) NESFileHeader {
@@ -34,13 +76,73 @@ func newNesHeader(data []byte) NESFileHeader {
 
 	ret.chrrom_sz = int(data[5]) * 8192
 
-	ret.flags[0] = data[6]
-	ret.flags[1] = data[7]
+	copy(ret.flags[:], data[6:11])
+	copy(ret.reserved[:], data[11:16])
 
 	return ret
 }
 
-func LoadCardridge(cpuBus *bus.SystemBus, ppuBus *bus.SystemBus, filepath string) error {
+/*
+ * The mirroring the board is soldered for. Four-screen wins over the
+ * mirroring bit, since those carts ignore it
+ */
+func (header *NESFileHeader) mirroring() ppu.Mirroring {
+	if (header.flags[0] & INES_FLAG_FOUR_SCREEN) != 0 {
+		return ppu.MIRROR_FOUR_SCREEN
+	}
+
+	if (header.flags[0] & INES_FLAG_VERTICAL_MIRROR) != 0 {
+		return ppu.MIRROR_VERTICAL
+	}
+
+	return ppu.MIRROR_HORIZONTAL
+}
+
+func (header *NESFileHeader) isNes2() bool {
+	return (header.flags[1] & INES_FLAG_NES2_MASK) == INES_FLAG_NES2
+}
+
+/*
+ * The region the header says we're for. NES 2.0 has a proper field for
+ * it. Plain iNES has a PAL bit in byte 9, but hardly any dump sets it, so
+ * that one only counts when it's set
+ */
+func (header *NESFileHeader) region() (region.Region, bool) {
+	if header.isNes2() {
+		switch header.reserved[1] & NES2_TIMING_MASK {
+		case NES2_TIMING_PAL:
+			return region.REGION_PAL, true
+		case NES2_TIMING_DENDY:
+			return region.REGION_DENDY, true
+		}
+
+		// Multi-region carts run fine on NTSC
+		return region.REGION_NTSC, true
+	}
+
+	if (header.flags[3] & INES_FLAG_PAL) != 0 {
+		return region.REGION_PAL, true
+	}
+
+	return region.REGION_NTSC, false
+}
+
+/*
+ * The pattern tables at $0000-$1FFF. Carts without CHR ROM have 8K of
+ * RAM there instead, which the game fills in itself. Either way it stops
+ * at $1FFF, the nametables come after
+ */
+func newChr(data []byte) comp.Component {
+	if len(data) == 0 {
+		return ram.New(ppu.PPU_CHR_ROM_BASE, ppu.PPU_CHR_ROM_END-1, ppu.PPU_CHR_ROM_SZ)
+	}
+
+	size := min(len(data), ppu.PPU_CHR_ROM_SZ)
+
+	return rom.New(ppu.PPU_CHR_ROM_BASE, uint16(ppu.PPU_CHR_ROM_BASE+size-1), uint32(size), data)
+}
+
+func LoadCardridge(cpuBus *bus.SystemBus, ppuBus *bus.SystemBus, vram *ppu.Vram, filepath string) (*Info, error) {
 	var f *os.File
 	var err error
 	var buffer []byte = make([]byte, 16)
@@ -51,7 +153,7 @@ func LoadCardridge(cpuBus *bus.SystemBus, ppuBus *bus.SystemBus, filepath string
 	f, err = os.Open(filepath)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Murder the file
@@ -60,11 +162,11 @@ func LoadCardridge(cpuBus *bus.SystemBus, ppuBus *bus.SystemBus, filepath string
 	_, err = f.Read(buffer)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if buffer[0] != 'N' || buffer[1] != 'E' || buffer[2] != 'S' {
-		return fmt.Errorf("Invalid file loaded : %c%c%c", buffer[0], buffer[1], buffer[2])
+		return nil, fmt.Errorf("Invalid file loaded : %c%c%c", buffer[0], buffer[1], buffer[2])
 	}
 
 	header := newNesHeader(buffer)
@@ -75,10 +177,13 @@ func LoadCardridge(cpuBus *bus.SystemBus, ppuBus *bus.SystemBus, filepath string
 
 	read_off := 16
 
-	if (header.flags[0] & 0x3) == 0x3 {
+	// Skip the trainer, if there is one
+	if (header.flags[0] & INES_FLAG_TRAINER) != 0 {
 		read_off += 512
 	}
 
+	vram.SetMirroring(header.mirroring())
+
 	// Make the buffer
 	prg_buffer = make([]byte, header.prgrom_sz)
 
@@ -86,7 +191,7 @@ func LoadCardridge(cpuBus *bus.SystemBus, ppuBus *bus.SystemBus, filepath string
 	_, err = f.ReadAt(prg_buffer, int64(read_off))
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	read_off += header.prgrom_sz
@@ -95,23 +200,23 @@ func LoadCardridge(cpuBus *bus.SystemBus, ppuBus *bus.SystemBus, filepath string
 	_, err = f.ReadAt(chr_buffer, int64(read_off))
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	switch mapperNumber {
 	case 0:
 		var prg_rom *rom.Rom
-		var chr_rom *rom.Rom
+		var chr comp.Component
 
 		prgbase := 0x8000
 		prg_size := header.prgrom_sz
 
 		prg_rom = rom.New(uint16(prgbase), uint16(prgbase+prg_size)-1, uint32(prg_size), prg_buffer)
-		chr_rom = rom.New(0, uint16(header.chrrom_sz)-1, uint32(header.chrrom_sz), chr_buffer)
+		chr = newChr(chr_buffer)
 
 		// Add an extra mirror, to account for the 16k ROM
 		if prg_size == 0x4000 {
-			mirror := mirror.New(uint16(prgbase+prg_size), uint16(prgbase+2*prg_size), prg_rom)
+			mirror := mirror.New(uint16(prgbase+prg_size), uint16(prgbase+2*prg_size-1), prg_rom)
 
 			cpuBus.AddComponent(mirror)
 		}
@@ -121,12 +226,25 @@ func LoadCardridge(cpuBus *bus.SystemBus, ppuBus *bus.SystemBus, filepath string
 
 		debug.Log("PRG size: 0x%x (0x%x -> 0x%x)\n", header.prgrom_sz, prg_rom.StartAddr(), prg_rom.EndAddr())
 
-		// Add the character rom to the PPU bus
-		ppuBus.AddComponent(chr_rom)
+		// Add the character rom (or ram) to the PPU bus
+		ppuBus.AddComponent(chr)
 	default:
-		return fmt.Errorf("found unimplemented mapper")
+		return nil, fmt.Errorf("found unimplemented mapper")
+
+	}
+
+	info := &Info{
+		Mapper:    mapperNumber,
+		Mirroring: header.mirroring(),
+		Crc32:     crc32.Update(crc32.ChecksumIEEE(prg_buffer), crc32.IEEETable, chr_buffer),
+	}
+
+	info.Region, info.RegionKnown = header.region()
 
+	// The header didn't tell us, maybe we know this game
+	if !info.RegionKnown {
+		info.Region, info.RegionKnown = LookupRegion(info.Crc32)
 	}
 
-	return nil
+	return info, nil
 }
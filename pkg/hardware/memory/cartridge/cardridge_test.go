@@ -0,0 +1,86 @@
+package cartridge
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/beakeyz/gones-emu/pkg/hardware/bus"
+	"github.com/beakeyz/gones-emu/pkg/hardware/ppu"
+)
+
+/*
+ * An NROM image with one 16K PRG bank and however many CHR banks we ask for
+ */
+func writeNrom(t *testing.T, chr_banks uint8) string {
+	path := filepath.Join(t.TempDir(), "test.nes")
+
+	data := []byte{'N', 'E', 'S', 0x1a, 1, chr_banks, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	data = append(data, make([]byte, 0x4000)...)
+
+	chr := make([]byte, int(chr_banks)*0x2000)
+
+	for i := range chr {
+		chr[i] = uint8(i)
+	}
+
+	data = append(data, chr...)
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func loadNrom(t *testing.T, chr_banks uint8) *bus.SystemBus {
+	cpuBus, _ := bus.NewSystembus()
+	ppuBus, _ := bus.NewSystembus()
+
+	if _, err := LoadCardridge(cpuBus, ppuBus, ppu.NewVram(ppu.MIRROR_HORIZONTAL), writeNrom(t, chr_banks)); err != nil {
+		t.Fatal(err)
+	}
+
+	return ppuBus
+}
+
+func TestChrRam(t *testing.T) {
+	ppuBus := loadNrom(t, 0)
+
+	for _, addr := range []uint16{0x0000, 0x0fff, 0x1000, 0x1fff} {
+		var value uint8
+
+		if err := ppuBus.Write(addr, uint8(addr>>4)); err != nil {
+			t.Fatalf("$%04x: %v", addr, err)
+		}
+
+		if err := ppuBus.Read(addr, &value); err != nil {
+			t.Fatalf("$%04x: %v", addr, err)
+		}
+
+		if value != uint8(addr>>4) {
+			t.Errorf("$%04x: read back %02x, want %02x", addr, value, uint8(addr>>4))
+		}
+	}
+
+	var value uint8
+
+	// Nothing else on this bus, so the nametables must not be CHR
+	if err := ppuBus.Read(0x2000, &value); err == nil {
+		t.Errorf("CHR RAM answered for $2000")
+	}
+}
+
+func TestChrRom(t *testing.T) {
+	ppuBus := loadNrom(t, 1)
+
+	var value uint8
+
+	if err := ppuBus.Read(0x1234, &value); err != nil || value != 0x34 {
+		t.Errorf("$1234: got %02x (%v), want 34", value, err)
+	}
+
+	if err := ppuBus.Read(0x2000, &value); err == nil {
+		t.Errorf("CHR ROM answered for $2000")
+	}
+}
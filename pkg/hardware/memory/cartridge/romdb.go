@@ -0,0 +1,77 @@
+package cartridge
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/beakeyz/gones-emu/pkg/hardware/region"
+)
+
+/*
+ * Most iNES dumps don't say which region they're for, so we keep a list
+ * of games we know, by the CRC32 of their PRG and CHR data (the header
+ * doesn't count, since everyone's dumps have a different one)
+ *
+ * There's nothing built in, games without a region in their header are
+ * NTSC unless a database loaded with LoadRomDatabase says otherwise
+ */
+var romDatabase = map[uint32]region.Region{}
+
+func LookupRegion(crc uint32) (region.Region, bool) {
+	r, ok := romDatabase[crc]
+
+	return r, ok
+}
+
+/*
+ * Add the games in a database file (the -romdb flag) to the ones we know. Every line looks
+ * like this, and anything after a # is ignored:
+ *
+ *   9a2db086 ntsc Super Mario Bros. (World)
+ */
+func LoadRomDatabase(path string) error {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+
+		if len(fields) == 0 {
+			continue
+		}
+
+		if len(fields) < 2 {
+			return fmt.Errorf("cartridge: %s:%d: expected a CRC32 and a region", path, line)
+		}
+
+		crc, err := strconv.ParseUint(fields[0], 16, 32)
+
+		if err != nil {
+			return fmt.Errorf("cartridge: %s:%d: bad CRC32 '%s'", path, line, fields[0])
+		}
+
+		r, err := region.Parse(fields[1])
+
+		if err != nil {
+			return fmt.Errorf("cartridge: %s:%d: %s", path, line, err.Error())
+		}
+
+		romDatabase[uint32(crc)] = r
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,59 @@
+package cartridge
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/beakeyz/gones-emu/pkg/hardware/region"
+)
+
+func TestLoadRomDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "romdb.txt")
+
+	db := "# crc32 region name\n" +
+		"\n" +
+		"12345678 pal  Some Game (Europe)\n" +
+		"9abcdef0 dendy  # no name\n"
+
+	if err := os.WriteFile(path, []byte(db), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadRomDatabase(path); err != nil {
+		t.Fatal(err)
+	}
+
+	for crc, want := range map[uint32]region.Region{
+		0x12345678: region.REGION_PAL,
+		0x9abcdef0: region.REGION_DENDY,
+	} {
+		got, ok := LookupRegion(crc)
+
+		if !ok || got != want {
+			t.Errorf("%08x: got %s (found=%v), want %s", crc, got, ok, want)
+		}
+	}
+
+	if _, ok := LookupRegion(0xdeadbeef); ok {
+		t.Errorf("found a game that isn't in the database")
+	}
+}
+
+func TestLoadRomDatabaseErrors(t *testing.T) {
+	for _, db := range []string{
+		"12345678\n",
+		"nothex pal\n",
+		"12345678 secam\n",
+	} {
+		path := filepath.Join(t.TempDir(), "romdb.txt")
+
+		if err := os.WriteFile(path, []byte(db), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := LoadRomDatabase(path); err == nil {
+			t.Errorf("%q: loaded without an error", db)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package ppu
+
+/*
+ * The background pipeline
+ *
+ * The PPU keeps its scroll position in the same registers it uses to
+ * address VRAM. These are the 'loopy' registers (named after the guy who
+ * figured them out):
+ *
+ *   v: current VRAM address (15 bits)
+ *   t: temporary VRAM address, the top left tile of the screen
+ *   x: fine X scroll (3 bits)
+ *   w: write toggle shared by $2005 and $2006
+ *
+ * v and t are laid out like this:
+ *
+ *   yyy NN YYYYY XXXXX
+ *   |   |  |     +------ coarse X scroll
+ *   |   |  +------------ coarse Y scroll
+ *   |   +--------------- nametable select
+ *   +------------------- fine Y scroll
+ *
+ * See: https://www.nesdev.org/wiki/PPU_scrolling
+ * See: https://www.nesdev.org/wiki/PPU_rendering
+ */
+
+const (
+	LOOPY_COARSE_X  = 0x001f
+	LOOPY_COARSE_Y  = 0x03e0
+	LOOPY_NT_X      = 0x0400
+	LOOPY_NT_Y      = 0x0800
+	LOOPY_NT_SELECT = LOOPY_NT_X | LOOPY_NT_Y
+	LOOPY_FINE_Y    = 0x7000
+
+	/* Everything that gets copied from t to v at the start of a line */
+	LOOPY_HORIZONTAL = LOOPY_COARSE_X | LOOPY_NT_X
+	/* And everything that gets copied at the start of a frame */
+	LOOPY_VERTICAL = LOOPY_COARSE_Y | LOOPY_NT_Y | LOOPY_FINE_Y
+)
+
+func (ppu *PPU) renderingEnabled() bool {
+	return (ppu.mask_register & (PPU_MASK_RENDER_BG | PPU_MASK_RENDER_SPRITES)) != 0
+}
+
+/*
+ * Move v to the next tile, switching horizontal nametables when we run
+ * off the right side of one
+ */
+func (ppu *PPU) incrementScrollX() {
+	if (ppu.vram_addr & LOOPY_COARSE_X) == 31 {
+		ppu.vram_addr &= ^uint16(LOOPY_COARSE_X)
+		ppu.vram_addr ^= LOOPY_NT_X
+	} else {
+		ppu.vram_addr++
+	}
+}
+
+/*
+ * Move v down a pixel. Fine Y overflows into coarse Y, and coarse Y wraps
+ * at 30 rows into the other vertical nametable. Rows 30 and 31 are the
+ * attribute table, which wrap without switching nametables
+ */
+func (ppu *PPU) incrementScrollY() {
+	if (ppu.vram_addr & LOOPY_FINE_Y) != LOOPY_FINE_Y {
+		ppu.vram_addr += 0x1000
+		return
+	}
+
+	ppu.vram_addr &= ^uint16(LOOPY_FINE_Y)
+
+	coarse_y := (ppu.vram_addr & LOOPY_COARSE_Y) >> 5
+
+	switch coarse_y {
+	case 29:
+		coarse_y = 0
+		ppu.vram_addr ^= LOOPY_NT_Y
+	case 31:
+		coarse_y = 0
+	default:
+		coarse_y++
+	}
+
+	ppu.vram_addr = (ppu.vram_addr & ^uint16(LOOPY_COARSE_Y)) | (coarse_y << 5)
+}
+
+func (ppu *PPU) transferScrollX() {
+	ppu.vram_addr = (ppu.vram_addr & ^uint16(LOOPY_HORIZONTAL)) | (ppu.tmp_addr & LOOPY_HORIZONTAL)
+}
+
+func (ppu *PPU) transferScrollY() {
+	ppu.vram_addr = (ppu.vram_addr & ^uint16(LOOPY_VERTICAL)) | (ppu.tmp_addr & LOOPY_VERTICAL)
+}
+
+/*
+ * Grab a byte off the PPU bus. Rendering doesn't care about unmapped
+ * space, it just sees a zero there
+ */
+func (ppu *PPU) fetch(addr uint16) uint8 {
+	var value uint8
+
+	if ppu.ppuRead(addr&0x3fff, &value) != nil {
+		return 0
+	}
+
+	return value
+}
+
+func (ppu *PPU) fetchNametableByte() {
+	ppu.bg_next_tile = ppu.fetch(PPU_VRAM_BASE | (ppu.vram_addr & 0x0fff))
+}
+
+/*
+ * Every byte of the attribute table covers 4x4 tiles, two bits for every
+ * 2x2 quadrant
+ */
+func (ppu *PPU) fetchAttributeByte() {
+	v := ppu.vram_addr
+
+	addr := 0x23c0 | (v & LOOPY_NT_SELECT) | ((v >> 4) & 0x38) | ((v >> 2) & 0x07)
+	attr := ppu.fetch(addr)
+
+	if (v & 0x40) != 0 {
+		attr >>= 4
+	}
+
+	if (v & 0x02) != 0 {
+		attr >>= 2
+	}
+
+	ppu.bg_next_attr = attr & 0x03
+}
+
+func (ppu *PPU) bgPatternAddr() uint16 {
+	var base uint16
+
+	if (ppu.ctl_register & PPU_CTL_BG_PATTERN_TBL_ADDR) != 0 {
+		base = 0x1000
+	}
+
+	fine_y := (ppu.vram_addr & LOOPY_FINE_Y) >> 12
+
+	return base + uint16(ppu.bg_next_tile)*16 + fine_y
+}
+
+func (ppu *PPU) fetchPatternLo() {
+	ppu.bg_next_lo = ppu.fetch(ppu.bgPatternAddr())
+}
+
+func (ppu *PPU) fetchPatternHi() {
+	ppu.bg_next_hi = ppu.fetch(ppu.bgPatternAddr() + 8)
+}
+
+/*
+ * Put the tile we just fetched into the low byte of the shifters. The
+ * attribute bits get stretched out over all 8 pixels of the tile
+ */
+func (ppu *PPU) loadBgShifters() {
+	ppu.bg_shift_lo = (ppu.bg_shift_lo & 0xff00) | uint16(ppu.bg_next_lo)
+	ppu.bg_shift_hi = (ppu.bg_shift_hi & 0xff00) | uint16(ppu.bg_next_hi)
+
+	ppu.bg_shift_attr_lo &= 0xff00
+	ppu.bg_shift_attr_hi &= 0xff00
+
+	if (ppu.bg_next_attr & 0x01) != 0 {
+		ppu.bg_shift_attr_lo |= 0x00ff
+	}
+
+	if (ppu.bg_next_attr & 0x02) != 0 {
+		ppu.bg_shift_attr_hi |= 0x00ff
+	}
+}
+
+func (ppu *PPU) shiftBgShifters() {
+	if (ppu.mask_register & PPU_MASK_RENDER_BG) == 0 {
+		return
+	}
+
+	ppu.bg_shift_lo <<= 1
+	ppu.bg_shift_hi <<= 1
+	ppu.bg_shift_attr_lo <<= 1
+	ppu.bg_shift_attr_hi <<= 1
+}
+
+/*
+ * The background fetches for a single dot of a visible or pre-render
+ * line. Every tile takes 8 dots: nametable, attribute, pattern low and
+ * pattern high byte, two dots each
+ */
+func (ppu *PPU) renderBackground() {
+	dot := ppu.pixel_x
+
+	if (dot >= 2 && dot <= 257) || (dot >= 322 && dot <= 337) {
+		ppu.shiftBgShifters()
+	}
+
+	if (dot >= 1 && dot <= 256) || (dot >= 321 && dot <= 336) {
+		switch (dot - 1) % 8 {
+		case 0:
+			ppu.loadBgShifters()
+			ppu.fetchNametableByte()
+		case 2:
+			ppu.fetchAttributeByte()
+		case 4:
+			ppu.fetchPatternLo()
+		case 6:
+			ppu.fetchPatternHi()
+		case 7:
+			ppu.incrementScrollX()
+		}
+	}
+
+	switch {
+	case dot == 256:
+		ppu.incrementScrollY()
+	case dot == 257:
+		ppu.loadBgShifters()
+		ppu.transferScrollX()
+	case dot == 337 || dot == 339:
+		// Two nametable fetches nobody uses. Some mappers count these
+		ppu.fetchNametableByte()
+	}
+
+	if ppu.pixel_y == ppu.prerender_line && dot >= 280 && dot <= 304 {
+		ppu.transferScrollY()
+	}
+}
+
+/*
+ * The 2 bit pixel and 2 bit palette the background wants at the current
+ * dot, with fine X picking which bit of the shifters we look at
+ */
+func (ppu *PPU) bgPixel() (uint8, uint8) {
+	if (ppu.mask_register & PPU_MASK_RENDER_BG) == 0 {
+		return 0, 0
+	}
+
+	// The leftmost 8 pixels can be masked off
+	if ppu.pixel_x <= 8 && (ppu.mask_register&PPU_MASK_BG_SHOW_LEFT8) == 0 {
+		return 0, 0
+	}
+
+	mux := uint16(0x8000) >> ppu.fine_x
+
+	var pixel uint8
+	var palette uint8
+
+	if (ppu.bg_shift_lo & mux) != 0 {
+		pixel |= 0x01
+	}
+
+	if (ppu.bg_shift_hi & mux) != 0 {
+		pixel |= 0x02
+	}
+
+	if (ppu.bg_shift_attr_lo & mux) != 0 {
+		palette |= 0x01
+	}
+
+	if (ppu.bg_shift_attr_hi & mux) != 0 {
+		palette |= 0x02
+	}
+
+	return pixel, palette
+}
@@ -0,0 +1,195 @@
+package ppu
+
+import (
+	"testing"
+)
+
+/* Any visible line will do */
+const testScrollLine = 40
+
+/*
+ * Build a v/t value out of its fields
+ */
+func loopy(fine_y uint16, nt uint16, coarse_y uint16, coarse_x uint16) uint16 {
+	return fine_y<<12 | nt<<10 | coarse_y<<5 | coarse_x
+}
+
+func TestIncrementScrollX(t *testing.T) {
+	tests := []struct {
+		v    uint16
+		want uint16
+	}{
+		{loopy(0, 0, 0, 0), loopy(0, 0, 0, 1)},
+		{loopy(5, 2, 7, 30), loopy(5, 2, 7, 31)},
+		{loopy(5, 2, 7, 31), loopy(5, 3, 7, 0)},
+		{loopy(5, 3, 7, 31), loopy(5, 2, 7, 0)},
+	}
+
+	for _, test := range tests {
+		ppu := newTestPpu()
+		ppu.vram_addr = test.v
+		ppu.incrementScrollX()
+
+		if ppu.vram_addr != test.want {
+			t.Errorf("v=%04x: got %04x, want %04x", test.v, ppu.vram_addr, test.want)
+		}
+	}
+}
+
+func TestIncrementScrollY(t *testing.T) {
+	tests := []struct {
+		v    uint16
+		want uint16
+	}{
+		{loopy(0, 0, 4, 3), loopy(1, 0, 4, 3)},
+		{loopy(6, 1, 4, 3), loopy(7, 1, 4, 3)},
+		{loopy(7, 1, 4, 3), loopy(0, 1, 5, 3)},
+		// Off the bottom of a nametable, into the other one
+		{loopy(7, 0, 29, 3), loopy(0, 2, 0, 3)},
+		{loopy(7, 3, 29, 3), loopy(0, 1, 0, 3)},
+		// The attribute rows wrap without switching
+		{loopy(7, 0, 30, 3), loopy(0, 0, 31, 3)},
+		{loopy(7, 2, 31, 3), loopy(0, 2, 0, 3)},
+	}
+
+	for _, test := range tests {
+		ppu := newTestPpu()
+		ppu.vram_addr = test.v
+		ppu.incrementScrollY()
+
+		if ppu.vram_addr != test.want {
+			t.Errorf("v=%04x: got %04x, want %04x", test.v, ppu.vram_addr, test.want)
+		}
+	}
+}
+
+/*
+ * v over a whole visible line: 32 coarse X increments and a fine Y one by
+ * dot 256, the horizontal bits of t at dot 257 and two more coarse X
+ * increments for the tiles fetched ahead of the next line
+ */
+func TestScrollScanline(t *testing.T) {
+	tests := []struct {
+		name string
+		v    uint16
+		t    uint16
+		/* v right after dot 256 */
+		dot256 uint16
+		/* v right after dot 257 */
+		dot257 uint16
+		/* v at the start of the next line */
+		end uint16
+	}{
+		{
+			"plain", loopy(0, 0, 5, 3), loopy(2, 0, 9, 7),
+			loopy(1, 1, 5, 3), loopy(1, 0, 5, 7), loopy(1, 0, 5, 9),
+		},
+		{
+			"next row", loopy(7, 2, 5, 3), loopy(0, 0, 0, 0),
+			loopy(0, 3, 6, 3), loopy(0, 2, 6, 0), loopy(0, 2, 6, 2),
+		},
+		{
+			"nametable wrap", loopy(7, 0, 29, 0), loopy(0, 1, 0, 30),
+			loopy(0, 3, 0, 0), loopy(0, 3, 0, 30), loopy(0, 2, 0, 0),
+		},
+		{
+			"attribute row wrap", loopy(7, 0, 31, 0), loopy(0, 0, 0, 0),
+			loopy(0, 1, 0, 0), loopy(0, 0, 0, 0), loopy(0, 0, 0, 2),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ppu := newTestPpu()
+			ppu.mask_register = PPU_MASK_RENDER_BG
+			ppu.vram_addr = test.v
+			ppu.tmp_addr = test.t
+
+			ppu.seek(testScrollLine, 0)
+
+			ppu.runTo(testScrollLine, 257)
+
+			if ppu.vram_addr != test.dot256 {
+				t.Errorf("dot 256: got %04x, want %04x", ppu.vram_addr, test.dot256)
+			}
+
+			ppu.runTo(testScrollLine, 258)
+
+			if ppu.vram_addr != test.dot257 {
+				t.Errorf("dot 257: got %04x, want %04x", ppu.vram_addr, test.dot257)
+			}
+
+			ppu.runTo(testScrollLine+1, 0)
+
+			if ppu.vram_addr != test.end {
+				t.Errorf("end of line: got %04x, want %04x", ppu.vram_addr, test.end)
+			}
+		})
+	}
+}
+
+/*
+ * The pre-render line copies the vertical bits of t over for all of dots
+ * 280-304, so a change to t in the middle of that still makes it. Visible
+ * lines leave them alone
+ */
+func TestScrollVerticalCopy(t *testing.T) {
+	ppu := newTestPpu()
+	ppu.mask_register = PPU_MASK_RENDER_BG
+	ppu.vram_addr = loopy(3, 3, 12, 4)
+	ppu.tmp_addr = loopy(5, 2, 20, 10)
+
+	ppu.seek(ppu.prerender_line, 0)
+	ppu.runTo(ppu.prerender_line, 280)
+
+	if want := loopy(4, 2, 12, 10); ppu.vram_addr != want {
+		t.Errorf("dot 279: got %04x, want %04x", ppu.vram_addr, want)
+	}
+
+	ppu.runTo(ppu.prerender_line, 281)
+
+	if want := loopy(5, 2, 20, 10); ppu.vram_addr != want {
+		t.Errorf("dot 280: got %04x, want %04x", ppu.vram_addr, want)
+	}
+
+	ppu.runTo(ppu.prerender_line, 300)
+	ppu.tmp_addr = loopy(1, 0, 7, 10)
+	ppu.runTo(ppu.prerender_line, 305)
+
+	if want := loopy(1, 0, 7, 10); ppu.vram_addr != want {
+		t.Errorf("dot 304: got %04x, want %04x", ppu.vram_addr, want)
+	}
+
+	// Too late now
+	ppu.tmp_addr = loopy(6, 2, 1, 10)
+	ppu.runTo(ppu.prerender_line, 320)
+
+	if want := loopy(1, 0, 7, 10); ppu.vram_addr != want {
+		t.Errorf("dot 319: got %04x, want %04x", ppu.vram_addr, want)
+	}
+
+	ppu.seek(testScrollLine, 0)
+	ppu.vram_addr = loopy(3, 3, 12, 4)
+	ppu.tmp_addr = loopy(5, 2, 20, 10)
+	ppu.runTo(testScrollLine, 305)
+
+	if want := loopy(4, 2, 12, 10); ppu.vram_addr != want {
+		t.Errorf("visible line: got %04x, want %04x", ppu.vram_addr, want)
+	}
+}
+
+/*
+ * Nothing moves v while rendering is off
+ */
+func TestScrollRenderingOff(t *testing.T) {
+	ppu := newTestPpu()
+	ppu.vram_addr = loopy(3, 1, 12, 4)
+	ppu.tmp_addr = loopy(5, 2, 20, 10)
+
+	ppu.seek(ppu.prerender_line, 0)
+	ppu.runTo(1, 0)
+
+	if want := loopy(3, 1, 12, 4); ppu.vram_addr != want {
+		t.Errorf("got %04x, want %04x", ppu.vram_addr, want)
+	}
+}
@@ -1,5 +1,152 @@
 package ppu
 
+import (
+	"errors"
+
+	"github.com/beakeyz/gones-emu/pkg/video"
+)
+
+/*
+ * Palettes
+ *
+ * There's two of them. Palette RAM is the 32 bytes at $3F00 that games
+ * write their colors into, 4 background and 4 sprite palettes of 4 colors
+ * each. Those colors are indices into the system palette, which is the 64
+ * colors the PPU can actually put out. The real PPU generates a video
+ * signal, not RGB, so the system palette is just our best guess at what
+ * that looks like on a TV.
+ *
+ * See: https://www.nesdev.org/wiki/PPU_palettes
+ */
+
+const (
+	PALETTE_COLORS = 64
+	/* Every combination of the 3 emphasis bits gets its own set of colors */
+	PALETTE_EMPHASIS_SETS = 8
+	PALETTE_ENTRIES       = PALETTE_COLORS * PALETTE_EMPHASIS_SETS
+
+	/* How much emphasis darkens the channels that aren't emphasized */
+	PALETTE_EMPHASIS_ATTENUATION = 0.816
+)
+
+/*
+ * The system palette, with (emphasis << 6) | color as the index
+ */
+type Palette [PALETTE_ENTRIES]video.Color
+
+/*
+ * The 2C02 palette, as 64 RGB triplets
+ */
+var ntscColors = [PALETTE_COLORS * 3]byte{
+	0x80, 0x80, 0x80, 0x00, 0x3d, 0xa6, 0x00, 0x12, 0xb0, 0x44, 0x00, 0x96,
+	0xa1, 0x00, 0x5e, 0xc7, 0x00, 0x28, 0xba, 0x06, 0x00, 0x8c, 0x17, 0x00,
+	0x5c, 0x2f, 0x00, 0x10, 0x45, 0x00, 0x05, 0x4a, 0x00, 0x00, 0x47, 0x2e,
+	0x00, 0x41, 0x66, 0x00, 0x00, 0x00, 0x05, 0x05, 0x05, 0x05, 0x05, 0x05,
+
+	0xc7, 0xc7, 0xc7, 0x00, 0x77, 0xff, 0x21, 0x55, 0xff, 0x82, 0x37, 0xfa,
+	0xeb, 0x2f, 0xb5, 0xff, 0x29, 0x50, 0xff, 0x22, 0x00, 0xd6, 0x32, 0x00,
+	0xc4, 0x62, 0x00, 0x35, 0x80, 0x00, 0x05, 0x8f, 0x00, 0x00, 0x8a, 0x55,
+	0x00, 0x99, 0xcc, 0x21, 0x21, 0x21, 0x09, 0x09, 0x09, 0x09, 0x09, 0x09,
+
+	0xff, 0xff, 0xff, 0x0f, 0xd7, 0xff, 0x69, 0xa2, 0xff, 0xd4, 0x80, 0xff,
+	0xff, 0x45, 0xf3, 0xff, 0x61, 0x8b, 0xff, 0x88, 0x33, 0xff, 0x9c, 0x12,
+	0xfa, 0xbc, 0x20, 0x9f, 0xe3, 0x0e, 0x2b, 0xf0, 0x35, 0x0c, 0xf0, 0xa4,
+	0x05, 0xfb, 0xff, 0x5e, 0x5e, 0x5e, 0x0d, 0x0d, 0x0d, 0x0d, 0x0d, 0x0d,
+
+	0xff, 0xff, 0xff, 0xa6, 0xfc, 0xff, 0xb3, 0xec, 0xff, 0xda, 0xab, 0xeb,
+	0xff, 0xa8, 0xf9, 0xff, 0xab, 0xb3, 0xff, 0xd2, 0xb0, 0xff, 0xef, 0xa6,
+	0xff, 0xf7, 0x9c, 0xd7, 0xe8, 0x95, 0xa6, 0xed, 0xaf, 0xa2, 0xf2, 0xda,
+	0x99, 0xff, 0xfc, 0xdd, 0xdd, 0xdd, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11,
+}
+
+/*
+ * Build a system palette out of 64 RGB triplets. The emphasis sets don't
+ * come with it, so we make those up by darkening the other channels
+ */
+func newPalette(rgb []byte) *Palette {
+	var palette Palette
+
+	for emphasis := range PALETTE_EMPHASIS_SETS {
+		for i := range PALETTE_COLORS {
+			channels := [3]float64{
+				float64(rgb[i*3]),
+				float64(rgb[i*3+1]),
+				float64(rgb[i*3+2]),
+			}
+
+			// Emphasis bits are red, green, blue (from low to high)
+			for c := range channels {
+				for e := range 3 {
+					if e != c && (emphasis&(1<<e)) != 0 {
+						channels[c] *= PALETTE_EMPHASIS_ATTENUATION
+					}
+				}
+			}
+
+			palette[emphasis*PALETTE_COLORS+i] = video.NewColor(
+				uint8(channels[0]),
+				uint8(channels[1]),
+				uint8(channels[2]),
+				0xff)
+		}
+	}
+
+	return &palette
+}
+
+func DefaultPalette() *Palette {
+	return newPalette(ntscColors[:])
+}
+
+/*
+ * Turn a palette address into an offset in palette RAM. The backdrop
+ * entries of the sprite palettes ($3F10/$3F14/$3F18/$3F1C) are the same
+ * bytes as the ones of the background palettes
+ */
 func VramAddrToPalette(vaddr uint16) uint16 {
-	return 0
+	offset := vaddr & (PPU_PALETTE_SZ - 1)
+
+	if (offset & 0x13) == 0x10 {
+		offset &= ^uint16(0x10)
+	}
+
+	return offset
+}
+
+/*
+ * The 32 bytes of palette RAM, mirrored all the way through $3FFF
+ */
+type PaletteRam struct {
+	memory [PPU_PALETTE_SZ]byte
+}
+
+func NewPaletteRam() *PaletteRam {
+	return &PaletteRam{}
+}
+
+func (pram *PaletteRam) Read(addr uint16, value *uint8) error {
+	if addr < PPU_PALETTE_BASE || addr >= PPU_PALETTE_END {
+		return errors.New("palette: read out of range!")
+	}
+
+	*value = pram.memory[VramAddrToPalette(addr)]
+	return nil
+}
+
+func (pram *PaletteRam) Write(addr uint16, value uint8) error {
+	if addr < PPU_PALETTE_BASE || addr >= PPU_PALETTE_END {
+		return errors.New("palette: write out of range!")
+	}
+
+	// Palette RAM is only 6 bits wide
+	pram.memory[VramAddrToPalette(addr)] = value & 0x3f
+	return nil
+}
+
+func (pram *PaletteRam) StartAddr() uint16 {
+	return PPU_PALETTE_BASE
+}
+
+func (pram *PaletteRam) EndAddr() uint16 {
+	return PPU_PALETTE_END - 1
 }
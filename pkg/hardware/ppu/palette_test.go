@@ -0,0 +1,136 @@
+package ppu
+
+import (
+	"testing"
+)
+
+func TestVramAddrToPalette(t *testing.T) {
+	tests := []struct {
+		addr uint16
+		want uint16
+	}{
+		{0x3f00, 0x00},
+		{0x3f01, 0x01},
+		{0x3f04, 0x04},
+		{0x3f0f, 0x0f},
+		// Sprite backdrops are the background ones
+		{0x3f10, 0x00},
+		{0x3f14, 0x04},
+		{0x3f18, 0x08},
+		{0x3f1c, 0x0c},
+		// But the rest of the sprite palettes aren't
+		{0x3f11, 0x11},
+		{0x3f13, 0x13},
+		{0x3f1f, 0x1f},
+		// Mirrored up to $3FFF
+		{0x3f20, 0x00},
+		{0x3f30, 0x00},
+		{0x3f3c, 0x0c},
+		{0x3ff5, 0x15},
+	}
+
+	for _, test := range tests {
+		if got := VramAddrToPalette(test.addr); got != test.want {
+			t.Errorf("$%04x: got %02x, want %02x", test.addr, got, test.want)
+		}
+	}
+}
+
+func TestPaletteRam(t *testing.T) {
+	ppu := newTestPpu()
+
+	for i, addr := range []uint16{0x3f10, 0x3f14, 0x3f18, 0x3f1c} {
+		ppu.writeBus(t, addr, uint8(0x20+i))
+
+		if got := ppu.readBus(t, addr&^0x10); got != uint8(0x20+i) {
+			t.Errorf("$%04x: got %02x, want %02x", addr&^0x10, got, 0x20+i)
+		}
+	}
+
+	// Only 6 bits wide
+	ppu.writeBus(t, 0x3f05, 0xff)
+
+	if got := ppu.readBus(t, 0x3f05); got != 0x3f {
+		t.Errorf("$3F05: got %02x, want 3f", got)
+	}
+}
+
+/*
+ * What ends up in the frame for the backdrop color under every mix of
+ * grayscale and emphasis
+ */
+func TestEmphasisGrayscale(t *testing.T) {
+	tests := []struct {
+		name string
+		mask uint8
+		want uint16
+	}{
+		{"plain", 0, 0x16},
+		{"grayscale", PPU_MASK_GRAYSCALE, 0x10},
+		{"red", 0x20, 1*PALETTE_COLORS + 0x16},
+		{"green", 0x40, 2*PALETTE_COLORS + 0x16},
+		{"blue", 0x80, 4*PALETTE_COLORS + 0x16},
+		{"all", PPU_MASK_CLR_INTENSITY_MASK, 7*PALETTE_COLORS + 0x16},
+		{"all grayscale", PPU_MASK_CLR_INTENSITY_MASK | PPU_MASK_GRAYSCALE, 7*PALETTE_COLORS + 0x10},
+	}
+
+	for _, test := range tests {
+		ppu := newTestPpu()
+		ppu.writeBus(t, 0x3f00, 0x16)
+		ppu.mask_register = test.mask
+
+		ppu.seek(10, 0)
+		ppu.runTo(11, 0)
+
+		if got := ppu.frame.Index(100, 10); got != test.want {
+			t.Errorf("%s: got %03x, want %03x", test.name, got, test.want)
+		}
+
+		if got := ppu.frame.Pixel(100, 10); got != ppu.palette[test.want] {
+			t.Errorf("%s: got color %v, want %v", test.name, got, ppu.palette[test.want])
+		}
+	}
+}
+
+/*
+ * Emphasis darkens the channels that aren't emphasized and leaves the
+ * emphasized one alone
+ */
+func TestEmphasisPalette(t *testing.T) {
+	palette := DefaultPalette()
+
+	r, g, b, _ := palette[0x20].RGBA()
+
+	for emphasis, want := range map[int][3]bool{
+		1: {false, true, true},
+		2: {true, false, true},
+		4: {true, true, false},
+		7: {true, true, true},
+	} {
+		er, eg, eb, _ := palette[emphasis*PALETTE_COLORS+0x20].RGBA()
+
+		for c, pair := range [3][2]uint8{{r, er}, {g, eg}, {b, eb}} {
+			if darker := pair[1] < pair[0]; darker != want[c] {
+				t.Errorf("emphasis %d: channel %d went from %02x to %02x", emphasis, c, pair[0], pair[1])
+			}
+		}
+	}
+}
+
+/*
+ * With rendering off, pointing v into palette RAM shows that color
+ * instead of the backdrop
+ */
+func TestBackdropOverride(t *testing.T) {
+	ppu := newTestPpu()
+	ppu.writeBus(t, 0x3f00, 0x16)
+	ppu.writeBus(t, 0x3f07, 0x2a)
+	ppu.vram_addr = 0x3f07
+
+	ppu.seek(10, 0)
+	ppu.runTo(11, 0)
+
+	if got := ppu.frame.Index(100, 10); got != 0x2a {
+		t.Errorf("got %02x, want 2a", got)
+	}
+}
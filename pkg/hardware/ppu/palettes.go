@@ -0,0 +1,212 @@
+package ppu
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/beakeyz/gones-emu/pkg/video"
+)
+
+/*
+ * Swappable system palettes
+ *
+ * Palette files (.pal) are just raw RGB triplets. A 192 byte file has the
+ * 64 regular colors, a 1536 byte file has all 8 emphasis sets after each
+ * other, in the same order as the emphasis bits in PPUMASK.
+ */
+
+const (
+	PALETTE_FILE_SZ          = PALETTE_COLORS * 3
+	PALETTE_FILE_EMPHASIS_SZ = PALETTE_ENTRIES * 3
+
+	PALETTE_DEFAULT = "2c02"
+)
+
+/*
+ * Every palette we ship with, by name
+ */
+var builtinPalettes = map[string]func() *Palette{
+	PALETTE_DEFAULT: DefaultPalette,
+	"composite":     func() *Palette { return compositePalette(false) },
+	"grayscale":     func() *Palette { return compositePalette(true) },
+}
+
+func PaletteNames() []string {
+	names := make([]string, 0, len(builtinPalettes))
+
+	for name := range builtinPalettes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func BuiltinPalette(name string) (*Palette, error) {
+	gen, ok := builtinPalettes[name]
+
+	if !ok {
+		return nil, fmt.Errorf("ppu: no built-in palette called '%s'", name)
+	}
+
+	return gen(), nil
+}
+
+/*
+ * Make a palette out of the contents of a .pal file
+ */
+func ParsePalette(data []byte) (*Palette, error) {
+	switch len(data) {
+	case PALETTE_FILE_SZ:
+		return newPalette(data), nil
+	case PALETTE_FILE_EMPHASIS_SZ:
+		var palette Palette
+
+		for i := range palette {
+			palette[i] = video.NewColor(data[i*3], data[i*3+1], data[i*3+2], 0xff)
+		}
+
+		return &palette, nil
+	}
+
+	return nil, fmt.Errorf("ppu: palette should be %d or %d bytes, not %d",
+		PALETTE_FILE_SZ, PALETTE_FILE_EMPHASIS_SZ, len(data))
+}
+
+func LoadPaletteFile(path string) (*Palette, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParsePalette(data)
+}
+
+/*
+ * Find a palette by name, or treat it as a path to a .pal file if it
+ * isn't one of ours
+ */
+func FindPalette(name string) (*Palette, error) {
+	if _, ok := builtinPalettes[name]; ok {
+		return BuiltinPalette(name)
+	}
+
+	return LoadPaletteFile(name)
+}
+
+/*
+ * Swap out the system palette. Takes effect on the next pixel
+ */
+func (ppu *PPU) SetPalette(palette *Palette) {
+	if palette == nil {
+		return
+	}
+
+	ppu.palette = palette
+}
+
+/*
+ * Work out the palette from the NTSC signal the PPU generates, instead of
+ * using measured colors. Every color is a square wave between two voltage
+ * levels, and its phase picks the hue. We sample one color cycle and
+ * decode it to YIQ, like a TV would.
+ *
+ * See: https://www.nesdev.org/wiki/NTSC_video
+ */
+var ntscLevels = [8]float64{
+	// Low levels of the square wave
+	0.350, 0.518, 0.962, 1.550,
+	// High levels
+	1.094, 1.506, 1.962, 1.962,
+}
+
+const (
+	NTSC_BLACK       = 0.518
+	NTSC_WHITE       = 1.962
+	NTSC_ATTENUATION = 0.746
+	/* Tint and color knobs, set to what a TV at its defaults shows */
+	NTSC_HUE_OFFSET = 3.9
+	NTSC_SATURATION = 2.0
+)
+
+func ntscInPhase(color int, phase int) bool {
+	return (color+phase)%12 < 6
+}
+
+func ntscSignal(index int, emphasis int, phase int) float64 {
+	color := index & 0x0f
+	level := (index >> 4) & 0x03
+
+	// $xE and $xF are black
+	if color > 13 {
+		level = 1
+	}
+
+	low := ntscLevels[level]
+	high := ntscLevels[4+level]
+
+	// Column 0 is all high, columns $D and up are all low
+	if color == 0 {
+		low = high
+	} else if color > 12 {
+		high = low
+	}
+
+	signal := low
+
+	if ntscInPhase(color, phase) {
+		signal = high
+	}
+
+	// Emphasis drags the signal down during parts of the color cycle
+	if color < 14 &&
+		(((emphasis&1) != 0 && ntscInPhase(0, phase)) ||
+			((emphasis&2) != 0 && ntscInPhase(4, phase)) ||
+			((emphasis&4) != 0 && ntscInPhase(8, phase))) {
+		signal *= NTSC_ATTENUATION
+	}
+
+	return (signal - NTSC_BLACK) / (NTSC_WHITE - NTSC_BLACK)
+}
+
+func ntscClamp(v float64) uint8 {
+	v = math.Round(v * 255)
+
+	return uint8(max(0, min(255, v)))
+}
+
+func compositePalette(gray bool) *Palette {
+	var palette Palette
+
+	for emphasis := range PALETTE_EMPHASIS_SETS {
+		for index := range PALETTE_COLORS {
+			var y, i, q float64
+
+			for phase := range 12 {
+				v := ntscSignal(index, emphasis, phase) / 12
+				angle := math.Pi * (float64(phase) + NTSC_HUE_OFFSET) / 6
+
+				y += v
+				i += v * NTSC_SATURATION * math.Cos(angle)
+				q += v * NTSC_SATURATION * math.Sin(angle)
+			}
+
+			if gray {
+				i = 0
+				q = 0
+			}
+
+			palette[emphasis*PALETTE_COLORS+index] = video.NewColor(
+				ntscClamp(y+0.946882*i+0.623557*q),
+				ntscClamp(y-0.274788*i-0.635691*q),
+				ntscClamp(y-1.108545*i+1.709007*q),
+				0xff)
+		}
+	}
+
+	return &palette
+}
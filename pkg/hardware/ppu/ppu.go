@@ -3,14 +3,22 @@ package ppu
 import (
 	"github.com/beakeyz/gones-emu/pkg/debug"
 	"github.com/beakeyz/gones-emu/pkg/hardware/bus"
+	"github.com/beakeyz/gones-emu/pkg/hardware/cpu"
+	"github.com/beakeyz/gones-emu/pkg/hardware/region"
 	"github.com/beakeyz/gones-emu/pkg/video"
 )
 
 type PPU struct {
 	/* Bus for the PPU stuff (Pattern, Nametable, Pallets) */
 	PpuBus *bus.SystemBus
+	/* Nametable RAM, the cartridge controls its mirroring */
+	Vram *Vram
 	/* Videobackend used for actually drawing the PPU state to a screen */
-	backend *video.VideoBackend
+	backend video.VideoBackend
+	/* The screen we're drawing, goes to the backend when it's done */
+	frame *video.Frame
+	/* The CPU our NMI output is wired to */
+	cpu cpu.CPU
 	/* PPU registers */
 	ctl_register    byte
 	mask_register   byte
@@ -20,12 +28,52 @@ type PPU struct {
 	start_addr uint16
 	/* End address on the main system bus */
 	end_addr uint16
-	/* The current 'pixel' we're working on. This includes hblank and vblank */
-	pixel_clock uint32
-	pixel_x     int32
-	pixel_y     int32
-	/* Nes pallet array */
-	nesPallet []video.Color
+	/* The current dot (0-340) and scanline (0-261) we're working on. This includes hblank and vblank */
+	pixel_x int32
+	pixel_y int32
+	/* Loopy scroll registers, see background.go */
+	vram_addr    uint16
+	tmp_addr     uint16
+	fine_x       uint8
+	write_toggle bool
+	/* Background tile latches and shift registers */
+	bg_next_tile     uint8
+	bg_next_attr     uint8
+	bg_next_lo       uint8
+	bg_next_hi       uint8
+	bg_shift_lo      uint16
+	bg_shift_hi      uint16
+	bg_shift_attr_lo uint16
+	bg_shift_attr_hi uint16
+	/* $2007 read buffer */
+	read_buffer uint8
+	/* Open bus latch, and the frame every bit was last driven on */
+	io_bus         uint8
+	io_bus_refresh [8]uint64
+	/* Sprite memory, see sprites.go */
+	oam           [PPU_OAM_SZ]uint8
+	oam_addr      uint8
+	secondary_oam [PPU_SECONDARY_OAM_SZ]uint8
+	sprite_count  int
+	/* Sprites we're drawing on the current line */
+	sprites_in_line   int
+	sprite_lo         [PPU_SPRITES_PER_LINE]uint8
+	sprite_hi         [PPU_SPRITES_PER_LINE]uint8
+	sprite_attr       [PPU_SPRITES_PER_LINE]uint8
+	sprite_x          [PPU_SPRITES_PER_LINE]uint8
+	sprite0_next_line bool
+	sprite0_in_line   bool
+	/* Frame layout for our region. The last line is the pre-render line */
+	scanlines      int32
+	vblank_line    int32
+	prerender_line int32
+	odd_frame_skip bool
+	/* Amount of frames we've finished */
+	frames uint64
+	/* Palette RAM at $3F00 */
+	pram *PaletteRam
+	/* The system palette, what the color indices in palette RAM look like */
+	palette *Palette
 }
 
 const (
@@ -49,6 +97,7 @@ const (
 
 	/* PPU MASK bit fields */
 	PPU_MASK_DISPLAY_TYPE       = 0x01
+	PPU_MASK_GRAYSCALE          = PPU_MASK_DISPLAY_TYPE
 	PPU_MASK_BG_SHOW_LEFT8      = 0x02
 	PPU_MASK_SPRITES_SHOW_LEFT8 = 0x04
 	PPU_MASK_RENDER_BG          = 0x08
@@ -86,166 +135,239 @@ const (
 	PPU_CYCLES_PER_SCANLINE = 341
 	/* Don't worry about where I got this from, just trust me v2 */
 	PPU_CYCLES_PER_SCREEN = 89342
+
+	PPU_VISIBLE_LINES = 240
+
+	/* Open bus bits fade out after roughly 600ms */
+	PPU_OPEN_BUS_DECAY_FRAMES = 36
 )
 
-func New(backend *video.VideoBackend) *PPU {
+func New(backend video.VideoBackend, c cpu.CPU) *PPU {
 	_bus, err := bus.NewSystembus()
 
 	if err != nil {
 		return nil
 	}
 
-	pallet := make([]video.Color, 1)
-
-	// Yuck, pallet generation
-	pallet = append(pallet, video.NewColor(0x80, 0x80, 0x80, 0xff))
-	pallet = append(pallet, video.NewColor(0x00, 0x3D, 0xA6, 0xff))
-	pallet = append(pallet, video.NewColor(0x00, 0x12, 0xB0, 0xff))
-	pallet = append(pallet, video.NewColor(0x44, 0x00, 0x96, 0xff))
-	pallet = append(pallet, video.NewColor(0x1, 0x00, 0x5E, 0xff))
-	pallet = append(pallet, video.NewColor(0xC7, 0x00, 0x28, 0xff))
-	pallet = append(pallet, video.NewColor(0xBA, 0x06, 0x00, 0xff))
-	pallet = append(pallet, video.NewColor(0x8C, 0x17, 0x00, 0xff))
-	pallet = append(pallet, video.NewColor(0xC, 0x2F, 0x00, 0xff))
-	pallet = append(pallet, video.NewColor(0x10, 0x45, 0x00, 0xff))
-	pallet = append(pallet, video.NewColor(0x05, 0x4A, 0x00, 0xff))
-	pallet = append(pallet, video.NewColor(0x00, 0x47, 0x2E, 0xff))
-	pallet = append(pallet, video.NewColor(0x0, 0x41, 0x66, 0xff))
-	pallet = append(pallet, video.NewColor(0x00, 0x00, 0x00, 0xff))
-	pallet = append(pallet, video.NewColor(0x05, 0x05, 0x05, 0xff))
-	pallet = append(pallet, video.NewColor(0x05, 0x05, 0x05, 0xff))
-	pallet = append(pallet, video.NewColor(0x7, 0xC7, 0xC7, 0xff))
-	pallet = append(pallet, video.NewColor(0x00, 0x77, 0xFF, 0xff))
-	pallet = append(pallet, video.NewColor(0x21, 0x55, 0xFF, 0xff))
-	pallet = append(pallet, video.NewColor(0x82, 0x37, 0xFA, 0xff))
-	pallet = append(pallet, video.NewColor(0xB, 0x2F, 0xB5, 0xff))
-	pallet = append(pallet, video.NewColor(0xFF, 0x29, 0x50, 0xff))
-	pallet = append(pallet, video.NewColor(0xFF, 0x22, 0x00, 0xff))
-	pallet = append(pallet, video.NewColor(0xD6, 0x32, 0x00, 0xff))
-	pallet = append(pallet, video.NewColor(0x4, 0x62, 0x00, 0xff))
-	pallet = append(pallet, video.NewColor(0x35, 0x80, 0x00, 0xff))
-	pallet = append(pallet, video.NewColor(0x05, 0x8F, 0x00, 0xff))
-	pallet = append(pallet, video.NewColor(0x00, 0x8A, 0x55, 0xff))
-	pallet = append(pallet, video.NewColor(0x0, 0x99, 0xCC, 0xff))
-	pallet = append(pallet, video.NewColor(0x21, 0x21, 0x21, 0xff))
-	pallet = append(pallet, video.NewColor(0x09, 0x09, 0x09, 0xff))
-	pallet = append(pallet, video.NewColor(0x09, 0x09, 0x09, 0xff))
-	pallet = append(pallet, video.NewColor(0xF, 0xFF, 0xFF, 0xff))
-	pallet = append(pallet, video.NewColor(0x0F, 0xD7, 0xFF, 0xff))
-	pallet = append(pallet, video.NewColor(0x69, 0xA2, 0xFF, 0xff))
-	pallet = append(pallet, video.NewColor(0xD4, 0x80, 0xFF, 0xff))
-	pallet = append(pallet, video.NewColor(0xF, 0x45, 0xF3, 0xff))
-	pallet = append(pallet, video.NewColor(0xFF, 0x61, 0x8B, 0xff))
-	pallet = append(pallet, video.NewColor(0xFF, 0x88, 0x33, 0xff))
-	pallet = append(pallet, video.NewColor(0xFF, 0x9C, 0x12, 0xff))
-	pallet = append(pallet, video.NewColor(0xA, 0xBC, 0x20, 0xff))
-	pallet = append(pallet, video.NewColor(0x9F, 0xE3, 0x0E, 0xff))
-	pallet = append(pallet, video.NewColor(0x2B, 0xF0, 0x35, 0xff))
-	pallet = append(pallet, video.NewColor(0x0C, 0xF0, 0xA4, 0xff))
-	pallet = append(pallet, video.NewColor(0x5, 0xFB, 0xFF, 0xff))
-	pallet = append(pallet, video.NewColor(0x5E, 0x5E, 0x5E, 0xff))
-	pallet = append(pallet, video.NewColor(0x0D, 0x0D, 0x0D, 0xff))
-	pallet = append(pallet, video.NewColor(0x0D, 0x0D, 0x0D, 0xff))
-	pallet = append(pallet, video.NewColor(0xF, 0xFF, 0xFF, 0xff))
-	pallet = append(pallet, video.NewColor(0xA6, 0xFC, 0xFF, 0xff))
-	pallet = append(pallet, video.NewColor(0xB3, 0xEC, 0xFF, 0xff))
-	pallet = append(pallet, video.NewColor(0xDA, 0xAB, 0xEB, 0xff))
-	pallet = append(pallet, video.NewColor(0xF, 0xA8, 0xF9, 0xff))
-	pallet = append(pallet, video.NewColor(0xFF, 0xAB, 0xB3, 0xff))
-	pallet = append(pallet, video.NewColor(0xFF, 0xD2, 0xB0, 0xff))
-	pallet = append(pallet, video.NewColor(0xFF, 0xEF, 0xA6, 0xff))
-	pallet = append(pallet, video.NewColor(0xF, 0xF7, 0x9C, 0xff))
-	pallet = append(pallet, video.NewColor(0xD7, 0xE8, 0x95, 0xff))
-	pallet = append(pallet, video.NewColor(0xA6, 0xED, 0xAF, 0xff))
-	pallet = append(pallet, video.NewColor(0xA2, 0xF2, 0xDA, 0xff))
-	pallet = append(pallet, video.NewColor(0x9, 0xFF, 0xFC, 0xff))
-	pallet = append(pallet, video.NewColor(0xDD, 0xDD, 0xDD, 0xff))
-	pallet = append(pallet, video.NewColor(0x11, 0x11, 0x11, 0xff))
-	pallet = append(pallet, video.NewColor(0x11, 0x11, 0x11, 0xff))
-
-	return &PPU{
+	vram := NewVram(MIRROR_HORIZONTAL)
+
+	// CHR comes from the cartridge, the nametables are ours
+	_bus.AddComponent(vram)
+
+	pram := NewPaletteRam()
+
+	_bus.AddComponent(pram)
+
+	ret := &PPU{
 		PpuBus:          _bus,
+		Vram:            vram,
 		backend:         backend,
+		frame:           video.NewFrame(),
+		cpu:             c,
 		ctl_register:    0,
 		mask_register:   0,
 		status_register: 0,
-		/* This is register space (TODO: The other spaces (nametables, chr rom, pallet ram)) */
-		start_addr:  0x2000,
-		end_addr:    0x2007,
-		pixel_clock: 0,
-		pixel_x:     0,
-		pixel_y:     0,
-		nesPallet:   pallet,
+		/* Register space. The 8 registers repeat all the way up to $3FFF */
+		start_addr: 0x2000,
+		end_addr:   0x3fff,
+		pixel_x:    0,
+		pixel_y:    0,
+		pram:       pram,
+		palette:    DefaultPalette(),
+	}
+
+	// NTSC until someone tells us otherwise
+	ret.SetRegion(region.REGION_NTSC)
+
+	return ret
+}
+
+/*
+ * Switch the frame layout over to @r. Best done before the first frame
+ */
+func (ppu *PPU) SetRegion(r region.Region) {
+	timing := r.Timing()
+
+	ppu.scanlines = timing.Scanlines
+	ppu.vblank_line = timing.VblankLine
+	ppu.prerender_line = timing.Scanlines - 1
+	ppu.odd_frame_skip = timing.OddFrameSkip
+
+	if ppu.pixel_y >= ppu.scanlines {
+		ppu.pixel_y = 0
 	}
 }
 
 func (ppu *PPU) EnteredVBlank() bool {
-	return (ppu.pixel_y == 241 && ppu.pixel_x == 1)
+	return (ppu.pixel_y == ppu.vblank_line && ppu.pixel_x == 1)
+}
+
+func (ppu *PPU) LeftVBlank() bool {
+	return (ppu.pixel_y == ppu.prerender_line && ppu.pixel_x == 1)
 }
 
 func (ppu *PPU) IsVBlank() bool {
-	return (ppu.pixel_y >= 240 && ppu.pixel_y != 261)
+	return (ppu.pixel_y >= PPU_VISIBLE_LINES && ppu.pixel_y != ppu.prerender_line)
 }
 
 func (ppu *PPU) IsHBlank() bool {
 	return (ppu.pixel_x >= 257 && ppu.pixel_x <= 320)
 }
 
-func (ppu *PPU) SelectPxColor() video.Color {
-	return ppu.nesPallet[(ppu.pixel_clock/PPU_CYCLES_PER_SCANLINE)%0x40]
+/*
+ * Figure out the color of the pixel under the beam and draw it
+ */
+func (ppu *PPU) drawPixel() {
+	pixel, palette := ppu.bgPixel()
+	spr_pixel, spr_palette, behind, zero := ppu.spritePixel()
+
+	/*
+	 * Sprite 0 hit happens wherever an opaque pixel of sprite 0 lands on
+	 * an opaque background pixel. Never on the last dot though
+	 */
+	if zero && pixel != 0 && ppu.pixel_x != 256 {
+		ppu.SetStatusBits(PPU_STATUS_HIT_SPRITE0)
+	}
+
+	if spr_pixel != 0 && (pixel == 0 || !behind) {
+		pixel = spr_pixel
+		palette = spr_palette
+	}
+
+	// Transparent pixels show the backdrop color at $3F00
+	if pixel == 0 {
+		palette = 0
+	}
+
+	addr := PPU_PALETTE_BASE + uint16(palette)<<2 + uint16(pixel)
+
+	/*
+	 * With rendering off the PPU shows the backdrop, unless v points into
+	 * palette RAM. Then it shows that color instead
+	 */
+	if !ppu.renderingEnabled() && (ppu.vram_addr&0x3f00) == 0x3f00 {
+		addr = ppu.vram_addr
+	}
+
+	index := ppu.outputIndex(ppu.readPalette(addr))
+
+	ppu.frame.SetPixel(ppu.pixel_x-1, ppu.pixel_y, index, ppu.palette[index])
 }
 
-/*!
- * Called when the ppu does a 'cycle'
+/*
+ * Read a color index out of palette RAM. Grayscale mode just drops the
+ * low bits, so everything ends up in the gray column
  */
-func (ppu *PPU) Execute(ticks int) error {
+func (ppu *PPU) readPalette(addr uint16) uint8 {
+	var index uint8
 
-	/* We can execute multiple PPU ticks in a single Execute call, in order to obtain ez syncing */
-	for range ticks {
-		/* Set the current pixel coordinates */
-		ppu.pixel_x = int32(ppu.pixel_clock % PPU_CYCLES_PER_SCANLINE)
-		ppu.pixel_y = int32(ppu.pixel_clock / PPU_CYCLES_PER_SCANLINE)
+	ppu.pram.Read(PPU_PALETTE_BASE|VramAddrToPalette(addr), &index)
 
-		// If we're inside the visible vertical region
-		if !ppu.IsVBlank() {
-
-			if ppu.IsHBlank() {
+	if (ppu.mask_register & PPU_MASK_GRAYSCALE) != 0 {
+		index &= 0x30
+	}
 
-			} else {
-				color := ppu.SelectPxColor()
+	return index
+}
 
-				ppu.backend.DrawNESPixel(
-					ppu.pixel_x,
-					ppu.pixel_y,
-					color)
+/*
+ * Where a color index ends up in the system palette, with the emphasis
+ * bits from PPUMASK picking the set
+ */
+func (ppu *PPU) outputIndex(index uint8) uint16 {
+	emphasis := uint16(ppu.mask_register&PPU_MASK_CLR_INTENSITY_MASK) >> 5
 
-			}
+	return emphasis*PALETTE_COLORS + uint16(index&0x3f)
+}
 
-		} else { // Post-render and VBlank
-			if ppu.EnteredVBlank() {
-				// Set vblank flag
-				ppu.SetStatusBits(PPU_STATUS_IN_VBLANK)
+/*
+ * Move the beam a single dot
+ */
+func (ppu *PPU) tick() {
+	if ppu.pixel_y < PPU_VISIBLE_LINES || ppu.pixel_y == ppu.prerender_line {
+		if ppu.renderingEnabled() {
+			ppu.renderBackground()
+			ppu.renderSprites()
+		}
 
-				// TODO: Signal CPU NMI if that is set in the PPU control word
-			}
+		if ppu.pixel_y < PPU_VISIBLE_LINES && ppu.pixel_x >= 1 && ppu.pixel_x <= 256 {
+			ppu.drawPixel()
 		}
+	}
 
-		/* We sadly can't do fancy AND stuff, since PPU_CYCLES_PER_SCREEN isn't a power of 2 =( */
-		ppu.pixel_clock++
+	if ppu.EnteredVBlank() {
+		// Set vblank flag
+		ppu.SetStatusBits(PPU_STATUS_IN_VBLANK)
+		ppu.updateNmi()
+	}
 
-		/* Beam the screen */
-		if ppu.pixel_clock >= PPU_CYCLES_PER_SCREEN {
-			ppu.pixel_clock = 0
-			ppu.backend.Flush()
-		}
+	// The pre-render line clears all the per-frame flags again
+	if ppu.LeftVBlank() {
+		ppu.ClearStatusBits(PPU_STATUS_IN_VBLANK | PPU_STATUS_HIT_SPRITE0 | PPU_STATUS_SPRITE_OVERFLOW)
+		ppu.updateNmi()
+	}
+
+	ppu.pixel_x++
+
+	/*
+	 * Odd frames skip the last dot of the pre-render line when we're
+	 * rendering, which makes them 89341 dots long. NTSC only
+	 */
+	if ppu.odd_frame_skip && ppu.pixel_y == ppu.prerender_line && ppu.pixel_x == 340 &&
+		(ppu.frames&1) != 0 && ppu.renderingEnabled() {
+		ppu.pixel_x++
+	}
+
+	if ppu.pixel_x < PPU_CYCLES_PER_SCANLINE {
+		return
+	}
+
+	ppu.pixel_x = 0
+	ppu.pixel_y++
+
+	/* Beam the screen */
+	if ppu.pixel_y >= ppu.scanlines {
+		ppu.pixel_y = 0
+		ppu.frames++
+		ppu.backend.SubmitFrame(ppu.frame)
+	}
+}
+
+/*!
+ * Called when the ppu does a 'cycle'
+ */
+func (ppu *PPU) Execute(ticks int) error {
+
+	/* We can execute multiple PPU ticks in a single Execute call, in order to obtain ez syncing */
+	for range ticks {
+		ppu.tick()
 	}
 	return nil
 }
 
+func (ppu *PPU) GetFrameCount() uint64 {
+	return ppu.frames
+}
+
 func (ppu *PPU) PostFrame() {
 
 }
 
+/*
+ * The NMI output is just vblank AND'ed with the enable bit in PPUCTRL. The
+ * CPU only cares about it going active, so toggling the enable bit during
+ * vblank fires another NMI, just like on the real thing
+ */
+func (ppu *PPU) updateNmi() {
+	if ppu.cpu == nil {
+		return
+	}
+
+	ppu.cpu.SetNmiLine(
+		(ppu.status_register&PPU_STATUS_IN_VBLANK) != 0 &&
+			(ppu.ctl_register&PPU_CTL_NMI_ON_VBLANK) != 0)
+}
+
 func (ppu *PPU) ClearStatusBits(bits uint8) {
 	ppu.status_register &= ^bits
 }
@@ -254,35 +376,116 @@ func (ppu *PPU) SetStatusBits(bits uint8) {
 	ppu.status_register |= bits
 }
 
+/*
+ * The PPU's data bus to the CPU holds on to the last value that was on
+ * it, so reading write-only registers gives you that back. It isn't
+ * driven by anything though, so the bits slowly leak away to 0 if nobody
+ * refreshes them
+ */
+func (ppu *PPU) refreshIoBus(value uint8, mask uint8) {
+	ppu.io_bus = (ppu.io_bus & ^mask) | (value & mask)
+
+	for i := range 8 {
+		if (mask & (1 << i)) != 0 {
+			ppu.io_bus_refresh[i] = ppu.frames
+		}
+	}
+}
+
+func (ppu *PPU) readIoBus() uint8 {
+	for i := range 8 {
+		if ppu.frames-ppu.io_bus_refresh[i] >= PPU_OPEN_BUS_DECAY_FRAMES {
+			ppu.io_bus &= ^uint8(1 << i)
+		}
+	}
+
+	return ppu.io_bus
+}
+
+/*
+ * Move v along after a $2007 access. While rendering this goes through
+ * the scroll increment logic instead, and it messes up the scroll
+ */
+func (ppu *PPU) incrementVramAddr() {
+	if ppu.renderingEnabled() && (ppu.pixel_y < PPU_VISIBLE_LINES || ppu.pixel_y == ppu.prerender_line) {
+		ppu.incrementScrollX()
+		ppu.incrementScrollY()
+		return
+	}
+
+	if (ppu.ctl_register & PPU_CTL_ADDR_INC) != 0 {
+		ppu.vram_addr += 32
+	} else {
+		ppu.vram_addr++
+	}
+
+	ppu.vram_addr &= 0x7fff
+}
+
+/*
+ * $2007 reads lag behind by one, the CPU gets whatever the previous read
+ * left in the buffer. Palette reads are the exception, those come right
+ * back, but the buffer still gets filled with the nametable byte that
+ * sits 'under' the palette
+ */
+func (ppu *PPU) readData() uint8 {
+	addr := ppu.vram_addr & 0x3fff
+	value := ppu.read_buffer
+
+	if addr >= PPU_PALETTE_BASE {
+		value = ppu.readPalette(addr) | (ppu.readIoBus() & 0xc0)
+		ppu.read_buffer = ppu.fetch(addr & 0x2fff)
+		ppu.refreshIoBus(value, 0x3f)
+	} else {
+		ppu.read_buffer = ppu.fetch(addr)
+		ppu.refreshIoBus(value, 0xff)
+	}
+
+	ppu.incrementVramAddr()
+
+	return value
+}
+
+func (ppu *PPU) writeData(value uint8) {
+	ppu.ppuWrite(ppu.vram_addr&0x3fff, value)
+	ppu.incrementVramAddr()
+}
+
+/*
+ * Only the low 3 address bits get decoded, so the registers are mirrored
+ * every 8 bytes
+ */
+func ppuRegister(addr uint16) uint16 {
+	return PPU_CTL | (addr & 0x07)
+}
+
 /*
  * Handles CPU reads to the PPU
  */
 func (ppu *PPU) Read(addr uint16, value *uint8) error {
 	debug.Log("(PPU) Reading at 0x%x\n", addr)
 
-	//
-	switch addr {
-	case 0x2000:
-		*value = ppu.ctl_register
-		break
-	case 0x2001:
-		*value = ppu.mask_register
-		break
+	switch ppuRegister(addr) {
 	case 0x2002:
-		*value = ppu.status_register
+		// Only the top 3 bits are real, the rest is open bus
+		*value = (ppu.status_register & 0xe0) | (ppu.readIoBus() & 0x1f)
+		ppu.refreshIoBus(*value, 0xe0)
 
 		ppu.ClearStatusBits(PPU_STATUS_IN_VBLANK)
-		break
-	case 0x2003:
+		ppu.updateNmi()
+
+		// Reading the status also resets the $2005/$2006 write toggle
+		ppu.write_toggle = false
 	case 0x2004:
-	case 0x2005:
-	case 0x2006:
+		*value = ppu.readOam()
+		ppu.refreshIoBus(*value, 0xff)
 	case 0x2007:
-
-		break
+		*value = ppu.readData()
 	default:
-		break
+		// Write-only registers
+		*value = ppu.readIoBus()
 	}
+
 	return nil
 }
 
@@ -291,6 +494,49 @@ func (ppu *PPU) Read(addr uint16, value *uint8) error {
  */
 func (ppu *PPU) Write(addr uint16, value uint8) error {
 	debug.Log("(PPU) Writing at %x\n", addr)
+
+	// Any write fills the whole latch
+	ppu.refreshIoBus(value, 0xff)
+
+	switch ppuRegister(addr) {
+	case 0x2000:
+		ppu.ctl_register = value
+		ppu.updateNmi()
+
+		// The nametable select bits live in t
+		ppu.tmp_addr = (ppu.tmp_addr & ^uint16(LOOPY_NT_SELECT)) | (uint16(value&PPU_CTL_NTADDR_MASK) << 10)
+	case 0x2001:
+		ppu.mask_register = value
+	case 0x2003:
+		ppu.oam_addr = value
+	case 0x2004:
+		ppu.writeOam(value)
+	case 0x2005:
+		if !ppu.write_toggle {
+			// First write: coarse X and fine X
+			ppu.tmp_addr = (ppu.tmp_addr & ^uint16(LOOPY_COARSE_X)) | uint16(value>>3)
+			ppu.fine_x = value & 0x07
+		} else {
+			// Second write: coarse Y and fine Y
+			ppu.tmp_addr = (ppu.tmp_addr & ^uint16(LOOPY_COARSE_Y|LOOPY_FINE_Y)) |
+				(uint16(value&0x07) << 12) | (uint16(value&0xf8) << 2)
+		}
+
+		ppu.write_toggle = !ppu.write_toggle
+	case 0x2006:
+		if !ppu.write_toggle {
+			// High byte first. Bit 14 of t gets cleared here too
+			ppu.tmp_addr = (ppu.tmp_addr & 0x00ff) | (uint16(value&0x3f) << 8)
+		} else {
+			ppu.tmp_addr = (ppu.tmp_addr & 0xff00) | uint16(value)
+			ppu.vram_addr = ppu.tmp_addr
+		}
+
+		ppu.write_toggle = !ppu.write_toggle
+	case 0x2007:
+		ppu.writeData(value)
+	}
+
 	return nil
 }
 
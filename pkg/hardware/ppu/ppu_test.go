@@ -0,0 +1,250 @@
+package ppu
+
+import (
+	"testing"
+
+	"github.com/beakeyz/gones-emu/pkg/hardware/memory/ram"
+	"github.com/beakeyz/gones-emu/pkg/video"
+)
+
+/*
+ * A PPU on its own, with 8K of CHR RAM where the cartridge would go and
+ * no CPU to send NMIs to
+ */
+func newTestPpu() *PPU {
+	ppu := New(video.NewFramebuffer(), nil)
+
+	ppu.PpuBus.AddComponent(ram.New(PPU_CHR_ROM_BASE, PPU_CHR_ROM_END-1, PPU_CHR_ROM_SZ))
+
+	return ppu
+}
+
+/*
+ * Put the beam at @dot of @line, without running anything in between
+ */
+func (ppu *PPU) seek(line int32, dot int32) {
+	ppu.pixel_y = line
+	ppu.pixel_x = dot
+}
+
+/*
+ * Tick until the beam gets to @dot of @line
+ */
+func (ppu *PPU) runTo(line int32, dot int32) {
+	for ppu.pixel_y != line || ppu.pixel_x != dot {
+		ppu.tick()
+	}
+}
+
+func (ppu *PPU) writeBus(t *testing.T, addr uint16, value uint8) {
+	t.Helper()
+
+	if err := ppu.ppuWrite(addr, value); err != nil {
+		t.Fatalf("$%04x: %v", addr, err)
+	}
+}
+
+func (ppu *PPU) readBus(t *testing.T, addr uint16) uint8 {
+	t.Helper()
+
+	var value uint8
+
+	if err := ppu.ppuRead(addr, &value); err != nil {
+		t.Fatalf("$%04x: %v", addr, err)
+	}
+
+	return value
+}
+
+/*
+ * Fill every row of @tile in the pattern table at @base with the same
+ * two planes
+ */
+func (ppu *PPU) fillTile(t *testing.T, base uint16, tile uint8, lo uint8, hi uint8) {
+	t.Helper()
+
+	for row := range uint16(8) {
+		ppu.writeBus(t, base+uint16(tile)*16+row, lo)
+		ppu.writeBus(t, base+uint16(tile)*16+row+8, hi)
+	}
+}
+
+func (ppu *PPU) readReg(addr uint16) uint8 {
+	var value uint8
+
+	ppu.Read(addr, &value)
+
+	return value
+}
+
+/*
+ * $2005 and $2006 go through the same write toggle, so mixing them picks
+ * up wherever the other one left off. Reading $2002 resets it
+ */
+func TestScrollAddrToggle(t *testing.T) {
+	ppu := newTestPpu()
+
+	ppu.Write(PPU_CTL, 0x03)
+
+	if want := loopy(0, 3, 0, 0); ppu.tmp_addr != want {
+		t.Errorf("$2000: t=%04x, want %04x", ppu.tmp_addr, want)
+	}
+
+	// First $2005 write: coarse X and fine X
+	ppu.Write(PPU_SCROLL, 0x7d)
+
+	if want := loopy(0, 3, 0, 15); ppu.tmp_addr != want || ppu.fine_x != 5 || !ppu.write_toggle {
+		t.Errorf("$2005: t=%04x x=%d w=%v, want %04x 5 true", ppu.tmp_addr, ppu.fine_x, ppu.write_toggle, want)
+	}
+
+	// Which makes this the second $2006 write, the low byte
+	ppu.Write(PPU_ADDR, 0x42)
+
+	if ppu.tmp_addr != 0x0c42 || ppu.vram_addr != 0x0c42 || ppu.write_toggle {
+		t.Errorf("$2006: t=%04x v=%04x w=%v, want 0c42 0c42 false", ppu.tmp_addr, ppu.vram_addr, ppu.write_toggle)
+	}
+
+	// First $2006 write, with bit 14 of t cleared
+	ppu.tmp_addr = 0x7fff
+	ppu.Write(PPU_ADDR, 0xff)
+
+	if ppu.tmp_addr != 0x3fff || ppu.vram_addr != 0x0c42 {
+		t.Errorf("$2006 high: t=%04x v=%04x, want 3fff 0c42", ppu.tmp_addr, ppu.vram_addr)
+	}
+
+	// Second $2005 write: fine Y and coarse Y
+	ppu.Write(PPU_SCROLL, 0x5e)
+
+	if want := uint16(0x3fff&^(LOOPY_FINE_Y|LOOPY_COARSE_Y)) | loopy(6, 0, 11, 0); ppu.tmp_addr != want || ppu.write_toggle {
+		t.Errorf("$2005 second: t=%04x w=%v, want %04x false", ppu.tmp_addr, ppu.write_toggle, want)
+	}
+
+	ppu.Write(PPU_SCROLL, 0x00)
+	ppu.readReg(PPU_STATUS)
+
+	if ppu.write_toggle {
+		t.Errorf("$2002 read didn't reset w")
+	}
+
+	// The registers repeat every 8 bytes
+	ppu.Write(0x3f36, 0x21)
+	ppu.Write(0x200e, 0x08)
+
+	if ppu.vram_addr != 0x2108 {
+		t.Errorf("mirrored $2006: v=%04x, want 2108", ppu.vram_addr)
+	}
+}
+
+func TestReadBuffer(t *testing.T) {
+	ppu := newTestPpu()
+
+	ppu.writeBus(t, 0x2000, 0xaa)
+	ppu.writeBus(t, 0x2001, 0xbb)
+	ppu.writeBus(t, 0x2020, 0xcc)
+
+	ppu.Write(PPU_ADDR, 0x20)
+	ppu.Write(PPU_ADDR, 0x00)
+
+	for i, want := range []uint8{0x00, 0xaa, 0xbb} {
+		if got := ppu.readReg(PPU_DATA); got != want {
+			t.Errorf("read %d: got %02x, want %02x", i, got, want)
+		}
+	}
+
+	// Going down a row at a time
+	ppu.Write(PPU_CTL, PPU_CTL_ADDR_INC)
+	ppu.Write(PPU_ADDR, 0x20)
+	ppu.Write(PPU_ADDR, 0x00)
+
+	for i, want := range []uint8{0x00, 0xaa, 0xcc} {
+		if got := ppu.readReg(PPU_DATA); got != want {
+			t.Errorf("read %d: got %02x, want %02x", i, got, want)
+		}
+	}
+
+	if ppu.vram_addr != 0x2060 {
+		t.Errorf("v=%04x, want 2060", ppu.vram_addr)
+	}
+
+	// Writes land where v points and move it along
+	ppu.Write(PPU_CTL, 0)
+	ppu.Write(PPU_ADDR, 0x21)
+	ppu.Write(PPU_ADDR, 0x00)
+	ppu.Write(PPU_DATA, 0x11)
+	ppu.Write(PPU_DATA, 0x22)
+
+	if ppu.readBus(t, 0x2100) != 0x11 || ppu.readBus(t, 0x2101) != 0x22 || ppu.vram_addr != 0x2102 {
+		t.Errorf("$2007 writes went somewhere else")
+	}
+}
+
+/*
+ * Palette reads come straight back, with open bus in the top two bits,
+ * and fill the buffer with the nametable byte under them
+ */
+func TestReadPalette(t *testing.T) {
+	ppu := newTestPpu()
+
+	ppu.writeBus(t, 0x3f01, 0x21)
+	ppu.writeBus(t, 0x2f01, 0x55)
+
+	ppu.Write(PPU_ADDR, 0x3f)
+	ppu.Write(PPU_ADDR, 0x01)
+	// Only here to put $C0 on the bus
+	ppu.Write(PPU_OAMADDR, 0xc0)
+
+	if got := ppu.readReg(PPU_DATA); got != 0xe1 {
+		t.Errorf("palette read: got %02x, want e1", got)
+	}
+
+	if ppu.read_buffer != 0x55 {
+		t.Errorf("buffer: got %02x, want 55", ppu.read_buffer)
+	}
+
+	ppu.Write(PPU_ADDR, 0x20)
+	ppu.Write(PPU_ADDR, 0x00)
+
+	if got := ppu.readReg(PPU_DATA); got != 0x55 {
+		t.Errorf("read after palette read: got %02x, want 55", got)
+	}
+}
+
+/*
+ * Write-only registers read back whatever is left on the bus, and every
+ * bit fades out if nothing drives it for long enough
+ */
+func TestOpenBusDecay(t *testing.T) {
+	ppu := newTestPpu()
+
+	ppu.Write(PPU_OAMADDR, 0xff)
+
+	if got := ppu.readReg(PPU_CTL); got != 0xff {
+		t.Errorf("open bus: got %02x, want ff", got)
+	}
+
+	// Reading $2002 only drives the top three bits
+	ppu.frames = 20
+	ppu.SetStatusBits(PPU_STATUS_IN_VBLANK | PPU_STATUS_HIT_SPRITE0 | PPU_STATUS_SPRITE_OVERFLOW)
+
+	if got := ppu.readReg(PPU_STATUS); got != 0xff {
+		t.Errorf("$2002: got %02x, want ff", got)
+	}
+
+	tests := []struct {
+		frames uint64
+		want   uint8
+	}{
+		{PPU_OPEN_BUS_DECAY_FRAMES - 1, 0xff},
+		{PPU_OPEN_BUS_DECAY_FRAMES, 0xe0},
+		{20 + PPU_OPEN_BUS_DECAY_FRAMES - 1, 0xe0},
+		{20 + PPU_OPEN_BUS_DECAY_FRAMES, 0x00},
+	}
+
+	for _, test := range tests {
+		ppu.frames = test.frames
+
+		if got := ppu.readReg(PPU_MASK); got != test.want {
+			t.Errorf("frame %d: got %02x, want %02x", test.frames, got, test.want)
+		}
+	}
+}
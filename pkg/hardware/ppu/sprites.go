@@ -0,0 +1,266 @@
+package ppu
+
+/*
+ * Sprites
+ *
+ * Primary OAM holds 64 sprites, 4 bytes each:
+ *
+ *   byte 0: Y position (minus one, sprites show up a line late)
+ *   byte 1: tile index
+ *   byte 2: attributes
+ *   byte 3: X position
+ *
+ * At the end of every visible line the PPU looks through OAM for (at most
+ * 8) sprites that hit the next line and copies them into secondary OAM.
+ * Their pattern bytes get fetched during hblank and then the sprites get
+ * drawn over (or under) the background on the next line.
+ *
+ * See: https://www.nesdev.org/wiki/PPU_OAM
+ * See: https://www.nesdev.org/wiki/PPU_sprite_evaluation
+ */
+
+const (
+	PPU_OAM_SZ           = 256
+	PPU_SECONDARY_OAM_SZ = 32
+	PPU_SPRITES_PER_LINE = 8
+
+	/* Sprite attribute bits */
+	SPRITE_ATTR_PALETTE = 0x03
+	SPRITE_ATTR_UNUSED  = 0x1c
+	SPRITE_ATTR_BEHIND  = 0x20
+	SPRITE_ATTR_FLIP_X  = 0x40
+	SPRITE_ATTR_FLIP_Y  = 0x80
+)
+
+func (ppu *PPU) spriteHeight() int32 {
+	if (ppu.ctl_register & PPU_CTL_SPRITE_SZ_8x16) != 0 {
+		return 16
+	}
+
+	return 8
+}
+
+func (ppu *PPU) spriteInRange(y uint8) bool {
+	row := ppu.pixel_y - int32(y)
+
+	return row >= 0 && row < ppu.spriteHeight()
+}
+
+/*
+ * Find the sprites that show up on the next line and copy them into
+ * secondary OAM. Sprite 0 gets tracked, since it's the only one that can
+ * set the sprite 0 hit flag.
+ *
+ * Once 8 sprites are found the hardware keeps looking for a ninth to set
+ * the overflow flag, but it increments both the sprite index and the byte
+ * index within a sprite. So it ends up comparing tile numbers, attributes
+ * and X positions against the scanline, which is why the flag is so
+ * unreliable in practice. We do the same thing
+ */
+func (ppu *PPU) evaluateSprites() {
+	for i := range ppu.secondary_oam {
+		ppu.secondary_oam[i] = 0xff
+	}
+
+	ppu.sprite_count = 0
+	ppu.sprite0_next_line = false
+
+	n := 0
+
+	for ; n < 64 && ppu.sprite_count < PPU_SPRITES_PER_LINE; n++ {
+		y := ppu.oam[n*4]
+
+		if !ppu.spriteInRange(y) {
+			continue
+		}
+
+		if n == 0 {
+			ppu.sprite0_next_line = true
+		}
+
+		copy(ppu.secondary_oam[ppu.sprite_count*4:], ppu.oam[n*4:n*4+4])
+		ppu.sprite_count++
+	}
+
+	if ppu.sprite_count < PPU_SPRITES_PER_LINE {
+		return
+	}
+
+	/*
+	 * The buggy overflow search. It starts at the sprite after the eighth
+	 * one we found, and every miss moves on to the next sprite *and* the
+	 * next byte (wrapping within the sprite)
+	 */
+	m := 0
+
+	for ; n < 64; n++ {
+		if ppu.spriteInRange(ppu.oam[n*4+m]) {
+			ppu.SetStatusBits(PPU_STATUS_SPRITE_OVERFLOW)
+			break
+		}
+
+		m = (m + 1) & 0x03
+	}
+}
+
+/*
+ * Flip a pattern byte around for horizontally flipped sprites
+ */
+func reverseBits(b uint8) uint8 {
+	b = (b&0xf0)>>4 | (b&0x0f)<<4
+	b = (b&0xcc)>>2 | (b&0x33)<<2
+	b = (b&0xaa)>>1 | (b&0x55)<<1
+	return b
+}
+
+func (ppu *PPU) spritePatternAddr(tile uint8, attr uint8, row int32) uint16 {
+	if (attr & SPRITE_ATTR_FLIP_Y) != 0 {
+		row = ppu.spriteHeight() - 1 - row
+	}
+
+	// 8x16 sprites pick their pattern table with bit 0 of the tile index
+	if ppu.spriteHeight() == 16 {
+		base := uint16(tile&0x01) * 0x1000
+		tile &= 0xfe
+
+		if row >= 8 {
+			tile++
+			row -= 8
+		}
+
+		return base + uint16(tile)*16 + uint16(row)
+	}
+
+	var base uint16
+
+	if (ppu.ctl_register & PPU_CTL_SPRITE_PATTERN_TBL_ADDR) != 0 {
+		base = 0x1000
+	}
+
+	return base + uint16(tile)*16 + uint16(row)
+}
+
+/*
+ * Grab the pattern bytes for everything in secondary OAM, so they're
+ * ready to go on the next line
+ */
+func (ppu *PPU) fetchSprites() {
+	ppu.sprite0_in_line = ppu.sprite0_next_line
+
+	for i := range PPU_SPRITES_PER_LINE {
+		sprite := ppu.secondary_oam[i*4 : i*4+4]
+
+		// Empty slots still fetch, they just use tile $FF
+		if i >= ppu.sprite_count {
+			addr := ppu.spritePatternAddr(0xff, 0, 0)
+			ppu.fetch(addr)
+			ppu.fetch(addr + 8)
+
+			ppu.sprite_lo[i] = 0
+			ppu.sprite_hi[i] = 0
+			continue
+		}
+
+		addr := ppu.spritePatternAddr(sprite[1], sprite[2], ppu.pixel_y-int32(sprite[0]))
+		lo := ppu.fetch(addr)
+		hi := ppu.fetch(addr + 8)
+
+		if (sprite[2] & SPRITE_ATTR_FLIP_X) != 0 {
+			lo = reverseBits(lo)
+			hi = reverseBits(hi)
+		}
+
+		ppu.sprite_lo[i] = lo
+		ppu.sprite_hi[i] = hi
+		ppu.sprite_attr[i] = sprite[2]
+		ppu.sprite_x[i] = sprite[3]
+	}
+
+	ppu.sprites_in_line = ppu.sprite_count
+}
+
+/*
+ * The sprite parts of a visible or pre-render line
+ */
+func (ppu *PPU) renderSprites() {
+	// OAMADDR gets cleared all through the sprite fetches
+	if ppu.pixel_x >= 257 && ppu.pixel_x <= 320 {
+		ppu.oam_addr = 0
+	}
+
+	if ppu.pixel_y == ppu.prerender_line {
+		// Nothing to find for line 0, sprites can't show up there
+		if ppu.pixel_x == 257 {
+			ppu.sprite_count = 0
+			ppu.sprite0_next_line = false
+		}
+	} else if ppu.pixel_x == 257 {
+		ppu.evaluateSprites()
+	}
+
+	if ppu.pixel_x == 320 {
+		ppu.fetchSprites()
+	}
+}
+
+/*
+ * The first opaque sprite pixel at the current dot. Lower OAM indices win,
+ * no matter what their priority bit says
+ */
+func (ppu *PPU) spritePixel() (pixel uint8, palette uint8, behind bool, zero bool) {
+	if (ppu.mask_register & PPU_MASK_RENDER_SPRITES) == 0 {
+		return
+	}
+
+	if ppu.pixel_x <= 8 && (ppu.mask_register&PPU_MASK_SPRITES_SHOW_LEFT8) == 0 {
+		return
+	}
+
+	x := ppu.pixel_x - 1
+
+	for i := range ppu.sprites_in_line {
+		offset := x - int32(ppu.sprite_x[i])
+
+		if offset < 0 || offset >= 8 {
+			continue
+		}
+
+		shift := 7 - offset
+		pixel = (ppu.sprite_lo[i]>>shift)&0x01 | ((ppu.sprite_hi[i]>>shift)&0x01)<<1
+
+		if pixel == 0 {
+			continue
+		}
+
+		palette = 4 + (ppu.sprite_attr[i] & SPRITE_ATTR_PALETTE)
+		behind = (ppu.sprite_attr[i] & SPRITE_ATTR_BEHIND) != 0
+		zero = i == 0 && ppu.sprite0_in_line
+
+		return
+	}
+
+	return 0, 0, false, false
+}
+
+/*
+ * $2004 reads. The unused attribute bits don't exist in OAM, so they
+ * always read back as 0
+ */
+func (ppu *PPU) readOam() uint8 {
+	value := ppu.oam[ppu.oam_addr]
+
+	if (ppu.oam_addr & 0x03) == 2 {
+		value &= ^uint8(SPRITE_ATTR_UNUSED)
+	}
+
+	return value
+}
+
+/*
+ * $2004 writes. OAMADDR gets bumped after every one, which is what OAM DMA
+ * relies on too
+ */
+func (ppu *PPU) writeOam(value uint8) {
+	ppu.oam[ppu.oam_addr] = value
+	ppu.oam_addr++
+}
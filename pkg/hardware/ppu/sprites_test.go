@@ -0,0 +1,283 @@
+package ppu
+
+import (
+	"testing"
+)
+
+/* The line the sprite tests evaluate on, sprites with this Y show up on the one after */
+const testSpriteLine = 100
+
+/*
+ * OAM with every byte set to $FF (so nothing is in range) apart from the
+ * sprites in @sprites
+ */
+func (ppu *PPU) loadOam(sprites map[int][4]uint8) {
+	for i := range ppu.oam {
+		ppu.oam[i] = 0xff
+	}
+
+	for n, sprite := range sprites {
+		copy(ppu.oam[n*4:], sprite[:])
+	}
+}
+
+func inRange(n int) [4]uint8 {
+	return [4]uint8{testSpriteLine, uint8(n), 0, uint8(n * 8)}
+}
+
+func TestSpriteEvaluation(t *testing.T) {
+	eight := func() map[int][4]uint8 {
+		sprites := map[int][4]uint8{}
+
+		for n := range 8 {
+			sprites[n] = inRange(n)
+		}
+
+		return sprites
+	}
+
+	with := func(sprites map[int][4]uint8, n int, sprite [4]uint8) map[int][4]uint8 {
+		sprites[n] = sprite
+		return sprites
+	}
+
+	tests := []struct {
+		name     string
+		ctl      uint8
+		oam      map[int][4]uint8
+		want     []int
+		overflow bool
+	}{
+		{"empty", 0, nil, nil, false},
+		{"oam order", 0, map[int][4]uint8{9: inRange(9), 2: inRange(2), 40: inRange(40)}, []int{2, 9, 40}, false},
+		{"last row", 0, map[int][4]uint8{3: {testSpriteLine - 7, 3, 0, 0}}, []int{3}, false},
+		{"below", 0, map[int][4]uint8{3: {testSpriteLine - 8, 3, 0, 0}}, nil, false},
+		{"8x16 last row", PPU_CTL_SPRITE_SZ_8x16, map[int][4]uint8{3: {testSpriteLine - 15, 3, 0, 0}}, []int{3}, false},
+		{"8x16 below", PPU_CTL_SPRITE_SZ_8x16, map[int][4]uint8{3: {testSpriteLine - 16, 3, 0, 0}}, nil, false},
+		{"eight", 0, eight(), []int{0, 1, 2, 3, 4, 5, 6, 7}, false},
+		{"nine", 0, with(eight(), 8, inRange(8)), []int{0, 1, 2, 3, 4, 5, 6, 7}, true},
+		// Four misses later the search is back on byte 0
+		{"ninth after four misses", 0, with(eight(), 12, inRange(12)), []int{0, 1, 2, 3, 4, 5, 6, 7}, true},
+		/*
+		 * Sprite 8 misses, so the search moves on to byte 1 of sprite 9.
+		 * That's a tile number, but it looks like a Y on our line
+		 */
+		{"false positive", 0, with(eight(), 9, [4]uint8{0xff, testSpriteLine, 0xff, 0xff}), []int{0, 1, 2, 3, 4, 5, 6, 7}, true},
+		/*
+		 * Sprite 9 really is on our line, but the search looks at its tile
+		 * number instead of its Y and never finds it
+		 */
+		{"false negative", 0, with(eight(), 9, [4]uint8{testSpriteLine, 0xff, 0xff, 0xff}), []int{0, 1, 2, 3, 4, 5, 6, 7}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ppu := newTestPpu()
+			ppu.ctl_register = test.ctl
+			ppu.mask_register = PPU_MASK_RENDER_SPRITES
+			ppu.loadOam(test.oam)
+
+			ppu.seek(testSpriteLine, 0)
+			ppu.runTo(testSpriteLine+1, 0)
+
+			if ppu.sprite_count != len(test.want) {
+				t.Fatalf("found %d sprites, want %d", ppu.sprite_count, len(test.want))
+			}
+
+			var want [PPU_SECONDARY_OAM_SZ]uint8
+
+			for i := range want {
+				want[i] = 0xff
+			}
+
+			for i, n := range test.want {
+				copy(want[i*4:], ppu.oam[n*4:n*4+4])
+			}
+
+			if ppu.secondary_oam != want {
+				t.Errorf("secondary OAM:\n got %x\nwant %x", ppu.secondary_oam, want)
+			}
+
+			if overflow := (ppu.status_register & PPU_STATUS_SPRITE_OVERFLOW) != 0; overflow != test.overflow {
+				t.Errorf("overflow %v, want %v", overflow, test.overflow)
+			}
+
+			if ppu.sprite0_next_line != (len(test.want) > 0 && test.want[0] == 0) {
+				t.Errorf("sprite 0 tracking is off")
+			}
+		})
+	}
+}
+
+func TestSpritePatternAddr(t *testing.T) {
+	tests := []struct {
+		name string
+		ctl  uint8
+		tile uint8
+		attr uint8
+		row  int32
+		want uint16
+	}{
+		{"8x8", 0, 0x12, 0, 3, 0x0123},
+		{"8x8 right table", PPU_CTL_SPRITE_PATTERN_TBL_ADDR, 0x12, 0, 3, 0x1123},
+		{"8x8 flip y", 0, 0x12, SPRITE_ATTR_FLIP_Y, 3, 0x0124},
+		{"8x8 flip x", 0, 0x12, SPRITE_ATTR_FLIP_X, 3, 0x0123},
+		{"8x16 top", PPU_CTL_SPRITE_SZ_8x16, 0x12, 0, 3, 0x0123},
+		{"8x16 bottom", PPU_CTL_SPRITE_SZ_8x16, 0x12, 0, 11, 0x0133},
+		{"8x16 odd tile", PPU_CTL_SPRITE_SZ_8x16, 0x13, 0, 3, 0x1123},
+		// Bit 3 of PPUCTRL doesn't matter for 8x16 sprites
+		{"8x16 ignores table", PPU_CTL_SPRITE_SZ_8x16 | PPU_CTL_SPRITE_PATTERN_TBL_ADDR, 0x12, 0, 3, 0x0123},
+		{"8x16 flip y top", PPU_CTL_SPRITE_SZ_8x16, 0x12, SPRITE_ATTR_FLIP_Y, 0, 0x0137},
+		{"8x16 flip y bottom", PPU_CTL_SPRITE_SZ_8x16, 0x12, SPRITE_ATTR_FLIP_Y, 15, 0x0120},
+	}
+
+	for _, test := range tests {
+		ppu := newTestPpu()
+		ppu.ctl_register = test.ctl
+
+		if got := ppu.spritePatternAddr(test.tile, test.attr, test.row); got != test.want {
+			t.Errorf("%s: got $%04x, want $%04x", test.name, got, test.want)
+		}
+	}
+}
+
+/*
+ * The colors the render tests put in palette RAM
+ */
+const (
+	testBackdrop    = 0x0f
+	testBgColor     = 0x01
+	testSpriteColor = 0x21
+	testSprite2     = 0x22
+)
+
+/*
+ * Set up a PPU with a background of tile 0 everywhere, which is opaque in
+ * the left half of every tile, and sprite tiles 1 (opaque in the left two
+ * pixels) and 2 (fully opaque, with sprite palette 1). Then render
+ * testSpriteLine+1 with the sprites from @sprites
+ */
+func renderSprites(t *testing.T, sprites map[int][4]uint8) *PPU {
+	ppu := newTestPpu()
+
+	ppu.fillTile(t, 0x0000, 0, 0xf0, 0x00)
+	ppu.fillTile(t, 0x0000, 1, 0xc0, 0x00)
+	ppu.fillTile(t, 0x0000, 2, 0xff, 0x00)
+
+	ppu.writeBus(t, 0x3f00, testBackdrop)
+	ppu.writeBus(t, 0x3f01, testBgColor)
+	ppu.writeBus(t, 0x3f11, testSpriteColor)
+	ppu.writeBus(t, 0x3f15, testSprite2)
+
+	ppu.mask_register = PPU_MASK_RENDER_BG | PPU_MASK_RENDER_SPRITES |
+		PPU_MASK_BG_SHOW_LEFT8 | PPU_MASK_SPRITES_SHOW_LEFT8
+	ppu.loadOam(sprites)
+
+	ppu.seek(testSpriteLine, 0)
+	ppu.runTo(testSpriteLine+2, 0)
+
+	return ppu
+}
+
+func TestSpriteRendering(t *testing.T) {
+	tests := []struct {
+		name    string
+		sprites map[int][4]uint8
+		/* Expected color index for x = 16 up to 23 */
+		want [8]uint8
+		hit  bool
+	}{
+		{
+			"background only", nil,
+			[8]uint8{testBgColor, testBgColor, testBgColor, testBgColor, testBackdrop, testBackdrop, testBackdrop, testBackdrop},
+			false,
+		},
+		{
+			"in front", map[int][4]uint8{1: {testSpriteLine, 1, 0, 16}},
+			[8]uint8{testSpriteColor, testSpriteColor, testBgColor, testBgColor, testBackdrop, testBackdrop, testBackdrop, testBackdrop},
+			false,
+		},
+		{
+			"flip x", map[int][4]uint8{1: {testSpriteLine, 1, SPRITE_ATTR_FLIP_X, 16}},
+			[8]uint8{testBgColor, testBgColor, testBgColor, testBgColor, testBackdrop, testBackdrop, testSpriteColor, testSpriteColor},
+			false,
+		},
+		{
+			"behind", map[int][4]uint8{1: {testSpriteLine, 2, SPRITE_ATTR_BEHIND, 16}},
+			[8]uint8{testBgColor, testBgColor, testBgColor, testBgColor, testSpriteColor, testSpriteColor, testSpriteColor, testSpriteColor},
+			false,
+		},
+		{
+			// Sprite 1 is in front, but sprite 0 gets picked first and it's behind
+			"lower index wins",
+			map[int][4]uint8{
+				0: {testSpriteLine, 1, SPRITE_ATTR_BEHIND, 16},
+				1: {testSpriteLine, 2, 1, 16},
+			},
+			[8]uint8{testBgColor, testBgColor, testSprite2, testSprite2, testSprite2, testSprite2, testSprite2, testSprite2},
+			true,
+		},
+		{
+			"sprite 0 hit", map[int][4]uint8{0: {testSpriteLine, 1, 0, 16}},
+			[8]uint8{testSpriteColor, testSpriteColor, testBgColor, testBgColor, testBackdrop, testBackdrop, testBackdrop, testBackdrop},
+			true,
+		},
+		{
+			"sprite 0 over backdrop", map[int][4]uint8{0: {testSpriteLine, 1, 0, 20}},
+			[8]uint8{testBgColor, testBgColor, testBgColor, testBgColor, testSpriteColor, testSpriteColor, testBackdrop, testBackdrop},
+			false,
+		},
+		{
+			"other sprite over background", map[int][4]uint8{5: {testSpriteLine, 1, 0, 16}},
+			[8]uint8{testSpriteColor, testSpriteColor, testBgColor, testBgColor, testBackdrop, testBackdrop, testBackdrop, testBackdrop},
+			false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ppu := renderSprites(t, test.sprites)
+
+			for i, want := range test.want {
+				x := int32(16 + i)
+
+				if got := ppu.frame.Index(x, testSpriteLine+1); got != uint16(want) {
+					t.Errorf("x=%d: got %02x, want %02x", x, got, want)
+				}
+			}
+
+			if hit := (ppu.status_register & PPU_STATUS_HIT_SPRITE0) != 0; hit != test.hit {
+				t.Errorf("sprite 0 hit %v, want %v", hit, test.hit)
+			}
+		})
+	}
+}
+
+/*
+ * The hit can't happen on the last dot of the line, even with both pixels
+ * opaque there
+ */
+func TestSprite0HitLastPixel(t *testing.T) {
+	ppu := newTestPpu()
+
+	ppu.fillTile(t, 0x0000, 0, 0xff, 0x00)
+	ppu.fillTile(t, 0x0000, 1, 0x01, 0x00)
+
+	ppu.mask_register = PPU_MASK_RENDER_BG | PPU_MASK_RENDER_SPRITES |
+		PPU_MASK_BG_SHOW_LEFT8 | PPU_MASK_SPRITES_SHOW_LEFT8
+	ppu.loadOam(map[int][4]uint8{0: {testSpriteLine, 1, 0, 248}})
+
+	ppu.seek(testSpriteLine, 0)
+	ppu.runTo(testSpriteLine+2, 0)
+
+	if (ppu.status_register & PPU_STATUS_HIT_SPRITE0) != 0 {
+		t.Errorf("sprite 0 hit on x=255")
+	}
+
+	ppu.loadOam(map[int][4]uint8{0: {testSpriteLine + 2, 1, 0, 247}})
+	ppu.runTo(testSpriteLine+4, 0)
+
+	if (ppu.status_register & PPU_STATUS_HIT_SPRITE0) == 0 {
+		t.Errorf("no sprite 0 hit on x=254")
+	}
+}
@@ -1 +1,107 @@
 package ppu
+
+import "errors"
+
+/*
+ * Nametable memory
+ *
+ * The PPU has 4 nametables in its address space ($2000-$2FFF, mirrored up
+ * to $3EFF), but the NES only has 2KB of RAM for them (CIRAM). The
+ * cartridge decides how the 4 tables get folded onto the 2 physical ones
+ * by driving CIRAM A10, which is what the mirroring modes describe.
+ * Four-screen carts bring 2KB of their own, so every table is real.
+ *
+ * See: https://www.nesdev.org/wiki/Mirroring
+ */
+
+type Mirroring uint8
+
+const (
+	/* $2000 = $2400, $2800 = $2C00 (vertical scrolling games) */
+	MIRROR_HORIZONTAL Mirroring = iota
+	/* $2000 = $2800, $2400 = $2C00 (horizontal scrolling games) */
+	MIRROR_VERTICAL
+	/* Everything goes to the first table */
+	MIRROR_SINGLE_LOW
+	/* Everything goes to the second table */
+	MIRROR_SINGLE_HIGH
+	/* No mirroring at all, with the extra RAM on the cart */
+	MIRROR_FOUR_SCREEN
+)
+
+const (
+	PPU_NAMETABLE_SZ    = 1024
+	PPU_CIRAM_SZ        = 2 * PPU_NAMETABLE_SZ
+	PPU_VRAM_MIRROR_END = 0x3eff
+)
+
+type Vram struct {
+	/* CIRAM, plus the cart RAM for four-screen */
+	memory    [4 * PPU_NAMETABLE_SZ]byte
+	mirroring Mirroring
+}
+
+func NewVram(mirroring Mirroring) *Vram {
+	return &Vram{
+		mirroring: mirroring,
+	}
+}
+
+/*
+ * Lets the cartridge (or a mapper, at any point) pick the mirroring
+ */
+func (vram *Vram) SetMirroring(mirroring Mirroring) {
+	vram.mirroring = mirroring
+}
+
+func (vram *Vram) GetMirroring() Mirroring {
+	return vram.mirroring
+}
+
+/*
+ * Turn a PPU address into an offset in our memory
+ */
+func (vram *Vram) offset(addr uint16) uint16 {
+	addr = (addr - PPU_VRAM_BASE) & 0x0fff
+
+	table := addr / PPU_NAMETABLE_SZ
+
+	switch vram.mirroring {
+	case MIRROR_HORIZONTAL:
+		table >>= 1
+	case MIRROR_VERTICAL:
+		table &= 1
+	case MIRROR_SINGLE_LOW:
+		table = 0
+	case MIRROR_SINGLE_HIGH:
+		table = 1
+	}
+
+	return table*PPU_NAMETABLE_SZ + (addr % PPU_NAMETABLE_SZ)
+}
+
+func (vram *Vram) Read(addr uint16, value *uint8) error {
+	if addr < PPU_VRAM_BASE || addr > PPU_VRAM_MIRROR_END {
+		return errors.New("vram: read out of range!")
+	}
+
+	*value = vram.memory[vram.offset(addr)]
+	return nil
+}
+
+func (vram *Vram) Write(addr uint16, value uint8) error {
+	if addr < PPU_VRAM_BASE || addr > PPU_VRAM_MIRROR_END {
+		return errors.New("vram: write out of range!")
+	}
+
+	vram.memory[vram.offset(addr)] = value
+	return nil
+}
+
+func (vram *Vram) StartAddr() uint16 {
+	return PPU_VRAM_BASE
+}
+
+func (vram *Vram) EndAddr() uint16 {
+	return PPU_VRAM_MIRROR_END
+}
@@ -0,0 +1,81 @@
+package ppu
+
+import (
+	"testing"
+)
+
+func TestMirroring(t *testing.T) {
+	tests := []struct {
+		name      string
+		mirroring Mirroring
+		/* CIRAM offsets for $2000, $2400, $2800 and $2C00 */
+		want [4]uint16
+	}{
+		{"horizontal", MIRROR_HORIZONTAL, [4]uint16{0x000, 0x000, 0x400, 0x400}},
+		{"vertical", MIRROR_VERTICAL, [4]uint16{0x000, 0x400, 0x000, 0x400}},
+		{"single low", MIRROR_SINGLE_LOW, [4]uint16{0x000, 0x000, 0x000, 0x000}},
+		{"single high", MIRROR_SINGLE_HIGH, [4]uint16{0x400, 0x400, 0x400, 0x400}},
+		{"four screen", MIRROR_FOUR_SCREEN, [4]uint16{0x000, 0x400, 0x800, 0xc00}},
+	}
+
+	for _, test := range tests {
+		vram := NewVram(test.mirroring)
+
+		for i, want := range test.want {
+			addr := uint16(PPU_VRAM_BASE + i*PPU_NAMETABLE_SZ)
+
+			// Somewhere in the middle of the table, and up in the $3000 mirror
+			for _, a := range []uint16{addr + 0x123, addr + 0x1123} {
+				if got := vram.offset(a); got != want+0x123 {
+					t.Errorf("%s: $%04x went to %03x, want %03x", test.name, a, got, want+0x123)
+				}
+			}
+		}
+	}
+}
+
+/*
+ * Mappers can flip the mirroring whenever they like, and the same bytes
+ * show up in different places right away
+ */
+func TestSetMirroring(t *testing.T) {
+	vram := NewVram(MIRROR_HORIZONTAL)
+
+	vram.Write(0x2000, 0x11)
+	vram.Write(0x2c00, 0x22)
+
+	tests := []struct {
+		mirroring Mirroring
+		/* What $2000, $2400, $2800 and $2C00 read back */
+		want [4]uint8
+	}{
+		{MIRROR_HORIZONTAL, [4]uint8{0x11, 0x11, 0x22, 0x22}},
+		{MIRROR_VERTICAL, [4]uint8{0x11, 0x22, 0x11, 0x22}},
+		{MIRROR_SINGLE_LOW, [4]uint8{0x11, 0x11, 0x11, 0x11}},
+		{MIRROR_SINGLE_HIGH, [4]uint8{0x22, 0x22, 0x22, 0x22}},
+	}
+
+	for _, test := range tests {
+		vram.SetMirroring(test.mirroring)
+
+		if vram.GetMirroring() != test.mirroring {
+			t.Errorf("mirroring %d didn't stick", test.mirroring)
+		}
+
+		for i, want := range test.want {
+			var got uint8
+
+			addr := uint16(PPU_VRAM_BASE + i*PPU_NAMETABLE_SZ)
+
+			if err := vram.Read(addr, &got); err != nil || got != want {
+				t.Errorf("mirroring %d: $%04x got %02x (%v), want %02x", test.mirroring, addr, got, err, want)
+			}
+		}
+	}
+
+	var value uint8
+
+	if vram.Read(0x3f00, &value) == nil || vram.Write(0x1fff, 0) == nil {
+		t.Errorf("vram answered outside of $2000-$3EFF")
+	}
+}
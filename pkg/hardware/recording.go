@@ -0,0 +1,123 @@
+package hardware
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/beakeyz/gones-emu/pkg/audio"
+	"github.com/beakeyz/gones-emu/pkg/debug"
+	"github.com/beakeyz/gones-emu/pkg/hardware/apu"
+)
+
+/*
+ * Dumping the APU output to .wav files
+ *
+ * The mixed output goes into the file we get asked for. With channels on,
+ * every channel also gets a file next to it (music.wav, music.pulse1.wav,
+ * music.pulse2.wav, ...)
+ */
+
+/* What the hotkey records at */
+const RECORDING_DEFAULT_RATE = audio.SAMPLE_RATE_48000
+
+/*
+ * The file @ch gets recorded to, when the mix goes to @path
+ */
+func channelRecordingPath(path string, ch apu.Channel) string {
+	ext := filepath.Ext(path)
+
+	if ext == "" {
+		ext = ".wav"
+	}
+
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "." + ch.String() + ext
+}
+
+func (system *NESSystem) StartRecording(path string, rate int, channels bool) error {
+	if system.recorder != nil {
+		return errors.New("hardware: already recording")
+	}
+
+	paths := []string{path}
+
+	if channels {
+		for ch := range apu.CHANNEL_COUNT {
+			paths = append(paths, channelRecordingPath(path, ch))
+		}
+	}
+
+	rec, err := audio.NewRecorder(paths, rate, system.region.Timing().CpuClockHz)
+
+	if err != nil {
+		return err
+	}
+
+	system.recorder = rec
+	system.recordRate = rate
+	system.recordChannels = channels
+
+	debug.Log("Recording audio to '%s'\n", path)
+
+	return nil
+}
+
+func (system *NESSystem) StopRecording() error {
+	if system.recorder == nil {
+		return nil
+	}
+
+	// Whatever is still in the resamplers is less than a frame, so just drop it
+	err := system.recorder.Close()
+	system.recorder = nil
+
+	debug.Log("Stopped recording audio\n")
+
+	return err
+}
+
+func (system *NESSystem) IsRecording() bool {
+	return system.recorder != nil
+}
+
+/*
+ * For the hotkey. New recordings get a name with the time in it, so we
+ * never overwrite an older one
+ */
+func (system *NESSystem) toggleRecording() {
+	var err error
+
+	if system.recorder != nil {
+		err = system.StopRecording()
+	} else {
+		rate := system.recordRate
+
+		if rate == 0 {
+			rate = RECORDING_DEFAULT_RATE
+		}
+
+		path := "gones-" + time.Now().Format("20060102-150405") + ".wav"
+
+		err = system.StartRecording(path, rate, system.recordChannels)
+	}
+
+	if err != nil {
+		debug.Error("Recording failed: %s\n", err.Error())
+	}
+}
+
+/*
+ * Feed the recorder a CPU cycle worth of output
+ */
+func (system *NESSystem) recordCycle() {
+	system.recorder.Clock(0, system.Apu.Output())
+
+	if system.recorder.Tracks() == 1 {
+		return
+	}
+
+	for ch := range apu.CHANNEL_COUNT {
+		system.recorder.Clock(1+int(ch), system.Apu.ChannelMix(ch))
+	}
+}
@@ -0,0 +1,116 @@
+package region
+
+import "fmt"
+
+/*
+ * Which kind of console we're pretending to be. They all run the same
+ * games, but the clocks are different, and so is the amount of scanlines
+ * the PPU does per frame
+ *
+ * See: https://www.nesdev.org/wiki/Cycle_reference_chart
+ */
+type Region int
+
+const (
+	REGION_NTSC Region = iota
+	REGION_PAL
+	/* Famiclone with PAL timing that's mostly NTSC compatible */
+	REGION_DENDY
+)
+
+type Timing struct {
+	/* CPU clock, in Hz */
+	CpuClockHz float64
+	/* PPU dots per CPU cycle, as a fraction */
+	PpuDotsNum int
+	PpuDotsDen int
+	/* Scanlines per frame, pre-render line included */
+	Scanlines int32
+	/* The line vblank (and the NMI) starts on */
+	VblankLine int32
+	/* Does the PPU skip a dot on odd frames when rendering? */
+	OddFrameSkip bool
+}
+
+var timings = [...]Timing{
+	REGION_NTSC: {
+		// 21.477272 MHz / 12
+		CpuClockHz:   1789772.7272,
+		PpuDotsNum:   3,
+		PpuDotsDen:   1,
+		Scanlines:    262,
+		VblankLine:   241,
+		OddFrameSkip: true,
+	},
+	REGION_PAL: {
+		// 26.601712 MHz / 16
+		CpuClockHz:   1662607.0,
+		PpuDotsNum:   16,
+		PpuDotsDen:   5,
+		Scanlines:    312,
+		VblankLine:   241,
+		OddFrameSkip: false,
+	},
+	REGION_DENDY: {
+		// 26.601712 MHz / 15. The extra lines go before vblank, so games
+		// written for NTSC get the vblank length they expect
+		CpuClockHz:   1773447.4667,
+		PpuDotsNum:   3,
+		PpuDotsDen:   1,
+		Scanlines:    312,
+		VblankLine:   291,
+		OddFrameSkip: false,
+	},
+}
+
+func (r Region) Timing() Timing {
+	if r < 0 || int(r) >= len(timings) {
+		return timings[REGION_NTSC]
+	}
+
+	return timings[r]
+}
+
+/*
+ * Average amount of CPU cycles in a frame (29780.5 on NTSC)
+ */
+func (t Timing) CpuCyclesPerFrame() float64 {
+	dots := float64(t.Scanlines) * 341
+
+	if t.OddFrameSkip {
+		dots -= 0.5
+	}
+
+	return dots * float64(t.PpuDotsDen) / float64(t.PpuDotsNum)
+}
+
+/*
+ * Frames per second (60.0988 on NTSC)
+ */
+func (t Timing) FrameRate() float64 {
+	return t.CpuClockHz / t.CpuCyclesPerFrame()
+}
+
+func (r Region) String() string {
+	switch r {
+	case REGION_PAL:
+		return "pal"
+	case REGION_DENDY:
+		return "dendy"
+	}
+
+	return "ntsc"
+}
+
+func Parse(name string) (Region, error) {
+	switch name {
+	case "ntsc":
+		return REGION_NTSC, nil
+	case "pal":
+		return REGION_PAL, nil
+	case "dendy":
+		return REGION_DENDY, nil
+	}
+
+	return REGION_NTSC, fmt.Errorf("region: unknown region '%s'", name)
+}
@@ -3,16 +3,18 @@ package hardware
 import (
 	"errors"
 	"fmt"
-	"time"
 
+	"github.com/beakeyz/gones-emu/pkg/audio"
+	"github.com/beakeyz/gones-emu/pkg/debug"
+	"github.com/beakeyz/gones-emu/pkg/hardware/apu"
 	"github.com/beakeyz/gones-emu/pkg/hardware/bus"
+	"github.com/beakeyz/gones-emu/pkg/hardware/controller"
 	"github.com/beakeyz/gones-emu/pkg/hardware/cpu/cpu6502"
 	"github.com/beakeyz/gones-emu/pkg/hardware/memory/cartridge"
 	"github.com/beakeyz/gones-emu/pkg/hardware/memory/ram"
-	"github.com/beakeyz/gones-emu/pkg/hardware/mirror"
 	"github.com/beakeyz/gones-emu/pkg/hardware/ppu"
+	"github.com/beakeyz/gones-emu/pkg/hardware/region"
 	"github.com/beakeyz/gones-emu/pkg/video"
-	"github.com/veandco/go-sdl2/sdl"
 )
 
 /*
@@ -29,23 +31,45 @@ type NESSystem struct {
 	MainCpu *cpu6502.CPU6502
 	/* PPU */
 	Ppu *ppu.PPU
+	/* APU */
+	Apu *apu.APU
+	/* Controller ports */
+	Ports *controller.Ports
 	/* Regular system RAM */
 	Ram *ram.Ram
 	/* Bus */
 	Bus *bus.SystemBus
 
 	/* The backend */
-	vbackend *video.VideoBackend
+	vbackend video.VideoBackend
+	/* Where the APU output goes, nil when we don't care about sound */
+	audio *audio.Stream
+	/* Dumps the APU output to .wav files, nil when we're not recording */
+	recorder       *audio.Recorder
+	recordRate     int
+	recordChannels bool
+
+	/* Which console we are, and the PPU dots we owe the PPU on PAL */
+	region       region.Region
+	ppuDotsNum   int
+	ppuDotsDen   int
+	ppuDotsAccum int
 
 	/* How many system ticks have already been done */
 	elapsedTicks uint64
+	/* Which built-in palette we're on, for cycling through them */
+	paletteIdx int
+	/* Host keys to controller buttons */
+	bindings []Binding
 }
 
-func InitNesSystem(vidBackend *video.VideoBackend, cardridgePath string) (*NESSystem, error) {
+func InitNesSystem(vidBackend video.VideoBackend, cardridgePath string) (*NESSystem, error) {
 	var err error
 	var ret *NESSystem = nil
 	var _cpu *cpu6502.CPU6502 = nil
 	var _ppu *ppu.PPU = nil
+	var _apu *apu.APU = nil
+	var _ports *controller.Ports = nil
 	var _ram *ram.Ram = nil
 	var _bus *bus.SystemBus = nil
 
@@ -57,36 +81,44 @@ func InitNesSystem(vidBackend *video.VideoBackend, cardridgePath string) (*NESSy
 	}
 
 	// Create a new CPU
-	_cpu = cpu6502.New(_bus)
+	_cpu = cpu6502.New(_bus, cpu6502.C6502_VARIANT_2A03)
 
 	if _cpu == nil {
 		return nil, errors.New("Failed to create CPU for the system")
 	}
 
 	// Create the PPU
-	_ppu = ppu.New(vidBackend)
+	_ppu = ppu.New(vidBackend, _cpu)
 
 	if _ppu == nil {
 		return nil, errors.New("Failed to create PPU for the system")
 	}
 
+	// And the APU
+	_apu = apu.New(_cpu)
+
 	// Add it's ram
 	_ram = ram.New(0, 0x1fff, 0x0800)
 
 	// Add the ram component
 	_bus.AddComponent(_ram)
 
-	// Add the PPU component
+	// Add the PPU component, it takes care of its own register mirrors
 	_bus.AddComponent(_ppu)
 
-	// Add the PPUs mirrors of the register space
-	// TODO: Let the PPU module add its ranges on its own?
-	for i := range 1023 {
-		_bus.AddComponent(mirror.New(uint16(0x2008+(i*8)), uint16(0x200f+(i*8)), _ppu))
-	}
+	// OAM DMA lives on the CPU, but it needs its register on the bus
+	_bus.AddComponent(cpu6502.NewOamDma(_cpu))
+
+	// The controllers sit on top of the APU frame counter at $4017, so they
+	// go before the APU and pass the $4017 writes on to it
+	_ports = controller.NewPorts(_cpu, _apu)
+	_bus.AddComponent(_ports)
+
+	// The APU registers go around $4014, so it needs to come after OAM DMA
+	_bus.AddComponent(_apu)
 
 	// Try to load the cardridge
-	err = cartridge.LoadCardridge(_bus, _ppu.PpuBus, cardridgePath)
+	info, err := cartridge.LoadCardridge(_bus, _ppu.PpuBus, _ppu.Vram, cardridgePath)
 
 	// Fuck
 	if err != nil {
@@ -99,41 +131,214 @@ func InitNesSystem(vidBackend *video.VideoBackend, cardridgePath string) (*NESSy
 	ret = &NESSystem{
 		MainCpu:      _cpu,
 		Ppu:          _ppu,
+		Apu:          _apu,
+		Ports:        _ports,
 		Bus:          _bus,
 		Ram:          _ram,
 		vbackend:     vidBackend,
 		elapsedTicks: 0,
+		bindings:     DefaultBindings(),
 	}
 
+	// NTSC, unless the cartridge knows better
+	ret.SetRegion(info.Region)
+
+	debug.Log("Region: %s\n", info.Region)
+
 	return ret, nil
 }
 
+/*
+ * Press the reset button. The CPU and APU reset together, so a DMC fetch
+ * the CPU drops doesn't leave the APU waiting on it
+ */
+func (system *NESSystem) Reset() {
+	system.MainCpu.Reset()
+	system.Apu.Reset()
+}
+
+/*
+ * Switch to the clocks and frame layout of @r
+ */
+func (system *NESSystem) SetRegion(r region.Region) {
+	timing := r.Timing()
+
+	system.region = r
+	system.ppuDotsNum = timing.PpuDotsNum
+	system.ppuDotsDen = timing.PpuDotsDen
+	system.ppuDotsAccum = 0
+
+	system.Ppu.SetRegion(r)
+	system.Apu.SetRegion(r)
+
+	if system.audio != nil {
+		system.audio.SetClockRate(timing.CpuClockHz)
+	}
+
+	if system.recorder != nil {
+		system.recorder.SetClockRate(timing.CpuClockHz)
+	}
+}
+
+func (system *NESSystem) GetRegion() region.Region {
+	return system.region
+}
+
+/*
+ * Start sending the APU output to @backend
+ */
+func (system *NESSystem) SetAudioBackend(backend audio.AudioBackend) {
+	system.audio = audio.NewStream(backend, system.region.Timing().CpuClockHz)
+}
+
+/*
+ * Let the audio backend finish up. Files need this to end up valid
+ */
+func (system *NESSystem) CloseAudio() error {
+	if system.audio == nil {
+		return nil
+	}
+
+	err := system.audio.Close()
+	system.audio = nil
+
+	return err
+}
+
 /*
  * Execute a single frame
+ *
+ * Runs one CPU instruction, cycle by cycle, with the PPU doing its three
+ * dots after every CPU cycle so it sees the bus accesses in the right order
  */
 func (system *NESSystem) SystemFrame() error {
 
 	var err error
-	var cpuCyclesElapsed int
 
-	/* Do a single CPU cycle */
-	err = system.MainCpu.ExecuteFrame(&cpuCyclesElapsed)
+	for {
+		/* Do a single CPU cycle */
+		err = system.MainCpu.DoCycle()
+
+		if err != nil {
+			return err
+		}
+
+		/* The APU runs off the CPU clock */
+		system.Apu.Clock()
+
+		if system.audio != nil {
+			system.audio.Clock(system.Apu.Output())
+		}
+
+		if system.recorder != nil {
+			system.recordCycle()
+		}
+
+		/*
+		 * Do three PPU cycles, to comply with relative component speed. On
+		 * PAL that's 3.2, so every fifth cycle gets an extra dot
+		 */
+		system.ppuDotsAccum += system.ppuDotsNum
+		system.Ppu.Execute(system.ppuDotsAccum / system.ppuDotsDen)
+		system.ppuDotsAccum %= system.ppuDotsDen
+
+		if system.MainCpu.InstructionDone() {
+			break
+		}
+	}
+
+	/* Increment the system ticks */
+	system.elapsedTicks++
+
+	return nil
+}
+
+/*
+ * Run until the PPU finished the frame it's currently on. This is all a
+ * headless frontend needs, the output ends up in the video backend
+ */
+func (system *NESSystem) StepFrame() error {
+	err := system.runFrame()
 
 	if err != nil {
 		return err
 	}
 
-	/* Do three PPU cycles, to comply with relative component speed */
-	system.Ppu.Execute(cpuCyclesElapsed * 3)
+	system.vbackend.Flush()
 
-	/* Increment the system ticks */
-	system.elapsedTicks++
+	return nil
+}
+
+/*
+ * Run the CPU (and everything hanging off of it) until the PPU finished
+ * its frame. That's 29780.5 CPU cycles on average for NTSC, since the PPU
+ * skips a dot on every other frame
+ */
+func (system *NESSystem) runFrame() error {
+	frame := system.Ppu.GetFrameCount()
+
+	for system.Ppu.GetFrameCount() == frame {
+		err := system.SystemFrame()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if system.recorder != nil {
+		err := system.recorder.EndFrame()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if system.audio != nil {
+		return system.audio.EndFrame()
+	}
 
 	return nil
 }
 
+/*
+ * Switch to a built-in palette, or a .pal file
+ */
+func (system *NESSystem) SetPalette(name string) error {
+	palette, err := ppu.FindPalette(name)
+
+	if err != nil {
+		return err
+	}
+
+	system.Ppu.SetPalette(palette)
+
+	// Keep cycling from wherever we are now
+	for i, builtin := range ppu.PaletteNames() {
+		if builtin == name {
+			system.paletteIdx = i
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Move on to the next built-in palette
+ */
+func (system *NESSystem) nextPalette() {
+	names := ppu.PaletteNames()
+
+	system.paletteIdx = (system.paletteIdx + 1) % len(names)
+
+	debug.Log("Switching to palette '%s'\n", names[system.paletteIdx])
+
+	if err := system.SetPalette(names[system.paletteIdx]); err != nil {
+		debug.Error("Switching palettes failed: %s\n", err.Error())
+	}
+}
+
 func (system *NESSystem) displayDebugInfo() {
-	var b *video.VideoBackend = system.vbackend
+	var b video.VideoBackend = system.vbackend
 
 	s := fmt.Sprintf(
 		"A: 0x%x, Flags: 0x%x",
@@ -141,7 +346,7 @@ func (system *NESSystem) displayDebugInfo() {
 		system.MainCpu.GetFlags(),
 	)
 
-	b.DrawText(500, 25, s, video.ColorWhite())
+	b.DrawText(8*8, 0, s, video.ColorWhite())
 }
 
 func (system *NESSystem) preDraw() {
@@ -158,39 +363,3 @@ func (system *NESSystem) preDraw() {
 
 	system.displayDebugInfo()
 }
-
-func (system *NESSystem) StartLoop() {
-
-	running := true
-	ran_tick := false
-
-	for running {
-
-		event := system.vbackend.CollectEvent()
-
-		switch event.(type) {
-		case *sdl.QuitEvent:
-			running = false
-		}
-
-		system.preDraw()
-
-		if system.vbackend.IsKeyPressed(sdl.K_RETURN) && !ran_tick {
-			err := system.SystemFrame()
-
-			if err != nil {
-				break
-			}
-
-			ran_tick = true
-		}
-
-		if !system.vbackend.IsKeyPressed(sdl.K_RETURN) && ran_tick {
-			ran_tick = false
-		}
-
-		system.vbackend.Flush()
-
-		time.Sleep(time.Millisecond)
-	}
-}
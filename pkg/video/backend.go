@@ -1,15 +1,30 @@
 package video
 
-import "github.com/veandco/go-sdl2/sdl"
+import "fmt"
 
-type VideoBackend struct {
-	sdlWindow   *sdl.Window
-	sdlRenderer *sdl.Renderer
-
-	defaultFont     Font
-	backgroundColor Color
-
-	deferFlush bool
+/*
+ * Whatever the NES output ends up on. This can be an actual window, or
+ * just a chunk of memory when we're running without a display
+ */
+type VideoBackend interface {
+	/* Grab the next pending host event, or nil if there is none */
+	CollectEvent() Event
+	IsKeyPressed(key Key) bool
+
+	/* Take a finished NES screen. It shows up on the next flush */
+	SubmitFrame(frame *Frame)
+	/* Draw over the NES screen, (0, 0) being its top left corner */
+	DrawNESPixel(x int32, y int32, clr Color)
+	/* Draw in host coordinates, for anything that isn't the NES screen */
+	DrawPixel(x int32, y int32, clr Color)
+	DrawRect(x int32, y int32, w int32, h int32, clr Color)
+	DrawNESText(x int32, y int32, text string, color Color)
+	DrawText(x int32, y int32, text string, color Color)
+
+	UpdateBackground()
+	SetDeferFlush(def bool)
+	/* Present whatever got drawn since the last flush */
+	Flush()
 }
 
 type Color struct {
@@ -25,113 +40,71 @@ const (
 	NES_SCREEN_HEIGHT = 240
 	NES_SCREEN_WIDTH  = 256
 
-	// Offset the NES screen a bit from the top left
-
-	NES_SCREEN_X_START = 25
-	NES_SCREEN_Y_START = 25
-
-	/* NES pixel to HOST pixel ratio */
-	NES_PTHP_RATIO = 2
+	/* NES pixels aren't square, a TV shows them 8:7 wide */
+	NES_PIXEL_ASPECT = 8.0 / 7.0
 )
 
-func NewColor(r uint8, g uint8, b uint8, a uint8) Color {
-	return Color{r, g, b, a}
-}
-
-func ColorWhite() Color {
-	return NewColor(0xff, 0xff, 0xff, 0xff)
-}
-
-func InitVideo(backend *VideoBackend) error {
-	var err error
-
-	/* Initialize the SDL library for video stuff */
-	err = sdl.Init(sdl.INIT_VIDEO)
-
-	if err != nil {
-		return err
-	}
-
-	/* Initialize a window and a renderer for us to draw with */
-	backend.sdlWindow, backend.sdlRenderer, err = sdl.CreateWindowAndRenderer(SCREEN_WIDTH, SCREEN_HEIGHT, 0)
-
-	if err != nil {
-		return err
-	}
-
-	// Create a new font for us to use
-
-	backend.defaultFont = NewFont(backend)
-
-	// Defer flushing calls
-
-	backend.deferFlush = true
-	backend.backgroundColor = NewColor(0x1f, 0x1f, 0x1f, 0xff)
-
-	return nil
-}
-
 /*
- * TODO: Rename the 'video' package lmao
- *
- * It's much more than video atm
+ * How the NES screen gets blown up to fit the window
  */
-func (back *VideoBackend) CollectEvent() sdl.Event {
-	return sdl.PollEvent()
-}
+type ScaleMode int
 
-func (back *VideoBackend) IsKeyPressed(a sdl.Keycode) bool {
-	sc := sdl.GetScancodeFromKey(a)
-	return (sdl.GetKeyboardState()[sc] != 0)
-}
-
-func (back *VideoBackend) DrawNESPixel(x int32, y int32, clr Color) {
+const (
+	/* Biggest whole multiple that fits. Sharp, but leaves borders */
+	SCALE_INTEGER ScaleMode = iota
+	/* As big as it fits, with the 8:7 pixels a TV would show */
+	SCALE_ASPECT
+)
 
-	if x >= NES_SCREEN_WIDTH || y >= NES_SCREEN_HEIGHT {
-		return
+func ParseScaleMode(name string) (ScaleMode, error) {
+	switch name {
+	case "integer":
+		return SCALE_INTEGER, nil
+	case "aspect":
+		return SCALE_ASPECT, nil
 	}
 
-	// Add the offset of the NES screen to these coords
-
-	back.DrawRect(x*NES_PTHP_RATIO+NES_SCREEN_X_START, y*NES_PTHP_RATIO+NES_SCREEN_Y_START, NES_PTHP_RATIO, NES_PTHP_RATIO, clr)
-}
-
-func (back *VideoBackend) DrawPixel(x int32, y int32, clr Color) {
-	// Set the color
-	back.sdlRenderer.SetDrawColor(clr.r, clr.g, clr.b, clr.a)
-
-	// Draw the pixel
-	back.sdlRenderer.DrawPoint(x, y)
+	return SCALE_INTEGER, fmt.Errorf("video: unknown scale mode '%s'", name)
 }
 
-func (back *VideoBackend) DrawRect(x int32, y int32, w int32, h int32, clr Color) {
-	rect := sdl.Rect{
-		X: x,
-		Y: y,
-		W: w,
-		H: h,
+/*
+ * Where the NES screen goes in a @w x @h window. Always centered
+ */
+func ScaleRect(mode ScaleMode, w int32, h int32) (x int32, y int32, sw int32, sh int32) {
+	switch mode {
+	case SCALE_ASPECT:
+		width := float64(NES_SCREEN_WIDTH) * NES_PIXEL_ASPECT
+		scale := min(float64(w)/width, float64(h)/NES_SCREEN_HEIGHT)
+
+		sw = int32(width * scale)
+		sh = int32(NES_SCREEN_HEIGHT * scale)
+	default:
+		scale := max(1, min(w/NES_SCREEN_WIDTH, h/NES_SCREEN_HEIGHT))
+
+		sw = NES_SCREEN_WIDTH * scale
+		sh = NES_SCREEN_HEIGHT * scale
 	}
 
-	// Set the color
-	back.sdlRenderer.SetDrawColor(clr.r, clr.g, clr.b, clr.a)
-
-	// Draw the rect
-	back.sdlRenderer.FillRect(&rect)
+	return (w - sw) / 2, (h - sh) / 2, sw, sh
 }
 
-func (back *VideoBackend) UpdateBackground() {
-	back.DrawRect(0, 0, SCREEN_WIDTH, SCREEN_HEIGHT, back.backgroundColor)
+func NewColor(r uint8, g uint8, b uint8, a uint8) Color {
+	return Color{r, g, b, a}
 }
 
-func (back *VideoBackend) SetDeferFlush(def bool) {
-	back.deferFlush = def
+func ColorWhite() Color {
+	return NewColor(0xff, 0xff, 0xff, 0xff)
 }
 
-func (back *VideoBackend) Flush() {
-	back.sdlRenderer.Present()
+func (clr Color) RGBA() (uint8, uint8, uint8, uint8) {
+	return clr.r, clr.g, clr.b, clr.a
 }
 
-func (back *VideoBackend) drawText(x int32, y int32, text string, color Color, nes bool) {
+/*
+ * Draw @text with the default font. Shared by the backends, since all
+ * of them end up drawing glyphs pixel by pixel
+ */
+func DrawFontText(back VideoBackend, font *Font, x int32, y int32, text string, color Color, background Color, nes bool) {
 
 	// Loop over all runes inside the provided text
 
@@ -139,18 +112,10 @@ func (back *VideoBackend) drawText(x int32, y int32, text string, color Color, n
 
 		// Draw the current glyph
 
-		back.defaultFont.DrawGlyph(int(x), int(y), byte(d), color, back.backgroundColor, nes)
+		font.DrawGlyph(int(x), int(y), byte(d), color, background, nes)
 
 		// Add an offset to the x-coordinate
 
 		x += 8
 	}
 }
-
-func (back *VideoBackend) DrawNESText(x int32, y int32, text string, color Color) {
-	back.drawText(x, y, text, color, true)
-}
-
-func (back *VideoBackend) DrawText(x int32, y int32, text string, color Color) {
-	back.drawText(x, y, text, color, false)
-}
@@ -1 +1,82 @@
 package video
+
+/*
+ * Host side events, so nothing outside of the backends has to know what
+ * library they're built on
+ */
+type Event interface{}
+
+/* The window got closed */
+type QuitEvent struct{}
+
+type KeyEvent struct {
+	Key     Key
+	Pressed bool
+	/* The OS repeating a key that's being held down */
+	Repeat bool
+}
+
+type Key int
+
+const (
+	KEY_UNKNOWN Key = iota
+	KEY_RETURN
+	KEY_ESCAPE
+	KEY_SPACE
+	KEY_TAB
+	KEY_BACKSPACE
+	KEY_UP
+	KEY_DOWN
+	KEY_LEFT
+	KEY_RIGHT
+	KEY_LSHIFT
+	KEY_RSHIFT
+	KEY_A
+	KEY_B
+	KEY_C
+	KEY_D
+	KEY_E
+	KEY_F
+	KEY_G
+	KEY_H
+	KEY_I
+	KEY_J
+	KEY_K
+	KEY_L
+	KEY_M
+	KEY_N
+	KEY_O
+	KEY_P
+	KEY_Q
+	KEY_R
+	KEY_S
+	KEY_T
+	KEY_U
+	KEY_V
+	KEY_W
+	KEY_X
+	KEY_Y
+	KEY_Z
+	KEY_0
+	KEY_1
+	KEY_2
+	KEY_3
+	KEY_4
+	KEY_5
+	KEY_6
+	KEY_7
+	KEY_8
+	KEY_9
+	KEY_F1
+	KEY_F2
+	KEY_F3
+	KEY_F4
+	KEY_F5
+	KEY_F6
+	KEY_F7
+	KEY_F8
+	KEY_F9
+	KEY_F10
+	KEY_F11
+	KEY_F12
+)
@@ -6,7 +6,7 @@ package video
 type Glyph [8]byte
 
 type Font struct {
-	backend *VideoBackend
+	backend VideoBackend
 	glyphs  []Glyph
 }
 
@@ -14,7 +14,7 @@ func (self *Font) getGlyph(index byte) Glyph {
 	return self.glyphs[index]
 }
 
-func NewFont(backend *VideoBackend) Font {
+func NewFont(backend VideoBackend) Font {
 	var ret Font
 
 	ret.backend = backend
@@ -0,0 +1,78 @@
+package video
+
+/*
+ * A finished (or in progress) NES screen
+ *
+ * The PPU draws into one of these and hands it to the backend once the
+ * frame is done, so backends get the whole screen at once instead of one
+ * call per pixel. Every pixel is kept twice: as the system palette index
+ * the PPU picked (with the emphasis bits on top), and as RGBA bytes that
+ * can go straight into a texture
+ */
+type Frame struct {
+	indices []uint16
+	rgba    []byte
+}
+
+const (
+	FRAME_BYTES_PER_PIXEL = 4
+	FRAME_PITCH           = NES_SCREEN_WIDTH * FRAME_BYTES_PER_PIXEL
+)
+
+func NewFrame() *Frame {
+	return &Frame{
+		indices: make([]uint16, NES_SCREEN_WIDTH*NES_SCREEN_HEIGHT),
+		rgba:    make([]byte, NES_SCREEN_WIDTH*NES_SCREEN_HEIGHT*FRAME_BYTES_PER_PIXEL),
+	}
+}
+
+func frameInBounds(x int32, y int32) bool {
+	return x >= 0 && y >= 0 && x < NES_SCREEN_WIDTH && y < NES_SCREEN_HEIGHT
+}
+
+func (frame *Frame) SetPixel(x int32, y int32, index uint16, clr Color) {
+	if !frameInBounds(x, y) {
+		return
+	}
+
+	i := y*NES_SCREEN_WIDTH + x
+
+	frame.indices[i] = index
+
+	// RGBA byte order, no matter what the host endianness is
+	p := frame.rgba[i*FRAME_BYTES_PER_PIXEL:]
+	p[0] = clr.r
+	p[1] = clr.g
+	p[2] = clr.b
+	p[3] = clr.a
+}
+
+func (frame *Frame) Pixel(x int32, y int32) Color {
+	if !frameInBounds(x, y) {
+		return Color{}
+	}
+
+	p := frame.rgba[(y*NES_SCREEN_WIDTH+x)*FRAME_BYTES_PER_PIXEL:]
+
+	return NewColor(p[0], p[1], p[2], p[3])
+}
+
+func (frame *Frame) Index(x int32, y int32) uint16 {
+	if !frameInBounds(x, y) {
+		return 0
+	}
+
+	return frame.indices[y*NES_SCREEN_WIDTH+x]
+}
+
+/*
+ * The raw RGBA bytes, row by row. FRAME_PITCH bytes per row
+ */
+func (frame *Frame) Bytes() []byte {
+	return frame.rgba
+}
+
+func (frame *Frame) CopyFrom(other *Frame) {
+	copy(frame.indices, other.indices)
+	copy(frame.rgba, other.rgba)
+}
@@ -0,0 +1,94 @@
+package video
+
+/*
+ * Backend that just keeps the NES screen in memory. There's no window and
+ * no input, which is exactly what we want for running headless and for
+ * checking the output pixel by pixel
+ *
+ * Drawing goes into a back buffer, and Flush copies that to the front, so
+ * readers always see a finished frame
+ */
+type Framebuffer struct {
+	back  *Frame
+	front *Frame
+
+	defaultFont     Font
+	backgroundColor Color
+
+	/* How many times we got flushed */
+	flushes uint64
+}
+
+func NewFramebuffer() *Framebuffer {
+	fb := &Framebuffer{
+		back:            NewFrame(),
+		front:           NewFrame(),
+		backgroundColor: NewColor(0x00, 0x00, 0x00, 0xff),
+	}
+
+	fb.defaultFont = NewFont(fb)
+
+	return fb
+}
+
+/*
+ * The pixel at (@x, @y) of the last presented frame
+ */
+func (fb *Framebuffer) Pixel(x int, y int) Color {
+	return fb.front.Pixel(int32(x), int32(y))
+}
+
+/*
+ * The entire last presented frame
+ */
+func (fb *Framebuffer) Frame() *Frame {
+	return fb.front
+}
+
+func (fb *Framebuffer) Flushes() uint64 {
+	return fb.flushes
+}
+
+func (fb *Framebuffer) CollectEvent() Event {
+	return nil
+}
+
+func (fb *Framebuffer) IsKeyPressed(key Key) bool {
+	return false
+}
+
+func (fb *Framebuffer) SubmitFrame(frame *Frame) {
+	fb.back.CopyFrom(frame)
+}
+
+func (fb *Framebuffer) DrawNESPixel(x int32, y int32, clr Color) {
+	fb.back.SetPixel(x, y, 0, clr)
+}
+
+/*
+ * There's no host screen around the NES one, so anything drawn in host
+ * coordinates just goes nowhere
+ */
+func (fb *Framebuffer) DrawPixel(x int32, y int32, clr Color) {
+}
+
+func (fb *Framebuffer) DrawRect(x int32, y int32, w int32, h int32, clr Color) {
+}
+
+func (fb *Framebuffer) DrawNESText(x int32, y int32, text string, color Color) {
+	DrawFontText(fb, &fb.defaultFont, x, y, text, color, fb.backgroundColor, true)
+}
+
+func (fb *Framebuffer) DrawText(x int32, y int32, text string, color Color) {
+}
+
+func (fb *Framebuffer) UpdateBackground() {
+}
+
+func (fb *Framebuffer) SetDeferFlush(def bool) {
+}
+
+func (fb *Framebuffer) Flush() {
+	fb.front.CopyFrom(fb.back)
+	fb.flushes++
+}
@@ -0,0 +1,210 @@
+package sdlvideo
+
+import (
+	"github.com/beakeyz/gones-emu/pkg/video"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+/*
+ * Video backend that draws into an SDL window
+ */
+type Backend struct {
+	sdlWindow   *sdl.Window
+	sdlRenderer *sdl.Renderer
+	/* The NES screen. Gets uploaded once per frame and scaled by the GPU */
+	sdlScreen *sdl.Texture
+	scaleMode video.ScaleMode
+
+	defaultFont     video.Font
+	backgroundColor video.Color
+
+	deferFlush bool
+}
+
+func New(scale video.ScaleMode) (*Backend, error) {
+	var err error
+	var backend *Backend = &Backend{
+		scaleMode: scale,
+	}
+
+	/* Initialize the SDL library for video stuff */
+	err = sdl.Init(sdl.INIT_VIDEO)
+
+	if err != nil {
+		return nil, err
+	}
+
+	/* Initialize a window and a renderer for us to draw with */
+	backend.sdlWindow, backend.sdlRenderer, err = sdl.CreateWindowAndRenderer(video.SCREEN_WIDTH, video.SCREEN_HEIGHT, sdl.WINDOW_RESIZABLE)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Nearest neighbour, we want our pixels sharp
+	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "0")
+
+	backend.sdlScreen, err = backend.sdlRenderer.CreateTexture(
+		sdl.PIXELFORMAT_RGBA32,
+		sdl.TEXTUREACCESS_STREAMING,
+		video.NES_SCREEN_WIDTH,
+		video.NES_SCREEN_HEIGHT)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a new font for us to use
+
+	backend.defaultFont = video.NewFont(backend)
+
+	// Defer flushing calls
+
+	backend.deferFlush = true
+	backend.backgroundColor = video.NewColor(0x1f, 0x1f, 0x1f, 0xff)
+
+	return backend, nil
+}
+
+/*
+ * TODO: Rename the 'video' package lmao
+ *
+ * It's much more than video atm
+ */
+func (back *Backend) CollectEvent() video.Event {
+	switch e := sdl.PollEvent().(type) {
+	case nil:
+		return nil
+	case *sdl.QuitEvent:
+		return &video.QuitEvent{}
+	case *sdl.KeyboardEvent:
+		return &video.KeyEvent{
+			Key:     keyFromSdl(e.Keysym.Sym),
+			Pressed: e.State == sdl.PRESSED,
+			Repeat:  e.Repeat != 0,
+		}
+	}
+
+	// Something we don't care about, but there might be more behind it
+	return back.CollectEvent()
+}
+
+func (back *Backend) IsKeyPressed(key video.Key) bool {
+	sc := sdl.GetScancodeFromKey(keyToSdl(key))
+	return (sdl.GetKeyboardState()[sc] != 0)
+}
+
+func (back *Backend) SetScaleMode(mode video.ScaleMode) {
+	back.scaleMode = mode
+}
+
+/*
+ * Where the NES screen goes in the window right now
+ */
+func (back *Backend) screenRect() sdl.Rect {
+	w, h, err := back.sdlRenderer.GetOutputSize()
+
+	if err != nil {
+		w, h = video.SCREEN_WIDTH, video.SCREEN_HEIGHT
+	}
+
+	x, y, sw, sh := video.ScaleRect(back.scaleMode, w, h)
+
+	return sdl.Rect{X: x, Y: y, W: sw, H: sh}
+}
+
+/*
+ * Copy the frame into the streaming texture, row by row since the
+ * texture pitch doesn't have to match ours
+ */
+func (back *Backend) SubmitFrame(frame *video.Frame) {
+	pixels, pitch, err := back.sdlScreen.Lock(nil)
+
+	if err != nil {
+		return
+	}
+
+	src := frame.Bytes()
+
+	for y := range video.NES_SCREEN_HEIGHT {
+		copy(pixels[y*pitch:y*pitch+video.FRAME_PITCH], src[y*video.FRAME_PITCH:])
+	}
+
+	back.sdlScreen.Unlock()
+}
+
+func (back *Backend) DrawNESPixel(x int32, y int32, clr video.Color) {
+
+	if x < 0 || y < 0 || x >= video.NES_SCREEN_WIDTH || y >= video.NES_SCREEN_HEIGHT {
+		return
+	}
+
+	// Scale the pixel up the same way the screen texture is
+
+	screen := back.screenRect()
+
+	x0 := screen.X + x*screen.W/video.NES_SCREEN_WIDTH
+	y0 := screen.Y + y*screen.H/video.NES_SCREEN_HEIGHT
+	x1 := screen.X + (x+1)*screen.W/video.NES_SCREEN_WIDTH
+	y1 := screen.Y + (y+1)*screen.H/video.NES_SCREEN_HEIGHT
+
+	back.DrawRect(x0, y0, x1-x0, y1-y0, clr)
+}
+
+func (back *Backend) DrawPixel(x int32, y int32, clr video.Color) {
+	r, g, b, a := clr.RGBA()
+
+	// Set the color
+	back.sdlRenderer.SetDrawColor(r, g, b, a)
+
+	// Draw the pixel
+	back.sdlRenderer.DrawPoint(x, y)
+}
+
+func (back *Backend) DrawRect(x int32, y int32, w int32, h int32, clr video.Color) {
+	rect := sdl.Rect{
+		X: x,
+		Y: y,
+		W: w,
+		H: h,
+	}
+
+	r, g, b, a := clr.RGBA()
+
+	// Set the color
+	back.sdlRenderer.SetDrawColor(r, g, b, a)
+
+	// Draw the rect
+	back.sdlRenderer.FillRect(&rect)
+}
+
+/*
+ * Clear the window and put the last NES screen on it. Everything else
+ * gets drawn on top of this
+ */
+func (back *Backend) UpdateBackground() {
+	r, g, b, a := back.backgroundColor.RGBA()
+
+	back.sdlRenderer.SetDrawColor(r, g, b, a)
+	back.sdlRenderer.Clear()
+
+	screen := back.screenRect()
+
+	back.sdlRenderer.Copy(back.sdlScreen, nil, &screen)
+}
+
+func (back *Backend) SetDeferFlush(def bool) {
+	back.deferFlush = def
+}
+
+func (back *Backend) Flush() {
+	back.sdlRenderer.Present()
+}
+
+func (back *Backend) DrawNESText(x int32, y int32, text string, color video.Color) {
+	video.DrawFontText(back, &back.defaultFont, x, y, text, color, back.backgroundColor, true)
+}
+
+func (back *Backend) DrawText(x int32, y int32, text string, color video.Color) {
+	video.DrawFontText(back, &back.defaultFont, x, y, text, color, back.backgroundColor, false)
+}
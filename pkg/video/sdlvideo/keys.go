@@ -0,0 +1,91 @@
+package sdlvideo
+
+import (
+	"github.com/beakeyz/gones-emu/pkg/video"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+/*
+ * Our keys and the SDL keycodes they map to
+ */
+var sdlKeys = map[video.Key]sdl.Keycode{
+	video.KEY_RETURN:    sdl.K_RETURN,
+	video.KEY_ESCAPE:    sdl.K_ESCAPE,
+	video.KEY_SPACE:     sdl.K_SPACE,
+	video.KEY_TAB:       sdl.K_TAB,
+	video.KEY_BACKSPACE: sdl.K_BACKSPACE,
+	video.KEY_UP:        sdl.K_UP,
+	video.KEY_DOWN:      sdl.K_DOWN,
+	video.KEY_LEFT:      sdl.K_LEFT,
+	video.KEY_RIGHT:     sdl.K_RIGHT,
+	video.KEY_LSHIFT:    sdl.K_LSHIFT,
+	video.KEY_RSHIFT:    sdl.K_RSHIFT,
+	video.KEY_A:         sdl.K_a,
+	video.KEY_B:         sdl.K_b,
+	video.KEY_C:         sdl.K_c,
+	video.KEY_D:         sdl.K_d,
+	video.KEY_E:         sdl.K_e,
+	video.KEY_F:         sdl.K_f,
+	video.KEY_G:         sdl.K_g,
+	video.KEY_H:         sdl.K_h,
+	video.KEY_I:         sdl.K_i,
+	video.KEY_J:         sdl.K_j,
+	video.KEY_K:         sdl.K_k,
+	video.KEY_L:         sdl.K_l,
+	video.KEY_M:         sdl.K_m,
+	video.KEY_N:         sdl.K_n,
+	video.KEY_O:         sdl.K_o,
+	video.KEY_P:         sdl.K_p,
+	video.KEY_Q:         sdl.K_q,
+	video.KEY_R:         sdl.K_r,
+	video.KEY_S:         sdl.K_s,
+	video.KEY_T:         sdl.K_t,
+	video.KEY_U:         sdl.K_u,
+	video.KEY_V:         sdl.K_v,
+	video.KEY_W:         sdl.K_w,
+	video.KEY_X:         sdl.K_x,
+	video.KEY_Y:         sdl.K_y,
+	video.KEY_Z:         sdl.K_z,
+	video.KEY_0:         sdl.K_0,
+	video.KEY_1:         sdl.K_1,
+	video.KEY_2:         sdl.K_2,
+	video.KEY_3:         sdl.K_3,
+	video.KEY_4:         sdl.K_4,
+	video.KEY_5:         sdl.K_5,
+	video.KEY_6:         sdl.K_6,
+	video.KEY_7:         sdl.K_7,
+	video.KEY_8:         sdl.K_8,
+	video.KEY_9:         sdl.K_9,
+	video.KEY_F1:        sdl.K_F1,
+	video.KEY_F2:        sdl.K_F2,
+	video.KEY_F3:        sdl.K_F3,
+	video.KEY_F4:        sdl.K_F4,
+	video.KEY_F5:        sdl.K_F5,
+	video.KEY_F6:        sdl.K_F6,
+	video.KEY_F7:        sdl.K_F7,
+	video.KEY_F8:        sdl.K_F8,
+	video.KEY_F9:        sdl.K_F9,
+	video.KEY_F10:       sdl.K_F10,
+	video.KEY_F11:       sdl.K_F11,
+	video.KEY_F12:       sdl.K_F12,
+}
+
+func keyToSdl(key video.Key) sdl.Keycode {
+	code, ok := sdlKeys[key]
+
+	if !ok {
+		return sdl.K_UNKNOWN
+	}
+
+	return code
+}
+
+func keyFromSdl(code sdl.Keycode) video.Key {
+	for key, c := range sdlKeys {
+		if c == code {
+			return key
+		}
+	}
+
+	return video.KEY_UNKNOWN
+}